@@ -1,19 +1,30 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/jghiloni/watchedsky-social/backend/mongo"
+	"github.com/jghiloni/watchedsky-social/backend/capxml"
+	"github.com/jghiloni/watchedsky-social/backend/cql2"
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/store"
+	"github.com/jghiloni/watchedsky-social/backend/utils"
 )
 
+const geoJSONMIMEType = "application/geo+json"
+
 func ListFeatures(ctx context.Context) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		mongoClient := mongo.GetClient(ctx)
-		if mongoClient == nil {
-			return errors.New("no mongo client configured")
+		featureStore := store.GetStore(ctx)
+		if featureStore == nil {
+			return errors.New("no feature store configured")
 		}
 
 		pageSize := c.QueryInt("limit", 100)
@@ -29,35 +40,188 @@ func ListFeatures(ctx context.Context) fiber.Handler {
 		if page < 0 {
 			page = 0
 		}
-		featureType := c.Query("type")
 
-		response, err := mongoClient.ListFeaturesByType(ctx, featureType, mongo.PageOptions{
-			Page:     uint(page),
-			PageSize: uint(pageSize),
+		filter, err := parseFilter(c)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(map[string]string{"error": err.Error()})
+		}
+
+		order := store.SortOrder(c.Query("sort", string(store.OrderNewest)))
+		if order != store.OrderNewest && order != store.OrderPriority {
+			return c.Status(http.StatusBadRequest).JSON(map[string]string{"error": "sort must be newest or priority"})
+		}
+
+		response, err := featureStore.ListFeatures(ctx, filter, store.PageOptions{
+			Page:      uint(page),
+			PageSize:  uint(pageSize),
+			Order:     order,
+			Cursor:    c.Query("cursor"),
+			WithTotal: c.QueryBool("total"),
 		})
 
+		if errors.Is(err, store.ErrInvalidFilter) || errors.Is(err, store.ErrInvalidCursor) {
+			return c.Status(http.StatusBadRequest).JSON(map[string]string{"error": err.Error()})
+		}
+
 		if err != nil {
 			return c.Status(http.StatusInternalServerError).JSON(map[string]string{"error": err.Error()})
 		}
 
-		return c.JSON(response)
+		setPageLinks(c, response)
+		return sendFeatures(c, response.Features, response)
 	}
 }
 
 func GetFeature(ctx context.Context) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		mongoClient := mongo.GetClient(ctx)
-		if mongoClient == nil {
-			return errors.New("no mongo client configured")
+		featureStore := store.GetStore(ctx)
+		if featureStore == nil {
+			return errors.New("no feature store configured")
 		}
 
 		featureID := c.Params("id")
 
-		f, err := mongoClient.GetFeaturesByID(ctx, featureID)
+		f, err := featureStore.GetFeaturesByID(ctx, featureID)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(map[string]string{"error": err.Error()})
+		}
+
+		return sendFeatures(c, f.Features, f)
+	}
+}
+
+// GetFeatureHistory returns every known version of an alert, linked into a
+// chain, along with the current state of the chain
+func GetFeatureHistory(ctx context.Context) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		featureStore := store.GetStore(ctx)
+		if featureStore == nil {
+			return errors.New("no feature store configured")
+		}
+
+		versions, err := featureStore.GetAlertVersions(ctx, c.Params("id"))
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(map[string]string{"error": err.Error()})
+		}
+
+		if len(versions) == 0 {
+			return c.Status(http.StatusNotFound).JSON(map[string]string{"error": "alert not found"})
+		}
+
+		return c.JSON(features.BuildAlertChain(versions, time.Now()))
+	}
+}
+
+// parseFilter reads the filters that listings and searches share from the
+// query string
+func parseFilter(c *fiber.Ctx) (store.FeatureFilter, error) {
+	filter := store.FeatureFilter{
+		Type:       c.Query("type"),
+		EventCodes: splitQuery(c, "code"),
+		FIPS:       splitQuery(c, "fips"),
+		States:     splitQuery(c, "state"),
+		HazardTags: utils.Map(splitQuery(c, "hazard"), func(s string) features.HazardTag {
+			return features.HazardTag(strings.ToLower(s))
+		}),
+		MinWindGustMPH:    c.QueryFloat("gust", 0),
+		MinHailSizeInches: c.QueryFloat("hail", 0),
+		Archived:          c.QueryBool("archived"),
+	}
+
+	// at implies active, and active on its own means right now
+	if at := c.Query("at"); at != "" {
+		t, err := time.Parse(time.RFC3339, at)
 		if err != nil {
+			return filter, errors.New("at must be an RFC 3339 timestamp")
+		}
+		filter.ActiveAt = t
+	} else if c.QueryBool("active") {
+		filter.ActiveAt = time.Now()
+	}
+
+	if expr := c.Query("filter"); expr != "" {
+		var err error
+		if filter.Expression, err = cql2.Parse(expr); err != nil {
+			return filter, err
+		}
+	}
+
+	return filter, nil
+}
+
+// setPageLinks sets an RFC 8288 Link header pointing at the next and
+// previous pages, if there are any. The links repeat the request with the
+// cursor in place of the page number
+func setPageLinks(c *fiber.Ctx, page store.FeaturePage) {
+	links := []string{}
+	for _, link := range []struct{ rel, cursor string }{{"next", page.Next}, {"prev", page.Prev}} {
+		if link.cursor == "" {
+			continue
+		}
+
+		query := url.Values{}
+		c.Context().QueryArgs().VisitAll(func(key []byte, value []byte) {
+			query.Add(string(key), string(value))
+		})
+		query.Del("page")
+		query.Set("cursor", link.cursor)
+
+		links = append(links, fmt.Sprintf(`<%s%s?%s>; rel="%s"`, c.BaseURL(), c.Path(), query.Encode(), link.rel))
+	}
+
+	if len(links) > 0 {
+		c.Set(fiber.HeaderLink, strings.Join(links, ", "))
+	}
+}
+
+// splitQuery returns the comma separated values of a query parameter
+func splitQuery(c *fiber.Ctx, key string) []string {
+	raw := c.Query(key)
+	if raw == "" {
+		return nil
+	}
+
+	values := []string{}
+	for _, v := range strings.Split(raw, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
+
+// sendFeatures writes body as JSON, unless the client asked for CAP, in which
+// case feats are converted to CAP alerts. A single feature is sent as a bare
+// <alert>, anything else is wrapped in <alerts>
+func sendFeatures(c *fiber.Ctx, feats features.Features, body any) error {
+	switch c.Accepts(fiber.MIMEApplicationJSON, geoJSONMIMEType, capxml.MIMEType) {
+	case capxml.MIMEType:
+		var doc any = capxml.FromFeatures(feats)
+		if len(feats) == 1 {
+			a, err := capxml.FromFeature(feats[0])
+			if err != nil {
+				return c.Status(http.StatusNotAcceptable).JSON(map[string]string{"error": err.Error()})
+			}
+
+			doc = a
+		}
+
+		buf := new(bytes.Buffer)
+		if err := capxml.Encode(buf, doc); err != nil {
 			return c.Status(http.StatusInternalServerError).JSON(map[string]string{"error": err.Error()})
 		}
 
-		return c.JSON(f)
+		c.Set(fiber.HeaderContentType, capxml.MIMEType)
+		return c.Send(buf.Bytes())
+	case geoJSONMIMEType:
+		if err := c.JSON(body); err != nil {
+			return err
+		}
+
+		c.Set(fiber.HeaderContentType, geoJSONMIMEType)
+		return nil
+	default:
+		return c.JSON(body)
 	}
 }
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jghiloni/watchedsky-social/backend/store"
+)
+
+// SearchFeatures runs a full text search over alerts, most relevant first.
+// The search is in q, and the same filters as ListFeatures narrow it down
+func SearchFeatures(ctx context.Context) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		featureStore := store.GetStore(ctx)
+		if featureStore == nil {
+			return errors.New("no feature store configured")
+		}
+
+		pageSize := c.QueryInt("limit", 25)
+		if pageSize < 1 {
+			pageSize = 1
+		}
+
+		if pageSize > 500 {
+			pageSize = 500
+		}
+
+		page := c.QueryInt("page", 0)
+		if page < 0 {
+			page = 0
+		}
+
+		filter, err := parseFilter(c)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(map[string]string{"error": err.Error()})
+		}
+
+		response, err := featureStore.SearchFeatures(ctx, c.Query("q"), filter, store.PageOptions{
+			Page:      uint(page),
+			PageSize:  uint(pageSize),
+			WithTotal: c.QueryBool("total"),
+		})
+
+		if errors.Is(err, store.ErrEmptySearch) || errors.Is(err, store.ErrInvalidFilter) {
+			return c.Status(http.StatusBadRequest).JSON(map[string]string{"error": err.Error()})
+		}
+
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(map[string]string{"error": err.Error()})
+		}
+
+		return sendFeatures(c, response.Features(), response)
+	}
+}
@@ -0,0 +1,97 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jghiloni/watchedsky-social/backend/api"
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/store"
+	"github.com/jghiloni/watchedsky-social/backend/store/memory"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Searching alerts", func() {
+	var app *fiber.App
+
+	BeforeEach(func() {
+		s := memory.New()
+		ctx := store.WithStore(context.Background(), s)
+
+		for _, alert := range []features.JSONObject{
+			{"id": "tornado", "event": "Tornado Warning", "areaDesc": "Tulsa, OK", "description": "A confirmed tornado was located near Broken Arrow."},
+			{"id": "storm", "event": "Severe Thunderstorm Warning", "areaDesc": "Wichita, KS", "description": "Damaging winds. A tornado is possible."},
+			{"id": "flood", "event": "Flash Flood Warning", "areaDesc": "Tulsa, OK", "description": "Flash flooding is ongoing. This is not a test."},
+			{"id": "test", "event": "Flash Flood Warning", "areaDesc": "Norman, OK", "description": "This is a test of flash flood alerting."},
+		} {
+			alert["@type"] = features.Alert
+			alert["messageType"] = "Alert"
+			alert["sent"] = "2024-06-05T21:00:00Z"
+			alert["expires"] = "2024-06-05T23:30:00Z"
+
+			_, err := s.AddFeatures(ctx, features.Feature{ID: alert["id"].(string), Properties: alert})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		app = fiber.New()
+		app.Get("/api/search", api.SearchFeatures(ctx))
+	})
+
+	type hit struct {
+		Feature    features.Feature    `json:"feature"`
+		Highlights map[string][]string `json:"highlights"`
+	}
+
+	// search runs a search and returns the response's status and hits
+	search := func(query url.Values) (int, []hit) {
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/api/search?"+query.Encode(), nil))
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+
+		var body struct {
+			Hits []hit `json:"hits"`
+		}
+		Expect(json.NewDecoder(resp.Body).Decode(&body)).To(Succeed())
+		return resp.StatusCode, body.Hits
+	}
+
+	ids := func(hits []hit) []string {
+		found := make([]string, len(hits))
+		for i, h := range hits {
+			found[i] = h.Feature.ID
+		}
+		return found
+	}
+
+	It("Puts matches in the event before matches in the description", func() {
+		status, hits := search(url.Values{"q": {"tornado"}})
+		Expect(status).To(Equal(fiber.StatusOK))
+		Expect(ids(hits)).To(Equal([]string{"tornado", "storm"}))
+		Expect(hits[0].Highlights).To(HaveKeyWithValue("event", []string{"<mark>Tornado</mark> Warning"}))
+	})
+
+	It("Requires phrases and leaves out excluded terms", func() {
+		_, hits := search(url.Values{"q": {`"flash flood" -test`}})
+		Expect(ids(hits)).To(BeEmpty())
+
+		_, hits = search(url.Values{"q": {`"flash flood" -alerting`}})
+		Expect(ids(hits)).To(Equal([]string{"flood"}))
+	})
+
+	It("Narrows searches down with filters", func() {
+		_, hits := search(url.Values{"q": {"warning"}, "filter": {"areaDesc LIKE 'Tulsa%'"}})
+		Expect(ids(hits)).To(ConsistOf("tornado", "flood"))
+	})
+
+	It("Rejects empty searches and invalid filters", func() {
+		status, _ := search(url.Values{"q": {"  -"}})
+		Expect(status).To(Equal(fiber.StatusBadRequest))
+
+		status, _ = search(url.Values{"q": {"tornado"}, "filter": {"areaDesc LIKE"}})
+		Expect(status).To(Equal(fiber.StatusBadRequest))
+	})
+})
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jghiloni/watchedsky-social/backend/store"
+)
+
+const csvMIMEType = "text/csv"
+
+// GetAlertStats returns statistics about the alerts sent between from and
+// to, which are RFC 3339 timestamps or dates, narrowed down by the same
+// filters as ListFeatures. The stats are CSV if format is csv or the client
+// accepts text/csv, and JSON otherwise
+func GetAlertStats(ctx context.Context) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		featureStore := store.GetStore(ctx)
+		if featureStore == nil {
+			return errors.New("no feature store configured")
+		}
+
+		filter, err := parseFilter(c)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(map[string]string{"error": err.Error()})
+		}
+
+		q := store.StatsQuery{Filter: filter}
+		if q.From, err = parseTimeQuery(c, "from"); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(map[string]string{"error": err.Error()})
+		}
+
+		if q.To, err = parseTimeQuery(c, "to"); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(map[string]string{"error": err.Error()})
+		}
+
+		if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+			return c.Status(http.StatusBadRequest).JSON(map[string]string{"error": "from must be before to"})
+		}
+
+		stats, err := featureStore.GetAlertStats(ctx, q)
+		if errors.Is(err, store.ErrInvalidFilter) {
+			return c.Status(http.StatusBadRequest).JSON(map[string]string{"error": err.Error()})
+		}
+
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(map[string]string{"error": err.Error()})
+		}
+
+		if c.Query("format") == "csv" || c.Accepts(fiber.MIMEApplicationJSON, csvMIMEType) == csvMIMEType {
+			body, err := statsCSV(stats)
+			if err != nil {
+				return c.Status(http.StatusInternalServerError).JSON(map[string]string{"error": err.Error()})
+			}
+
+			c.Set(fiber.HeaderContentType, csvMIMEType)
+			c.Set(fiber.HeaderContentDisposition, `attachment; filename="alert-stats.csv"`)
+			return c.Send(body)
+		}
+
+		return c.JSON(stats)
+	}
+}
+
+// parseTimeQuery parses a query parameter that is an RFC 3339 timestamp or
+// a date, which is midnight UTC. It is zero if the parameter isn't set
+func parseTimeQuery(c *fiber.Ctx, key string) (time.Time, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+
+	if t, err := time.Parse(time.DateOnly, raw); err == nil {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp or a date", key)
+}
+
+// statsCSV writes stats as rows of stat, key and value. The totals come
+// first with empty keys, and then a row for each count
+func statsCSV(stats store.AlertStats) ([]byte, error) {
+	rows := [][]string{
+		{"stat", "key", "value"},
+		{"total", "", strconv.FormatInt(stats.Total, 10)},
+	}
+
+	if stats.MedianDurationSeconds != nil {
+		rows = append(rows, []string{"medianDurationSeconds", "", strconv.FormatFloat(*stats.MedianDurationSeconds, 'f', -1, 64)})
+	}
+
+	if stats.FirstSent != nil {
+		rows = append(rows, []string{"firstSent", "", stats.FirstSent.Format(time.RFC3339)})
+	}
+
+	if stats.LastSent != nil {
+		rows = append(rows, []string{"lastSent", "", stats.LastSent.Format(time.RFC3339)})
+	}
+
+	for _, breakdown := range []struct {
+		stat   string
+		counts []store.Count
+	}{
+		{"event", stats.ByEvent},
+		{"severity", stats.BySeverity},
+		{"sender", stats.BySender},
+		{"state", stats.ByState},
+		{"day", stats.ByDay},
+	} {
+		for _, count := range breakdown.counts {
+			rows = append(rows, []string{breakdown.stat, count.Key, strconv.FormatInt(count.Count, 10)})
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	w := csv.NewWriter(buf)
+	if err := w.WriteAll(rows); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,13 @@
+package bsky_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestBsky(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Bsky Suite")
+}
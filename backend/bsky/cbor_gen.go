@@ -26,7 +26,7 @@ func (t *Alert) MarshalCBOR(w io.Writer) error {
 	}
 
 	cw := cbg.NewCborWriter(w)
-	fieldCount := 23
+	fieldCount := 30
 
 	if t.AffectedZones == nil {
 		fieldCount--
@@ -36,10 +36,18 @@ func (t *Alert) MarshalCBOR(w io.Writer) error {
 		fieldCount--
 	}
 
+	if t.EasEventCode == nil {
+		fieldCount--
+	}
+
 	if t.Ends == nil {
 		fieldCount--
 	}
 
+	if t.EventMotionDescription == nil {
+		fieldCount--
+	}
+
 	if t.Expires == nil {
 		fieldCount--
 	}
@@ -48,14 +56,30 @@ func (t *Alert) MarshalCBOR(w io.Writer) error {
 		fieldCount--
 	}
 
+	if t.Hazards == nil {
+		fieldCount--
+	}
+
 	if t.Instruction == nil {
 		fieldCount--
 	}
 
+	if t.Language == nil {
+		fieldCount--
+	}
+
+	if t.Localizations == nil {
+		fieldCount--
+	}
+
 	if t.Onset == nil {
 		fieldCount--
 	}
 
+	if t.References == nil {
+		fieldCount--
+	}
+
 	if t.ReplacedAt == nil {
 		fieldCount--
 	}
@@ -64,6 +88,10 @@ func (t *Alert) MarshalCBOR(w io.Writer) error {
 		fieldCount--
 	}
 
+	if t.SameCodes == nil {
+		fieldCount--
+	}
+
 	if _, err := cw.Write(cbg.CborEncodeMajorType(cbg.MajMap, uint64(fieldCount))); err != nil {
 		return err
 	}
@@ -298,6 +326,25 @@ func (t *Alert) MarshalCBOR(w io.Writer) error {
 		}
 	}
 
+	// t.Hazards (bsky.Alert_Hazards) (struct)
+	if t.Hazards != nil {
+
+		if len("hazards") > 1000000 {
+			return xerrors.Errorf("Value in field \"hazards\" was too long")
+		}
+
+		if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("hazards"))); err != nil {
+			return err
+		}
+		if _, err := cw.WriteString(string("hazards")); err != nil {
+			return err
+		}
+
+		if err := t.Hazards.MarshalCBOR(cw); err != nil {
+			return err
+		}
+	}
+
 	// t.Urgency (string) (string)
 	if len("urgency") > 1000000 {
 		return xerrors.Errorf("Value in field \"urgency\" was too long")
@@ -395,6 +442,38 @@ func (t *Alert) MarshalCBOR(w io.Writer) error {
 		return err
 	}
 
+	// t.Language (string) (string)
+	if t.Language != nil {
+
+		if len("language") > 1000000 {
+			return xerrors.Errorf("Value in field \"language\" was too long")
+		}
+
+		if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("language"))); err != nil {
+			return err
+		}
+		if _, err := cw.WriteString(string("language")); err != nil {
+			return err
+		}
+
+		if t.Language == nil {
+			if _, err := cw.Write(cbg.CborNull); err != nil {
+				return err
+			}
+		} else {
+			if len(*t.Language) > 1000000 {
+				return xerrors.Errorf("Value in field t.Language was too long")
+			}
+
+			if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(*t.Language))); err != nil {
+				return err
+			}
+			if _, err := cw.WriteString(string(*t.Language)); err != nil {
+				return err
+			}
+		}
+	}
+
 	// t.Severity (string) (string)
 	if len("severity") > 1000000 {
 		return xerrors.Errorf("Value in field \"severity\" was too long")
@@ -464,6 +543,78 @@ func (t *Alert) MarshalCBOR(w io.Writer) error {
 		return err
 	}
 
+	// t.SameCodes ([]string) (slice)
+	if t.SameCodes != nil {
+
+		if len("sameCodes") > 1000000 {
+			return xerrors.Errorf("Value in field \"sameCodes\" was too long")
+		}
+
+		if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("sameCodes"))); err != nil {
+			return err
+		}
+		if _, err := cw.WriteString(string("sameCodes")); err != nil {
+			return err
+		}
+
+		if len(t.SameCodes) > 8192 {
+			return xerrors.Errorf("Slice value in field t.SameCodes was too long")
+		}
+
+		if err := cw.WriteMajorTypeHeader(cbg.MajArray, uint64(len(t.SameCodes))); err != nil {
+			return err
+		}
+		for _, v := range t.SameCodes {
+			if len(v) > 1000000 {
+				return xerrors.Errorf("Value in field v was too long")
+			}
+
+			if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(v))); err != nil {
+				return err
+			}
+			if _, err := cw.WriteString(string(v)); err != nil {
+				return err
+			}
+
+		}
+	}
+
+	// t.References ([]string) (slice)
+	if t.References != nil {
+
+		if len("references") > 1000000 {
+			return xerrors.Errorf("Value in field \"references\" was too long")
+		}
+
+		if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("references"))); err != nil {
+			return err
+		}
+		if _, err := cw.WriteString(string("references")); err != nil {
+			return err
+		}
+
+		if len(t.References) > 8192 {
+			return xerrors.Errorf("Slice value in field t.References was too long")
+		}
+
+		if err := cw.WriteMajorTypeHeader(cbg.MajArray, uint64(len(t.References))); err != nil {
+			return err
+		}
+		for _, v := range t.References {
+			if len(v) > 1000000 {
+				return xerrors.Errorf("Value in field v was too long")
+			}
+
+			if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(v))); err != nil {
+				return err
+			}
+			if _, err := cw.WriteString(string(v)); err != nil {
+				return err
+			}
+
+		}
+	}
+
 	// t.ReplacedAt (string) (string)
 	if t.ReplacedAt != nil {
 
@@ -629,6 +780,38 @@ func (t *Alert) MarshalCBOR(w io.Writer) error {
 		return err
 	}
 
+	// t.EasEventCode (string) (string)
+	if t.EasEventCode != nil {
+
+		if len("easEventCode") > 1000000 {
+			return xerrors.Errorf("Value in field \"easEventCode\" was too long")
+		}
+
+		if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("easEventCode"))); err != nil {
+			return err
+		}
+		if _, err := cw.WriteString(string("easEventCode")); err != nil {
+			return err
+		}
+
+		if t.EasEventCode == nil {
+			if _, err := cw.Write(cbg.CborNull); err != nil {
+				return err
+			}
+		} else {
+			if len(*t.EasEventCode) > 1000000 {
+				return xerrors.Errorf("Value in field t.EasEventCode was too long")
+			}
+
+			if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(*t.EasEventCode))); err != nil {
+				return err
+			}
+			if _, err := cw.WriteString(string(*t.EasEventCode)); err != nil {
+				return err
+			}
+		}
+	}
+
 	// t.AffectedZones ([]string) (slice)
 	if t.AffectedZones != nil {
 
@@ -664,6 +847,67 @@ func (t *Alert) MarshalCBOR(w io.Writer) error {
 
 		}
 	}
+
+	// t.Localizations ([]*bsky.Alert_Localization) (slice)
+	if t.Localizations != nil {
+
+		if len("localizations") > 1000000 {
+			return xerrors.Errorf("Value in field \"localizations\" was too long")
+		}
+
+		if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("localizations"))); err != nil {
+			return err
+		}
+		if _, err := cw.WriteString(string("localizations")); err != nil {
+			return err
+		}
+
+		if len(t.Localizations) > 8192 {
+			return xerrors.Errorf("Slice value in field t.Localizations was too long")
+		}
+
+		if err := cw.WriteMajorTypeHeader(cbg.MajArray, uint64(len(t.Localizations))); err != nil {
+			return err
+		}
+		for _, v := range t.Localizations {
+			if err := v.MarshalCBOR(cw); err != nil {
+				return err
+			}
+
+		}
+	}
+
+	// t.EventMotionDescription (string) (string)
+	if t.EventMotionDescription != nil {
+
+		if len("eventMotionDescription") > 1000000 {
+			return xerrors.Errorf("Value in field \"eventMotionDescription\" was too long")
+		}
+
+		if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("eventMotionDescription"))); err != nil {
+			return err
+		}
+		if _, err := cw.WriteString(string("eventMotionDescription")); err != nil {
+			return err
+		}
+
+		if t.EventMotionDescription == nil {
+			if _, err := cw.Write(cbg.CborNull); err != nil {
+				return err
+			}
+		} else {
+			if len(*t.EventMotionDescription) > 1000000 {
+				return xerrors.Errorf("Value in field t.EventMotionDescription was too long")
+			}
+
+			if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(*t.EventMotionDescription))); err != nil {
+				return err
+			}
+			if _, err := cw.WriteString(string(*t.EventMotionDescription)); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
@@ -834,6 +1078,26 @@ func (t *Alert) UnmarshalCBOR(r io.Reader) (err error) {
 					t.Expires = (*string)(&sval)
 				}
 			}
+			// t.Hazards (bsky.Alert_Hazards) (struct)
+		case "hazards":
+
+			{
+
+				b, err := cr.ReadByte()
+				if err != nil {
+					return err
+				}
+				if b != cbg.CborNull[0] {
+					if err := cr.UnreadByte(); err != nil {
+						return err
+					}
+					t.Hazards = new(Alert_Hazards)
+					if err := t.Hazards.UnmarshalCBOR(cr); err != nil {
+						return xerrors.Errorf("unmarshaling t.Hazards pointer: %w", err)
+					}
+				}
+
+			}
 			// t.Urgency (string) (string)
 		case "urgency":
 
@@ -897,6 +1161,27 @@ func (t *Alert) UnmarshalCBOR(r io.Reader) (err error) {
 
 				t.Headline = string(sval)
 			}
+			// t.Language (string) (string)
+		case "language":
+
+			{
+				b, err := cr.ReadByte()
+				if err != nil {
+					return err
+				}
+				if b != cbg.CborNull[0] {
+					if err := cr.UnreadByte(); err != nil {
+						return err
+					}
+
+					sval, err := cbg.ReadStringWithMax(cr, 1000000)
+					if err != nil {
+						return err
+					}
+
+					t.Language = (*string)(&sval)
+				}
+			}
 			// t.Severity (string) (string)
 		case "severity":
 
@@ -930,46 +1215,126 @@ func (t *Alert) UnmarshalCBOR(r io.Reader) (err error) {
 
 				t.Effective = string(sval)
 			}
-			// t.ReplacedAt (string) (string)
-		case "replacedAt":
+			// t.SameCodes ([]string) (slice)
+		case "sameCodes":
 
-			{
-				b, err := cr.ReadByte()
-				if err != nil {
-					return err
-				}
-				if b != cbg.CborNull[0] {
-					if err := cr.UnreadByte(); err != nil {
-						return err
-					}
+			maj, extra, err = cr.ReadHeader()
+			if err != nil {
+				return err
+			}
 
-					sval, err := cbg.ReadStringWithMax(cr, 1000000)
-					if err != nil {
-						return err
-					}
+			if extra > 8192 {
+				return fmt.Errorf("t.SameCodes: array too large (%d)", extra)
+			}
 
-					t.ReplacedAt = (*string)(&sval)
-				}
+			if maj != cbg.MajArray {
+				return fmt.Errorf("expected cbor array")
 			}
-			// t.ReplacedBy (string) (string)
-		case "replacedBy":
 
-			{
-				b, err := cr.ReadByte()
-				if err != nil {
-					return err
-				}
-				if b != cbg.CborNull[0] {
-					if err := cr.UnreadByte(); err != nil {
-						return err
-					}
+			if extra > 0 {
+				t.SameCodes = make([]string, extra)
+			}
 
-					sval, err := cbg.ReadStringWithMax(cr, 1000000)
-					if err != nil {
-						return err
+			for i := 0; i < int(extra); i++ {
+				{
+					var maj byte
+					var extra uint64
+					var err error
+					_ = maj
+					_ = extra
+					_ = err
+
+					{
+						sval, err := cbg.ReadStringWithMax(cr, 1000000)
+						if err != nil {
+							return err
+						}
+
+						t.SameCodes[i] = string(sval)
 					}
 
-					t.ReplacedBy = (*string)(&sval)
+				}
+			}
+			// t.References ([]string) (slice)
+		case "references":
+
+			maj, extra, err = cr.ReadHeader()
+			if err != nil {
+				return err
+			}
+
+			if extra > 8192 {
+				return fmt.Errorf("t.References: array too large (%d)", extra)
+			}
+
+			if maj != cbg.MajArray {
+				return fmt.Errorf("expected cbor array")
+			}
+
+			if extra > 0 {
+				t.References = make([]string, extra)
+			}
+
+			for i := 0; i < int(extra); i++ {
+				{
+					var maj byte
+					var extra uint64
+					var err error
+					_ = maj
+					_ = extra
+					_ = err
+
+					{
+						sval, err := cbg.ReadStringWithMax(cr, 1000000)
+						if err != nil {
+							return err
+						}
+
+						t.References[i] = string(sval)
+					}
+
+				}
+			}
+			// t.ReplacedAt (string) (string)
+		case "replacedAt":
+
+			{
+				b, err := cr.ReadByte()
+				if err != nil {
+					return err
+				}
+				if b != cbg.CborNull[0] {
+					if err := cr.UnreadByte(); err != nil {
+						return err
+					}
+
+					sval, err := cbg.ReadStringWithMax(cr, 1000000)
+					if err != nil {
+						return err
+					}
+
+					t.ReplacedAt = (*string)(&sval)
+				}
+			}
+			// t.ReplacedBy (string) (string)
+		case "replacedBy":
+
+			{
+				b, err := cr.ReadByte()
+				if err != nil {
+					return err
+				}
+				if b != cbg.CborNull[0] {
+					if err := cr.UnreadByte(); err != nil {
+						return err
+					}
+
+					sval, err := cbg.ReadStringWithMax(cr, 1000000)
+					if err != nil {
+						return err
+					}
+
+					t.ReplacedBy = (*string)(&sval)
 				}
 			}
 			// t.SenderName (string) (string)
@@ -1026,6 +1391,27 @@ func (t *Alert) UnmarshalCBOR(r io.Reader) (err error) {
 
 				t.MessageType = string(sval)
 			}
+			// t.EasEventCode (string) (string)
+		case "easEventCode":
+
+			{
+				b, err := cr.ReadByte()
+				if err != nil {
+					return err
+				}
+				if b != cbg.CborNull[0] {
+					if err := cr.UnreadByte(); err != nil {
+						return err
+					}
+
+					sval, err := cbg.ReadStringWithMax(cr, 1000000)
+					if err != nil {
+						return err
+					}
+
+					t.EasEventCode = (*string)(&sval)
+				}
+			}
 			// t.AffectedZones ([]string) (slice)
 		case "affectedZones":
 
@@ -1066,6 +1452,804 @@ func (t *Alert) UnmarshalCBOR(r io.Reader) (err error) {
 
 				}
 			}
+			// t.Localizations ([]*bsky.Alert_Localization) (slice)
+		case "localizations":
+
+			maj, extra, err = cr.ReadHeader()
+			if err != nil {
+				return err
+			}
+
+			if extra > 8192 {
+				return fmt.Errorf("t.Localizations: array too large (%d)", extra)
+			}
+
+			if maj != cbg.MajArray {
+				return fmt.Errorf("expected cbor array")
+			}
+
+			if extra > 0 {
+				t.Localizations = make([]*Alert_Localization, extra)
+			}
+
+			for i := 0; i < int(extra); i++ {
+				{
+					var maj byte
+					var extra uint64
+					var err error
+					_ = maj
+					_ = extra
+					_ = err
+
+					{
+
+						b, err := cr.ReadByte()
+						if err != nil {
+							return err
+						}
+						if b != cbg.CborNull[0] {
+							if err := cr.UnreadByte(); err != nil {
+								return err
+							}
+							t.Localizations[i] = new(Alert_Localization)
+							if err := t.Localizations[i].UnmarshalCBOR(cr); err != nil {
+								return xerrors.Errorf("unmarshaling t.Localizations[i] pointer: %w", err)
+							}
+						}
+
+					}
+
+				}
+			}
+			// t.EventMotionDescription (string) (string)
+		case "eventMotionDescription":
+
+			{
+				b, err := cr.ReadByte()
+				if err != nil {
+					return err
+				}
+				if b != cbg.CborNull[0] {
+					if err := cr.UnreadByte(); err != nil {
+						return err
+					}
+
+					sval, err := cbg.ReadStringWithMax(cr, 1000000)
+					if err != nil {
+						return err
+					}
+
+					t.EventMotionDescription = (*string)(&sval)
+				}
+			}
+
+		default:
+			// Field doesn't exist on this type, so ignore it
+			cbg.ScanForLinks(r, func(cid.Cid) {})
+		}
+	}
+
+	return nil
+}
+func (t *Alert_Hazards) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+
+	cw := cbg.NewCborWriter(w)
+	fieldCount := 7
+
+	if t.FlashFloodDamageThreat == nil {
+		fieldCount--
+	}
+
+	if t.FlashFloodDetection == nil {
+		fieldCount--
+	}
+
+	if t.MaxHailSize == nil {
+		fieldCount--
+	}
+
+	if t.MaxWindGust == nil {
+		fieldCount--
+	}
+
+	if t.ThunderstormDamageThreat == nil {
+		fieldCount--
+	}
+
+	if t.TornadoDamageThreat == nil {
+		fieldCount--
+	}
+
+	if t.TornadoDetection == nil {
+		fieldCount--
+	}
+
+	if _, err := cw.Write(cbg.CborEncodeMajorType(cbg.MajMap, uint64(fieldCount))); err != nil {
+		return err
+	}
+
+	// t.MaxHailSize (string) (string)
+	if t.MaxHailSize != nil {
+
+		if len("maxHailSize") > 1000000 {
+			return xerrors.Errorf("Value in field \"maxHailSize\" was too long")
+		}
+
+		if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("maxHailSize"))); err != nil {
+			return err
+		}
+		if _, err := cw.WriteString(string("maxHailSize")); err != nil {
+			return err
+		}
+
+		if t.MaxHailSize == nil {
+			if _, err := cw.Write(cbg.CborNull); err != nil {
+				return err
+			}
+		} else {
+			if len(*t.MaxHailSize) > 1000000 {
+				return xerrors.Errorf("Value in field t.MaxHailSize was too long")
+			}
+
+			if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(*t.MaxHailSize))); err != nil {
+				return err
+			}
+			if _, err := cw.WriteString(string(*t.MaxHailSize)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// t.MaxWindGust (string) (string)
+	if t.MaxWindGust != nil {
+
+		if len("maxWindGust") > 1000000 {
+			return xerrors.Errorf("Value in field \"maxWindGust\" was too long")
+		}
+
+		if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("maxWindGust"))); err != nil {
+			return err
+		}
+		if _, err := cw.WriteString(string("maxWindGust")); err != nil {
+			return err
+		}
+
+		if t.MaxWindGust == nil {
+			if _, err := cw.Write(cbg.CborNull); err != nil {
+				return err
+			}
+		} else {
+			if len(*t.MaxWindGust) > 1000000 {
+				return xerrors.Errorf("Value in field t.MaxWindGust was too long")
+			}
+
+			if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(*t.MaxWindGust))); err != nil {
+				return err
+			}
+			if _, err := cw.WriteString(string(*t.MaxWindGust)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// t.TornadoDetection (string) (string)
+	if t.TornadoDetection != nil {
+
+		if len("tornadoDetection") > 1000000 {
+			return xerrors.Errorf("Value in field \"tornadoDetection\" was too long")
+		}
+
+		if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("tornadoDetection"))); err != nil {
+			return err
+		}
+		if _, err := cw.WriteString(string("tornadoDetection")); err != nil {
+			return err
+		}
+
+		if t.TornadoDetection == nil {
+			if _, err := cw.Write(cbg.CborNull); err != nil {
+				return err
+			}
+		} else {
+			if len(*t.TornadoDetection) > 1000000 {
+				return xerrors.Errorf("Value in field t.TornadoDetection was too long")
+			}
+
+			if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(*t.TornadoDetection))); err != nil {
+				return err
+			}
+			if _, err := cw.WriteString(string(*t.TornadoDetection)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// t.FlashFloodDetection (string) (string)
+	if t.FlashFloodDetection != nil {
+
+		if len("flashFloodDetection") > 1000000 {
+			return xerrors.Errorf("Value in field \"flashFloodDetection\" was too long")
+		}
+
+		if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("flashFloodDetection"))); err != nil {
+			return err
+		}
+		if _, err := cw.WriteString(string("flashFloodDetection")); err != nil {
+			return err
+		}
+
+		if t.FlashFloodDetection == nil {
+			if _, err := cw.Write(cbg.CborNull); err != nil {
+				return err
+			}
+		} else {
+			if len(*t.FlashFloodDetection) > 1000000 {
+				return xerrors.Errorf("Value in field t.FlashFloodDetection was too long")
+			}
+
+			if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(*t.FlashFloodDetection))); err != nil {
+				return err
+			}
+			if _, err := cw.WriteString(string(*t.FlashFloodDetection)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// t.TornadoDamageThreat (string) (string)
+	if t.TornadoDamageThreat != nil {
+
+		if len("tornadoDamageThreat") > 1000000 {
+			return xerrors.Errorf("Value in field \"tornadoDamageThreat\" was too long")
+		}
+
+		if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("tornadoDamageThreat"))); err != nil {
+			return err
+		}
+		if _, err := cw.WriteString(string("tornadoDamageThreat")); err != nil {
+			return err
+		}
+
+		if t.TornadoDamageThreat == nil {
+			if _, err := cw.Write(cbg.CborNull); err != nil {
+				return err
+			}
+		} else {
+			if len(*t.TornadoDamageThreat) > 1000000 {
+				return xerrors.Errorf("Value in field t.TornadoDamageThreat was too long")
+			}
+
+			if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(*t.TornadoDamageThreat))); err != nil {
+				return err
+			}
+			if _, err := cw.WriteString(string(*t.TornadoDamageThreat)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// t.FlashFloodDamageThreat (string) (string)
+	if t.FlashFloodDamageThreat != nil {
+
+		if len("flashFloodDamageThreat") > 1000000 {
+			return xerrors.Errorf("Value in field \"flashFloodDamageThreat\" was too long")
+		}
+
+		if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("flashFloodDamageThreat"))); err != nil {
+			return err
+		}
+		if _, err := cw.WriteString(string("flashFloodDamageThreat")); err != nil {
+			return err
+		}
+
+		if t.FlashFloodDamageThreat == nil {
+			if _, err := cw.Write(cbg.CborNull); err != nil {
+				return err
+			}
+		} else {
+			if len(*t.FlashFloodDamageThreat) > 1000000 {
+				return xerrors.Errorf("Value in field t.FlashFloodDamageThreat was too long")
+			}
+
+			if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(*t.FlashFloodDamageThreat))); err != nil {
+				return err
+			}
+			if _, err := cw.WriteString(string(*t.FlashFloodDamageThreat)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// t.ThunderstormDamageThreat (string) (string)
+	if t.ThunderstormDamageThreat != nil {
+
+		if len("thunderstormDamageThreat") > 1000000 {
+			return xerrors.Errorf("Value in field \"thunderstormDamageThreat\" was too long")
+		}
+
+		if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("thunderstormDamageThreat"))); err != nil {
+			return err
+		}
+		if _, err := cw.WriteString(string("thunderstormDamageThreat")); err != nil {
+			return err
+		}
+
+		if t.ThunderstormDamageThreat == nil {
+			if _, err := cw.Write(cbg.CborNull); err != nil {
+				return err
+			}
+		} else {
+			if len(*t.ThunderstormDamageThreat) > 1000000 {
+				return xerrors.Errorf("Value in field t.ThunderstormDamageThreat was too long")
+			}
+
+			if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(*t.ThunderstormDamageThreat))); err != nil {
+				return err
+			}
+			if _, err := cw.WriteString(string(*t.ThunderstormDamageThreat)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (t *Alert_Hazards) UnmarshalCBOR(r io.Reader) (err error) {
+	*t = Alert_Hazards{}
+
+	cr := cbg.NewCborReader(r)
+
+	maj, extra, err := cr.ReadHeader()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+	}()
+
+	if maj != cbg.MajMap {
+		return fmt.Errorf("cbor input should be of type map")
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("Alert_Hazards: map struct too large (%d)", extra)
+	}
+
+	var name string
+	n := extra
+
+	for i := uint64(0); i < n; i++ {
+
+		{
+			sval, err := cbg.ReadStringWithMax(cr, 1000000)
+			if err != nil {
+				return err
+			}
+
+			name = string(sval)
+		}
+
+		switch name {
+		// t.MaxHailSize (string) (string)
+		case "maxHailSize":
+
+			{
+				b, err := cr.ReadByte()
+				if err != nil {
+					return err
+				}
+				if b != cbg.CborNull[0] {
+					if err := cr.UnreadByte(); err != nil {
+						return err
+					}
+
+					sval, err := cbg.ReadStringWithMax(cr, 1000000)
+					if err != nil {
+						return err
+					}
+
+					t.MaxHailSize = (*string)(&sval)
+				}
+			}
+			// t.MaxWindGust (string) (string)
+		case "maxWindGust":
+
+			{
+				b, err := cr.ReadByte()
+				if err != nil {
+					return err
+				}
+				if b != cbg.CborNull[0] {
+					if err := cr.UnreadByte(); err != nil {
+						return err
+					}
+
+					sval, err := cbg.ReadStringWithMax(cr, 1000000)
+					if err != nil {
+						return err
+					}
+
+					t.MaxWindGust = (*string)(&sval)
+				}
+			}
+			// t.TornadoDetection (string) (string)
+		case "tornadoDetection":
+
+			{
+				b, err := cr.ReadByte()
+				if err != nil {
+					return err
+				}
+				if b != cbg.CborNull[0] {
+					if err := cr.UnreadByte(); err != nil {
+						return err
+					}
+
+					sval, err := cbg.ReadStringWithMax(cr, 1000000)
+					if err != nil {
+						return err
+					}
+
+					t.TornadoDetection = (*string)(&sval)
+				}
+			}
+			// t.FlashFloodDetection (string) (string)
+		case "flashFloodDetection":
+
+			{
+				b, err := cr.ReadByte()
+				if err != nil {
+					return err
+				}
+				if b != cbg.CborNull[0] {
+					if err := cr.UnreadByte(); err != nil {
+						return err
+					}
+
+					sval, err := cbg.ReadStringWithMax(cr, 1000000)
+					if err != nil {
+						return err
+					}
+
+					t.FlashFloodDetection = (*string)(&sval)
+				}
+			}
+			// t.TornadoDamageThreat (string) (string)
+		case "tornadoDamageThreat":
+
+			{
+				b, err := cr.ReadByte()
+				if err != nil {
+					return err
+				}
+				if b != cbg.CborNull[0] {
+					if err := cr.UnreadByte(); err != nil {
+						return err
+					}
+
+					sval, err := cbg.ReadStringWithMax(cr, 1000000)
+					if err != nil {
+						return err
+					}
+
+					t.TornadoDamageThreat = (*string)(&sval)
+				}
+			}
+			// t.FlashFloodDamageThreat (string) (string)
+		case "flashFloodDamageThreat":
+
+			{
+				b, err := cr.ReadByte()
+				if err != nil {
+					return err
+				}
+				if b != cbg.CborNull[0] {
+					if err := cr.UnreadByte(); err != nil {
+						return err
+					}
+
+					sval, err := cbg.ReadStringWithMax(cr, 1000000)
+					if err != nil {
+						return err
+					}
+
+					t.FlashFloodDamageThreat = (*string)(&sval)
+				}
+			}
+			// t.ThunderstormDamageThreat (string) (string)
+		case "thunderstormDamageThreat":
+
+			{
+				b, err := cr.ReadByte()
+				if err != nil {
+					return err
+				}
+				if b != cbg.CborNull[0] {
+					if err := cr.UnreadByte(); err != nil {
+						return err
+					}
+
+					sval, err := cbg.ReadStringWithMax(cr, 1000000)
+					if err != nil {
+						return err
+					}
+
+					t.ThunderstormDamageThreat = (*string)(&sval)
+				}
+			}
+
+		default:
+			// Field doesn't exist on this type, so ignore it
+			cbg.ScanForLinks(r, func(cid.Cid) {})
+		}
+	}
+
+	return nil
+}
+func (t *Alert_Localization) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+
+	cw := cbg.NewCborWriter(w)
+	fieldCount := 4
+
+	if t.Description == nil {
+		fieldCount--
+	}
+
+	if t.Headline == nil {
+		fieldCount--
+	}
+
+	if t.Instruction == nil {
+		fieldCount--
+	}
+
+	if _, err := cw.Write(cbg.CborEncodeMajorType(cbg.MajMap, uint64(fieldCount))); err != nil {
+		return err
+	}
+
+	// t.Headline (string) (string)
+	if t.Headline != nil {
+
+		if len("headline") > 1000000 {
+			return xerrors.Errorf("Value in field \"headline\" was too long")
+		}
+
+		if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("headline"))); err != nil {
+			return err
+		}
+		if _, err := cw.WriteString(string("headline")); err != nil {
+			return err
+		}
+
+		if t.Headline == nil {
+			if _, err := cw.Write(cbg.CborNull); err != nil {
+				return err
+			}
+		} else {
+			if len(*t.Headline) > 1000000 {
+				return xerrors.Errorf("Value in field t.Headline was too long")
+			}
+
+			if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(*t.Headline))); err != nil {
+				return err
+			}
+			if _, err := cw.WriteString(string(*t.Headline)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// t.Language (string) (string)
+	if len("language") > 1000000 {
+		return xerrors.Errorf("Value in field \"language\" was too long")
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("language"))); err != nil {
+		return err
+	}
+	if _, err := cw.WriteString(string("language")); err != nil {
+		return err
+	}
+
+	if len(t.Language) > 1000000 {
+		return xerrors.Errorf("Value in field t.Language was too long")
+	}
+
+	if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(t.Language))); err != nil {
+		return err
+	}
+	if _, err := cw.WriteString(string(t.Language)); err != nil {
+		return err
+	}
+
+	// t.Description (string) (string)
+	if t.Description != nil {
+
+		if len("description") > 1000000 {
+			return xerrors.Errorf("Value in field \"description\" was too long")
+		}
+
+		if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("description"))); err != nil {
+			return err
+		}
+		if _, err := cw.WriteString(string("description")); err != nil {
+			return err
+		}
+
+		if t.Description == nil {
+			if _, err := cw.Write(cbg.CborNull); err != nil {
+				return err
+			}
+		} else {
+			if len(*t.Description) > 1000000 {
+				return xerrors.Errorf("Value in field t.Description was too long")
+			}
+
+			if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(*t.Description))); err != nil {
+				return err
+			}
+			if _, err := cw.WriteString(string(*t.Description)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// t.Instruction (string) (string)
+	if t.Instruction != nil {
+
+		if len("instruction") > 1000000 {
+			return xerrors.Errorf("Value in field \"instruction\" was too long")
+		}
+
+		if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len("instruction"))); err != nil {
+			return err
+		}
+		if _, err := cw.WriteString(string("instruction")); err != nil {
+			return err
+		}
+
+		if t.Instruction == nil {
+			if _, err := cw.Write(cbg.CborNull); err != nil {
+				return err
+			}
+		} else {
+			if len(*t.Instruction) > 1000000 {
+				return xerrors.Errorf("Value in field t.Instruction was too long")
+			}
+
+			if err := cw.WriteMajorTypeHeader(cbg.MajTextString, uint64(len(*t.Instruction))); err != nil {
+				return err
+			}
+			if _, err := cw.WriteString(string(*t.Instruction)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (t *Alert_Localization) UnmarshalCBOR(r io.Reader) (err error) {
+	*t = Alert_Localization{}
+
+	cr := cbg.NewCborReader(r)
+
+	maj, extra, err := cr.ReadHeader()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+	}()
+
+	if maj != cbg.MajMap {
+		return fmt.Errorf("cbor input should be of type map")
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("Alert_Localization: map struct too large (%d)", extra)
+	}
+
+	var name string
+	n := extra
+
+	for i := uint64(0); i < n; i++ {
+
+		{
+			sval, err := cbg.ReadStringWithMax(cr, 1000000)
+			if err != nil {
+				return err
+			}
+
+			name = string(sval)
+		}
+
+		switch name {
+		// t.Headline (string) (string)
+		case "headline":
+
+			{
+				b, err := cr.ReadByte()
+				if err != nil {
+					return err
+				}
+				if b != cbg.CborNull[0] {
+					if err := cr.UnreadByte(); err != nil {
+						return err
+					}
+
+					sval, err := cbg.ReadStringWithMax(cr, 1000000)
+					if err != nil {
+						return err
+					}
+
+					t.Headline = (*string)(&sval)
+				}
+			}
+			// t.Language (string) (string)
+		case "language":
+
+			{
+				sval, err := cbg.ReadStringWithMax(cr, 1000000)
+				if err != nil {
+					return err
+				}
+
+				t.Language = string(sval)
+			}
+			// t.Description (string) (string)
+		case "description":
+
+			{
+				b, err := cr.ReadByte()
+				if err != nil {
+					return err
+				}
+				if b != cbg.CborNull[0] {
+					if err := cr.UnreadByte(); err != nil {
+						return err
+					}
+
+					sval, err := cbg.ReadStringWithMax(cr, 1000000)
+					if err != nil {
+						return err
+					}
+
+					t.Description = (*string)(&sval)
+				}
+			}
+			// t.Instruction (string) (string)
+		case "instruction":
+
+			{
+				b, err := cr.ReadByte()
+				if err != nil {
+					return err
+				}
+				if b != cbg.CborNull[0] {
+					if err := cr.UnreadByte(); err != nil {
+						return err
+					}
+
+					sval, err := cbg.ReadStringWithMax(cr, 1000000)
+					if err != nil {
+						return err
+					}
+
+					t.Instruction = (*string)(&sval)
+				}
+			}
 
 		default:
 			// Field doesn't exist on this type, so ignore it
@@ -19,6 +19,7 @@ import (
 	"github.com/jghiloni/watchedsky-social/backend/features"
 	"github.com/jghiloni/watchedsky-social/backend/geojson"
 	"github.com/jghiloni/watchedsky-social/backend/utils"
+	"github.com/rivo/uniseg"
 )
 
 const AlertCollection = "social.watchedsky.alert"
@@ -186,19 +187,25 @@ func (c *BlueskyClient) GetLatestID(ctx context.Context) (string, error) {
 	return "", fmt.Errorf("expected record to be an *atproto.Alert, but it was %T", alertVal)
 }
 
-func (c *BlueskyClient) SkeetAlert(ctx context.Context, a *Alert) error {
+// SkeetAlert posts a short announcement of an alert, returning the AT URI of
+// the new post. diff is what changed since the previous version of the
+// alert, and may be nil
+func (c *BlueskyClient) SkeetAlert(ctx context.Context, a *Alert, diff *features.AlertDiff) (string, error) {
 	me := c.Me()
 	if me == nil {
-		return errors.New("requires auth")
+		return "", errors.New("requires auth")
 	}
 
 	cfg := config.GetConfig(ctx)
 
+	lang := utils.Coalesce(deref(a.Language), features.DefaultLanguage)
 	webURL := fmt.Sprintf("%s/alert/%s", cfg.BaseURL, a.Id)
-	msg := fmt.Sprintf("%s Weather Alert: %s. See more at %s", strings.ToUpper(a.Severity), a.Headline, webURL)
+	msg := AlertPostText(a, diff, webURL)
+
 	post := bsky.FeedPost{
 		CreatedAt: time.Now().Format(time.RFC3339),
 		Text:      msg,
+		Langs:     []string{lang},
 	}
 
 	// The last word is a link
@@ -208,7 +215,7 @@ func (c *BlueskyClient) SkeetAlert(ctx context.Context, a *Alert) error {
 			{
 				Index: &bsky.RichtextFacet_ByteSlice{
 					ByteStart: int64(startIdx),
-					ByteEnd:   int64(len(msg) - 1),
+					ByteEnd:   int64(startIdx + len(webURL)),
 				},
 				Features: []*bsky.RichtextFacet_Features_Elem{
 					{
@@ -221,15 +228,121 @@ func (c *BlueskyClient) SkeetAlert(ctx context.Context, a *Alert) error {
 		}
 	}
 
-	_, err := atproto.RepoCreateRecord(ctx, c.xc, &atproto.RepoCreateRecord_Input{
+	out, err := atproto.RepoCreateRecord(ctx, c.xc, &atproto.RepoCreateRecord_Input{
 		Collection: "app.bsky.feed.post",
 		Repo:       me.Did,
 		Record: &lexutil.LexiconTypeDecoder{
 			Val: &post,
 		},
 	})
+	if err != nil {
+		return "", err
+	}
 
-	return err
+	return out.Uri, nil
+}
+
+// MaxPostGraphemes is the longest post text Bluesky accepts
+const MaxPostGraphemes = 300
+
+// AlertPostText writes the text of a post announcing an alert, ending with
+// a link to webURL. diff may be nil. Text that doesn't fit in
+// MaxPostGraphemes is left out: first the hazard summary, then the list of
+// changes, and then the end of the headline
+func AlertPostText(a *Alert, diff *features.AlertDiff, webURL string) string {
+	lang := utils.Coalesce(deref(a.Language), features.DefaultLanguage)
+	text := postTextFor(lang)
+
+	headline := fmt.Sprintf("%s %s: %s. ", strings.ToUpper(a.Severity), text.weatherAlert, a.Headline)
+	link := text.seeMore + " " + webURL
+
+	kind, changes := "", ""
+	if diff != nil {
+		kind = text.updated
+		if strings.EqualFold(diff.MessageType, "Cancel") {
+			kind = text.cancelled
+		}
+
+		changes = kind + ". "
+
+		// change and hazard summaries are only written in English
+		if summary := diff.Summary(); summary != "" && text.english {
+			changes = fmt.Sprintf("%s: %s. ", kind, summary)
+		}
+	}
+
+	hazards := ""
+	if summary := a.ParseHazards().Summary(); summary != "" && text.english {
+		hazards = summary + ". "
+	}
+
+	shortChanges := ""
+	if kind != "" {
+		shortChanges = kind + ". "
+	}
+
+	for _, body := range []string{changes + hazards, changes, shortChanges} {
+		if msg := headline + body + link; uniseg.GraphemeClusterCount(msg) <= MaxPostGraphemes {
+			return msg
+		}
+	}
+
+	// even the headline alone is too long, so it is cut short
+	room := MaxPostGraphemes - uniseg.GraphemeClusterCount(shortChanges+link)
+	return truncateGraphemes(headline, room) + shortChanges + link
+}
+
+// truncateGraphemes shortens s to at most n grapheme clusters, ending it
+// with an ellipsis if anything was cut
+func truncateGraphemes(s string, n int) string {
+	if uniseg.GraphemeClusterCount(s) <= n {
+		return s
+	}
+
+	// room for the ellipsis and the space after it
+	n -= 2
+	out := strings.Builder{}
+	g := uniseg.NewGraphemes(s)
+	for i := 0; i < n && g.Next(); i++ {
+		out.WriteString(g.Str())
+	}
+
+	return strings.TrimRight(out.String(), " ") + "… "
+}
+
+// postText is the fixed text of a post in one language
+type postText struct {
+	english      bool
+	weatherAlert string
+	updated      string
+	cancelled    string
+	seeMore      string
+}
+
+var postTexts = map[string]postText{
+	"en": {
+		english:      true,
+		weatherAlert: "Weather Alert",
+		updated:      "Updated",
+		cancelled:    "Cancelled",
+		seeMore:      "See more at",
+	},
+	"es": {
+		weatherAlert: "Alerta meteorológica",
+		updated:      "Actualizada",
+		cancelled:    "Cancelada",
+		seeMore:      "Más información en",
+	},
+}
+
+// postTextFor returns the fixed text of a post in lang, falling back to
+// English
+func postTextFor(lang string) postText {
+	if text, ok := postTexts[features.BaseLanguage(lang)]; ok {
+		return text
+	}
+
+	return postTexts["en"]
 }
 
 func mergeConfigs(configs []BlueskyClientConfig) BlueskyClientConfig {
@@ -0,0 +1,52 @@
+package bsky_test
+
+import (
+	"strings"
+
+	"github.com/jghiloni/watchedsky-social/backend/bsky"
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rivo/uniseg"
+)
+
+var _ = Describe("Alert posts", func() {
+	const webURL = "https://watchedsky.social/alert/urn:oid:2.49.0.1.840.0.1"
+
+	alert := func(headline string) *bsky.Alert {
+		return &bsky.Alert{
+			Id:       "urn:oid:2.49.0.1.840.0.1",
+			Severity: "Severe",
+			Headline: headline,
+		}
+	}
+
+	It("Writes the headline, what changed and a link", func() {
+		diff := &features.AlertDiff{MessageType: "Update", AreaAdded: true}
+		text := bsky.AlertPostText(alert("Tornado Warning issued June 5"), diff, webURL)
+		Expect(text).To(Equal("SEVERE Weather Alert: Tornado Warning issued June 5. Updated: area expanded. See more at " + webURL))
+	})
+
+	It("Cuts a long headline short to fit Bluesky's limit", func() {
+		text := bsky.AlertPostText(alert(strings.Repeat("Tornado Warning ", 40)), nil, webURL)
+		Expect(uniseg.GraphemeClusterCount(text)).To(BeNumerically("<=", bsky.MaxPostGraphemes))
+		Expect(text).To(ContainSubstring("… See more at "))
+		Expect(text).To(HaveSuffix(webURL))
+	})
+
+	It("Counts graphemes rather than bytes or runes", func() {
+		// each flag is two runes and eight bytes, but one grapheme
+		headline := strings.Repeat("🇺🇸", 250)
+		text := bsky.AlertPostText(alert(headline), nil, webURL)
+		Expect(uniseg.GraphemeClusterCount(text)).To(Equal(bsky.MaxPostGraphemes))
+		Expect(text).To(HavePrefix("SEVERE Weather Alert: 🇺🇸"))
+	})
+
+	It("Leaves out what changed before cutting the headline", func() {
+		headline := strings.Repeat("a", 190)
+		diff := &features.AlertDiff{MessageType: "Update", AreaAdded: true, AreaRemoved: true, TextChanged: []string{"description"}}
+		text := bsky.AlertPostText(alert(headline), diff, webURL)
+		Expect(uniseg.GraphemeClusterCount(text)).To(BeNumerically("<=", bsky.MaxPostGraphemes))
+		Expect(text).To(ContainSubstring(headline + ". Updated. See more at "))
+	})
+})
@@ -6,7 +6,7 @@ import (
 
 	"github.com/jghiloni/watchedsky-social/backend/features"
 	"github.com/jghiloni/watchedsky-social/backend/geojson"
-	"github.com/jghiloni/watchedsky-social/backend/mongo"
+	"github.com/jghiloni/watchedsky-social/backend/store"
 	"github.com/jghiloni/watchedsky-social/backend/utils"
 )
 
@@ -38,6 +38,49 @@ func (a Alert) ToFeature(ctx context.Context) (features.Feature, error) {
 		},
 	}
 
+	params := a.Hazards.parameters()
+	if a.EventMotionDescription != nil {
+		params["eventMotionDescription"] = []any{*a.EventMotionDescription}
+	}
+
+	if len(params) > 0 {
+		f.Properties["parameters"] = params
+	}
+	f.Properties["hazards"] = f.Hazards()
+
+	if motion, err := f.StormMotion(); err == nil {
+		f.Properties["stormMotion"] = motion.Project()
+	}
+
+	if len(a.References) > 0 {
+		f.Properties["references"] = utils.Map(a.References, func(id string) any {
+			return map[string]any{"identifier": id}
+		})
+	}
+
+	if len(a.SameCodes) > 0 {
+		f.Properties["geocode"] = map[string]any{"SAME": utils.AnySlice(a.SameCodes)}
+	}
+
+	if a.EasEventCode != nil {
+		f.Properties["eventCode"] = map[string]any{"SAME": []any{*a.EasEventCode}}
+	}
+
+	if a.Language != nil {
+		f.Properties["language"] = *a.Language
+	}
+
+	if len(a.Localizations) > 0 {
+		f.Properties["localizations"] = utils.Map(a.Localizations, func(l *Alert_Localization) features.Localization {
+			return features.Localization{
+				Language:    l.Language,
+				Headline:    deref(l.Headline),
+				Description: deref(l.Description),
+				Instruction: deref(l.Instruction),
+			}
+		})
+	}
+
 	var err error
 	f.Geometry, err = a.hydrateFeatureGeometry(ctx)
 	return f, err
@@ -58,7 +101,7 @@ func (a Alert) hydrateFeatureGeometry(ctx context.Context) (geojson.Geometry, er
 
 	geos = append(geos, alertGeo)
 
-	dbClient := mongo.GetClient(ctx)
+	dbClient := store.GetStore(ctx)
 	if dbClient != nil {
 		azs, err := dbClient.GetFeaturesByID(ctx, a.AffectedZones...)
 		if err != nil {
@@ -71,7 +114,7 @@ func (a Alert) hydrateFeatureGeometry(ctx context.Context) (geojson.Geometry, er
 	}
 
 	if len(geos) > 0 {
-		return geojson.GeometryCollection{Geometries: geos}, nil
+		return geojson.GeometryCollection{GT: geojson.GeometryCollectionType, Geometries: geos}, nil
 	}
 
 	return nil, nil
@@ -80,7 +123,7 @@ func (a Alert) hydrateFeatureGeometry(ctx context.Context) (geojson.Geometry, er
 func FromFeature(f features.Feature) Alert {
 	a := Alert{
 		Id:            f.Properties.StringValue("id"),
-		AffectedZones: utils.FromAnySlice[string](f.Properties["affectedZones"].([]any)),
+		AffectedZones: f.AffectedZones(),
 		Certainty:     f.Properties.StringValue("certainty"),
 		Description:   f.Properties.StringValue("description"),
 		Effective:     f.Properties.StringValue("effective"),
@@ -93,6 +136,17 @@ func FromFeature(f features.Feature) Alert {
 		Severity:      f.Properties.StringValue("severity"),
 		Status:        f.Properties.StringValue("status"),
 		Urgency:       f.Properties.StringValue("urgency"),
+		SameCodes:     f.SAMECodes(),
+		References:    f.References(),
+		Hazards:       hazardsFromFeature(f),
+	}
+
+	if motion := f.Parameter("eventMotionDescription"); motion != "" {
+		a.EventMotionDescription = utils.Ptr(motion)
+	}
+
+	if eventCode := f.EASEventCode(); eventCode != "" {
+		a.EasEventCode = utils.Ptr(eventCode)
 	}
 
 	if strEnds := f.Properties.StringValue("ends"); strEnds != "" {
@@ -119,5 +173,92 @@ func FromFeature(f features.Feature) Alert {
 		a.ReplacedBy = utils.Ptr(strReplacedBy)
 	}
 
+	a.Language = utils.Ptr(f.Language())
+	if locs := f.Localizations(); len(locs) > 1 {
+		a.Localizations = utils.Map(locs[1:], func(l features.Localization) *Alert_Localization {
+			return &Alert_Localization{
+				Language:    l.Language,
+				Headline:    optional(l.Headline),
+				Description: optional(l.Description),
+				Instruction: optional(l.Instruction),
+			}
+		})
+	}
+
 	return a
 }
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return &s
+}
+
+func (h *Alert_Hazards) fields() map[string]**string {
+	return map[string]**string{
+		"maxWindGust":              &h.MaxWindGust,
+		"maxHailSize":              &h.MaxHailSize,
+		"tornadoDetection":         &h.TornadoDetection,
+		"tornadoDamageThreat":      &h.TornadoDamageThreat,
+		"thunderstormDamageThreat": &h.ThunderstormDamageThreat,
+		"flashFloodDetection":      &h.FlashFloodDetection,
+		"flashFloodDamageThreat":   &h.FlashFloodDamageThreat,
+	}
+}
+
+// parameters converts the hazards back into NWS style alert parameters
+func (h *Alert_Hazards) parameters() map[string]any {
+	params := map[string]any{}
+	if h == nil {
+		return params
+	}
+
+	for name, field := range h.fields() {
+		if *field != nil {
+			params[name] = []any{**field}
+		}
+	}
+
+	return params
+}
+
+func hazardsFromFeature(f features.Feature) *Alert_Hazards {
+	h := &Alert_Hazards{}
+	found := false
+	for name, field := range h.fields() {
+		if value := f.Parameter(name); value != "" {
+			*field = utils.Ptr(value)
+			found = true
+		}
+	}
+
+	if !found {
+		return nil
+	}
+
+	return h
+}
+
+// ParseHazards extracts the structured hazards of the alert
+func (a Alert) ParseHazards() features.Hazards {
+	params := map[string]string{}
+	if a.Hazards != nil {
+		for name, field := range a.Hazards.fields() {
+			if *field != nil {
+				params[name] = **field
+			}
+		}
+	}
+
+	return features.ParseHazards(a.Event, a.Description, params)
+}
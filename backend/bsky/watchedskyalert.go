@@ -13,27 +13,63 @@ func init() {
 } //
 // RECORDTYPE: Alert
 type Alert struct {
-	LexiconTypeID string        `json:"$type,const=social.watchedsky.alert" cborgen:"$type,const=social.watchedsky.alert"`
-	AffectedZones []string      `json:"affectedZones,omitempty" cborgen:"affectedZones,omitempty"`
-	AreaDesc      *string       `json:"areaDesc,omitempty" cborgen:"areaDesc,omitempty"`
-	Certainty     string        `json:"certainty" cborgen:"certainty"`
-	Description   string        `json:"description" cborgen:"description"`
-	Effective     string        `json:"effective" cborgen:"effective"`
-	Ends          *string       `json:"ends,omitempty" cborgen:"ends,omitempty"`
-	Event         string        `json:"event" cborgen:"event"`
-	Expires       *string       `json:"expires,omitempty" cborgen:"expires,omitempty"`
-	Geometry      *util.LexBlob `json:"geometry,omitempty" cborgen:"geometry,omitempty"`
-	Headline      string        `json:"headline" cborgen:"headline"`
-	Id            string        `json:"id" cborgen:"id"`
-	Instruction   *string       `json:"instruction,omitempty" cborgen:"instruction,omitempty"`
-	MessageType   string        `json:"messageType" cborgen:"messageType"`
-	Onset         *string       `json:"onset,omitempty" cborgen:"onset,omitempty"`
-	ReplacedAt    *string       `json:"replacedAt,omitempty" cborgen:"replacedAt,omitempty"`
-	ReplacedBy    *string       `json:"replacedBy,omitempty" cborgen:"replacedBy,omitempty"`
-	Sender        string        `json:"sender" cborgen:"sender"`
-	SenderName    string        `json:"senderName" cborgen:"senderName"`
-	Sent          string        `json:"sent" cborgen:"sent"`
-	Severity      string        `json:"severity" cborgen:"severity"`
-	Status        string        `json:"status" cborgen:"status"`
-	Urgency       string        `json:"urgency" cborgen:"urgency"`
+	LexiconTypeID string   `json:"$type,const=social.watchedsky.alert" cborgen:"$type,const=social.watchedsky.alert"`
+	AffectedZones []string `json:"affectedZones,omitempty" cborgen:"affectedZones,omitempty"`
+	AreaDesc      *string  `json:"areaDesc,omitempty" cborgen:"areaDesc,omitempty"`
+	Certainty     string   `json:"certainty" cborgen:"certainty"`
+	Description   string   `json:"description" cborgen:"description"`
+	// easEventCode: Three letter EAS event code, e.g. TOR
+	EasEventCode *string `json:"easEventCode,omitempty" cborgen:"easEventCode,omitempty"`
+	Effective    string  `json:"effective" cborgen:"effective"`
+	Ends         *string `json:"ends,omitempty" cborgen:"ends,omitempty"`
+	Event        string  `json:"event" cborgen:"event"`
+	// eventMotionDescription: Storm motion as sent by the NWS, e.g. 2024-06-05T21:42:00-00:00...storm...235DEG...30KT...4120 8810
+	EventMotionDescription *string        `json:"eventMotionDescription,omitempty" cborgen:"eventMotionDescription,omitempty"`
+	Expires                *string        `json:"expires,omitempty" cborgen:"expires,omitempty"`
+	Geometry               *util.LexBlob  `json:"geometry,omitempty" cborgen:"geometry,omitempty"`
+	Hazards                *Alert_Hazards `json:"hazards,omitempty" cborgen:"hazards,omitempty"`
+	Headline               string         `json:"headline" cborgen:"headline"`
+	Id                     string         `json:"id" cborgen:"id"`
+	Instruction            *string        `json:"instruction,omitempty" cborgen:"instruction,omitempty"`
+	// language: Language of the headline, description and instruction, e.g. en-US
+	Language *string `json:"language,omitempty" cborgen:"language,omitempty"`
+	// localizations: The headline, description and instruction in other languages
+	Localizations []*Alert_Localization `json:"localizations,omitempty" cborgen:"localizations,omitempty"`
+	MessageType   string                `json:"messageType" cborgen:"messageType"`
+	Onset         *string               `json:"onset,omitempty" cborgen:"onset,omitempty"`
+	// references: Identifiers of the earlier versions of this alert that it updates or cancels
+	References []string `json:"references,omitempty" cborgen:"references,omitempty"`
+	ReplacedAt *string  `json:"replacedAt,omitempty" cborgen:"replacedAt,omitempty"`
+	ReplacedBy *string  `json:"replacedBy,omitempty" cborgen:"replacedBy,omitempty"`
+	// sameCodes: Six digit SAME (FIPS) geocodes of the affected area
+	SameCodes  []string `json:"sameCodes,omitempty" cborgen:"sameCodes,omitempty"`
+	Sender     string   `json:"sender" cborgen:"sender"`
+	SenderName string   `json:"senderName" cborgen:"senderName"`
+	Sent       string   `json:"sent" cborgen:"sent"`
+	Severity   string   `json:"severity" cborgen:"severity"`
+	Status     string   `json:"status" cborgen:"status"`
+	Urgency    string   `json:"urgency" cborgen:"urgency"`
+}
+
+// Alert_Hazards is a "hazards" in the social.watchedsky.alert schema.
+//
+// Hazard parameters of the alert, as sent by the NWS
+type Alert_Hazards struct {
+	FlashFloodDamageThreat   *string `json:"flashFloodDamageThreat,omitempty" cborgen:"flashFloodDamageThreat,omitempty"`
+	FlashFloodDetection      *string `json:"flashFloodDetection,omitempty" cborgen:"flashFloodDetection,omitempty"`
+	MaxHailSize              *string `json:"maxHailSize,omitempty" cborgen:"maxHailSize,omitempty"`
+	MaxWindGust              *string `json:"maxWindGust,omitempty" cborgen:"maxWindGust,omitempty"`
+	ThunderstormDamageThreat *string `json:"thunderstormDamageThreat,omitempty" cborgen:"thunderstormDamageThreat,omitempty"`
+	TornadoDamageThreat      *string `json:"tornadoDamageThreat,omitempty" cborgen:"tornadoDamageThreat,omitempty"`
+	TornadoDetection         *string `json:"tornadoDetection,omitempty" cborgen:"tornadoDetection,omitempty"`
+}
+
+// Alert_Localization is a "localization" in the social.watchedsky.alert schema.
+//
+// Text of the alert in one language
+type Alert_Localization struct {
+	Description *string `json:"description,omitempty" cborgen:"description,omitempty"`
+	Headline    *string `json:"headline,omitempty" cborgen:"headline,omitempty"`
+	Instruction *string `json:"instruction,omitempty" cborgen:"instruction,omitempty"`
+	Language    string  `json:"language" cborgen:"language"`
 }
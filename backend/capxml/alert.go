@@ -0,0 +1,112 @@
+package capxml
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+const (
+	// Namespace is the XML namespace of OASIS CAP 1.2 documents
+	Namespace = "urn:oasis:names:tc:emergency:cap:1.2"
+
+	// MIMEType is the media type of a CAP document
+	MIMEType = "application/cap+xml"
+)
+
+// Alert is the root <alert> element of a CAP 1.2 message
+type Alert struct {
+	XMLName     xml.Name `xml:"urn:oasis:names:tc:emergency:cap:1.2 alert"`
+	Identifier  string   `xml:"identifier"`
+	Sender      string   `xml:"sender"`
+	Sent        string   `xml:"sent"`
+	Status      string   `xml:"status"`
+	MsgType     string   `xml:"msgType"`
+	Source      string   `xml:"source,omitempty"`
+	Scope       string   `xml:"scope"`
+	Restriction string   `xml:"restriction,omitempty"`
+	Addresses   string   `xml:"addresses,omitempty"`
+	Codes       []string `xml:"code,omitempty"`
+	Note        string   `xml:"note,omitempty"`
+	References  string   `xml:"references,omitempty"`
+	Incidents   string   `xml:"incidents,omitempty"`
+	Info        []Info   `xml:"info"`
+}
+
+// Info is an <info> block. A single alert may carry several, usually one per
+// language
+type Info struct {
+	Language     string       `xml:"language,omitempty"`
+	Category     []string     `xml:"category"`
+	Event        string       `xml:"event"`
+	ResponseType []string     `xml:"responseType,omitempty"`
+	Urgency      string       `xml:"urgency"`
+	Severity     string       `xml:"severity"`
+	Certainty    string       `xml:"certainty"`
+	Audience     string       `xml:"audience,omitempty"`
+	EventCodes   []NamedValue `xml:"eventCode,omitempty"`
+	Effective    string       `xml:"effective,omitempty"`
+	Onset        string       `xml:"onset,omitempty"`
+	Expires      string       `xml:"expires,omitempty"`
+	SenderName   string       `xml:"senderName,omitempty"`
+	Headline     string       `xml:"headline,omitempty"`
+	Description  string       `xml:"description,omitempty"`
+	Instruction  string       `xml:"instruction,omitempty"`
+	Web          string       `xml:"web,omitempty"`
+	Contact      string       `xml:"contact,omitempty"`
+	Parameters   []NamedValue `xml:"parameter,omitempty"`
+	Resources    []Resource   `xml:"resource,omitempty"`
+	Areas        []Area       `xml:"area,omitempty"`
+}
+
+// NamedValue is the valueName/value pair used by <eventCode>, <parameter> and
+// <geocode>
+type NamedValue struct {
+	ValueName string `xml:"valueName"`
+	Value     string `xml:"value"`
+}
+
+// Resource is a <resource> block referencing supplemental content
+type Resource struct {
+	ResourceDesc string `xml:"resourceDesc"`
+	MimeType     string `xml:"mimeType"`
+	Size         int64  `xml:"size,omitempty"`
+	URI          string `xml:"uri,omitempty"`
+	DerefURI     string `xml:"derefUri,omitempty"`
+	Digest       string `xml:"digest,omitempty"`
+}
+
+// Area is an <area> block. Polygons are whitespace separated "lat,lon" pairs,
+// and circles are a "lat,lon" pair followed by a radius in kilometers
+type Area struct {
+	AreaDesc string       `xml:"areaDesc"`
+	Polygons []string     `xml:"polygon,omitempty"`
+	Circles  []string     `xml:"circle,omitempty"`
+	Geocodes []NamedValue `xml:"geocode,omitempty"`
+	Altitude string       `xml:"altitude,omitempty"`
+	Ceiling  string       `xml:"ceiling,omitempty"`
+}
+
+// Alerts is a list of CAP alerts, serialized inside an <alerts> element when
+// more than one alert is returned at once
+type Alerts struct {
+	XMLName xml.Name `xml:"alerts"`
+	Alerts  []Alert  `xml:"alert"`
+}
+
+// Decode reads a single CAP alert from r
+func Decode(r io.Reader) (Alert, error) {
+	var a Alert
+	err := xml.NewDecoder(r).Decode(&a)
+	return a, err
+}
+
+// Encode writes a as an indented XML document to w
+func Encode(w io.Writer, a any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(a)
+}
@@ -0,0 +1,13 @@
+package capxml_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestCapxml(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Capxml Suite")
+}
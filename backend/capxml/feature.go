@@ -0,0 +1,296 @@
+package capxml
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/utils"
+)
+
+// DefaultLanguage is the CAP default when an <info> block has no <language>
+const DefaultLanguage = features.DefaultLanguage
+
+// ToFeature converts a CAP alert into a wx:Alert feature, shaped like the
+// features returned by the NWS API. The first <info> block in the default
+// language is used, falling back to the first <info> block. The text of the
+// other <info> blocks is kept as localizations
+func ToFeature(a Alert) (features.Feature, error) {
+	if a.Identifier == "" {
+		return features.Feature{}, errors.New("alert identifier is required")
+	}
+
+	if len(a.Info) == 0 {
+		return features.Feature{}, fmt.Errorf("alert %s has no info blocks", a.Identifier)
+	}
+
+	info := primaryInfo(a.Info)
+
+	props := features.JSONObject{
+		"@type":       features.Alert,
+		"id":          a.Identifier,
+		"sender":      a.Sender,
+		"sent":        a.Sent,
+		"status":      a.Status,
+		"messageType": a.MsgType,
+		"category":    strings.Join(info.Category, " "),
+		"event":       info.Event,
+		"urgency":     info.Urgency,
+		"severity":    info.Severity,
+		"certainty":   info.Certainty,
+		"effective":   utils.Coalesce(info.Effective, a.Sent),
+		"senderName":  info.SenderName,
+		"headline":    info.Headline,
+		"description": info.Description,
+		"language":    utils.Coalesce(info.Language, DefaultLanguage),
+		"references":  parseReferences(a.References),
+	}
+
+	optional := map[string]string{
+		"onset":       info.Onset,
+		"expires":     info.Expires,
+		"instruction": info.Instruction,
+		"web":         info.Web,
+	}
+
+	for k, v := range optional {
+		if v != "" {
+			props[k] = v
+		}
+	}
+
+	if len(info.ResponseType) > 0 {
+		props["response"] = info.ResponseType[0]
+	}
+
+	if locs := localizations(a.Info, props.StringValue("language")); len(locs) > 0 {
+		props["localizations"] = locs
+	}
+
+	params := namedValuesToObject(info.Parameters)
+	if len(params) > 0 {
+		props["parameters"] = params
+	}
+
+	eventCodes := namedValuesToObject(info.EventCodes)
+	if len(eventCodes) > 0 {
+		props["eventCode"] = eventCodes
+	}
+
+	areaDescs := make([]string, 0, len(info.Areas))
+	geocodes := []NamedValue{}
+	for _, area := range info.Areas {
+		areaDescs = append(areaDescs, area.AreaDesc)
+		geocodes = append(geocodes, area.Geocodes...)
+	}
+
+	props["areaDesc"] = strings.Join(areaDescs, "; ")
+	props["geocode"] = namedValuesToObject(geocodes)
+
+	geometry, err := areaGeometry(info.Areas)
+	if err != nil {
+		return features.Feature{}, fmt.Errorf("alert %s has an invalid area: %w", a.Identifier, err)
+	}
+
+	return features.Feature{
+		ID:         a.Identifier,
+		Geometry:   geometry,
+		Properties: props,
+	}, nil
+}
+
+// FromFeature converts a wx:Alert feature into a CAP alert with an <info>
+// block for each language, each with the same single <area>
+func FromFeature(f features.Feature) (Alert, error) {
+	if f.Properties.StringValue("@type") != features.Alert {
+		return Alert{}, errors.New("only Alert features can be converted to CAP")
+	}
+
+	props := f.Properties
+	a := Alert{
+		Identifier: utils.Coalesce(props.StringValue("id"), f.ID),
+		Sender:     props.StringValue("sender"),
+		Sent:       props.StringValue("sent"),
+		Status:     props.StringValue("status"),
+		MsgType:    props.StringValue("messageType"),
+		Scope:      "Public",
+		References: formatReferences(props["references"]),
+	}
+
+	info := Info{
+		Language:    props.StringValue("language"),
+		Category:    strings.Fields(utils.Coalesce(props.StringValue("category"), "Met")),
+		Event:       props.StringValue("event"),
+		Urgency:     props.StringValue("urgency"),
+		Severity:    props.StringValue("severity"),
+		Certainty:   props.StringValue("certainty"),
+		Effective:   props.StringValue("effective"),
+		Onset:       props.StringValue("onset"),
+		Expires:     utils.Coalesce(props.StringValue("ends"), props.StringValue("expires")),
+		SenderName:  props.StringValue("senderName"),
+		Headline:    props.StringValue("headline"),
+		Description: props.StringValue("description"),
+		Instruction: props.StringValue("instruction"),
+		Web:         props.StringValue("web"),
+		Parameters:  objectToNamedValues(props["parameters"]),
+		EventCodes:  objectToNamedValues(props["eventCode"]),
+	}
+
+	if response := props.StringValue("response"); response != "" {
+		info.ResponseType = []string{response}
+	}
+
+	area := Area{
+		AreaDesc: props.StringValue("areaDesc"),
+		Geocodes: objectToNamedValues(props["geocode"]),
+	}
+
+	area.Polygons, area.Circles = capPolygons(f.Geometry)
+	info.Areas = []Area{area}
+
+	for _, loc := range f.Localizations() {
+		localized := info
+		localized.Language = loc.Language
+		localized.Headline = loc.Headline
+		localized.Description = loc.Description
+		localized.Instruction = loc.Instruction
+		a.Info = append(a.Info, localized)
+	}
+
+	return a, nil
+}
+
+// FromFeatures converts every alert in feats, skipping anything that isn't
+// an alert
+func FromFeatures(feats features.Features) Alerts {
+	alerts := Alerts{Alerts: make([]Alert, 0, len(feats))}
+	for _, f := range feats {
+		a, err := FromFeature(f)
+		if err != nil {
+			continue
+		}
+
+		alerts.Alerts = append(alerts.Alerts, a)
+	}
+
+	return alerts
+}
+
+func primaryInfo(infos []Info) Info {
+	for _, info := range infos {
+		if info.Language == "" || strings.EqualFold(info.Language, DefaultLanguage) {
+			return info
+		}
+	}
+
+	return infos[0]
+}
+
+// localizations returns the text of the <info> blocks that aren't in the
+// primary language, one per language
+func localizations(infos []Info, primary string) []features.Localization {
+	seen := map[string]bool{strings.ToLower(primary): true}
+	locs := []features.Localization{}
+	for _, info := range infos {
+		lang := utils.Coalesce(info.Language, DefaultLanguage)
+		if seen[strings.ToLower(lang)] {
+			continue
+		}
+		seen[strings.ToLower(lang)] = true
+
+		locs = append(locs, features.Localization{
+			Language:    lang,
+			Headline:    info.Headline,
+			Description: info.Description,
+			Instruction: info.Instruction,
+		})
+	}
+
+	return locs
+}
+
+// namedValuesToObject groups valueName/value pairs the way the NWS API does,
+// as a map of name to a list of values
+func namedValuesToObject(nvs []NamedValue) map[string]any {
+	obj := map[string]any{}
+	for _, nv := range nvs {
+		values, _ := obj[nv.ValueName].([]any)
+		obj[nv.ValueName] = append(values, nv.Value)
+	}
+
+	return obj
+}
+
+func objectToNamedValues(raw any) []NamedValue {
+	obj, ok := raw.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	nvs := []NamedValue{}
+	for _, name := range names {
+		switch v := obj[name].(type) {
+		case string:
+			nvs = append(nvs, NamedValue{ValueName: name, Value: v})
+		case []string:
+			for _, s := range v {
+				nvs = append(nvs, NamedValue{ValueName: name, Value: s})
+			}
+		default:
+			if values, ok := utils.NormalizeSlice(v); ok {
+				for _, value := range values {
+					nvs = append(nvs, NamedValue{ValueName: name, Value: fmt.Sprint(value)})
+				}
+			}
+		}
+	}
+
+	return nvs
+}
+
+// parseReferences converts the CAP "sender,identifier,sent" triples into the
+// objects the NWS API uses
+func parseReferences(refs string) []any {
+	parsed := []any{}
+	for _, ref := range strings.Fields(refs) {
+		parts := strings.Split(ref, ",")
+		if len(parts) != 3 {
+			continue
+		}
+
+		parsed = append(parsed, map[string]any{
+			"sender":     parts[0],
+			"identifier": parts[1],
+			"sent":       parts[2],
+		})
+	}
+
+	return parsed
+}
+
+func formatReferences(raw any) string {
+	refs, ok := utils.NormalizeSlice(raw)
+	if !ok {
+		return ""
+	}
+
+	triples := make([]string, 0, len(refs))
+	for _, r := range refs {
+		ref, ok := r.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		triples = append(triples, fmt.Sprintf("%v,%v,%v", ref["sender"], ref["identifier"], ref["sent"]))
+	}
+
+	return strings.Join(triples, " ")
+}
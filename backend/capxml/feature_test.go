@@ -0,0 +1,101 @@
+package capxml_test
+
+import (
+	"bytes"
+	"os"
+
+	"github.com/jghiloni/watchedsky-social/backend/capxml"
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/geojson"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Feature", func() {
+	var alert capxml.Alert
+
+	BeforeEach(func() {
+		f, e := os.Open("testdata/tornado.xml")
+		Expect(e).NotTo(HaveOccurred())
+		defer f.Close()
+
+		alert, e = capxml.Decode(f)
+		Expect(e).NotTo(HaveOccurred())
+	})
+
+	Describe("ToFeature", func() {
+		It("Converts the alert to a wx:Alert feature", func() {
+			f, e := capxml.ToFeature(alert)
+			Expect(e).NotTo(HaveOccurred())
+
+			Expect(f.ID).To(Equal("urn:oid:2.49.0.1.840.0.1a2b3c4d.001.1"))
+			Expect(f.Properties.StringValue("@type")).To(Equal(features.Alert))
+			Expect(f.Properties.StringValue("event")).To(Equal("Tornado Warning"))
+			Expect(f.Properties.StringValue("areaDesc")).To(Equal("Will, IL; Joliet"))
+			Expect(f.Properties["geocode"]).To(HaveKeyWithValue("SAME", []any{"017197"}))
+			Expect(f.Properties["references"]).To(HaveLen(1))
+		})
+
+		It("Converts polygons and circles to a MultiPolygon", func() {
+			f, e := capxml.ToFeature(alert)
+			Expect(e).NotTo(HaveOccurred())
+
+			mp, ok := f.Geometry.(geojson.MultiPolygon)
+			Expect(ok).To(BeTrue())
+			Expect(mp).To(HaveLen(2))
+			Expect(mp[0][0][0]).To(Equal(geojson.Coordinate{Latitude: 41.5, Longitude: -88.2}))
+			Expect(geojson.Distance(mp[1][0][0], geojson.Coordinate{Latitude: 41.525, Longitude: -88.082})).To(BeNumerically("~", 5, 0.001))
+		})
+	})
+
+	Describe("FromFeature", func() {
+		It("Round trips through XML", func() {
+			f, e := capxml.ToFeature(alert)
+			Expect(e).NotTo(HaveOccurred())
+
+			a, e := capxml.FromFeature(f)
+			Expect(e).NotTo(HaveOccurred())
+
+			buf := new(bytes.Buffer)
+			Expect(capxml.Encode(buf, a)).To(Succeed())
+
+			decoded, e := capxml.Decode(buf)
+			Expect(e).NotTo(HaveOccurred())
+			Expect(decoded.Identifier).To(Equal(alert.Identifier))
+			Expect(decoded.References).To(Equal(alert.References))
+			Expect(decoded.Info[0].Areas[0].Polygons).To(HaveLen(2))
+			Expect(capxml.ParsePolygon(decoded.Info[0].Areas[0].Polygons[0])).To(Equal(mustParsePolygon(alert.Info[0].Areas[0].Polygons[0])))
+			Expect(decoded.Info[0].Parameters).To(ConsistOf(alert.Info[0].Parameters))
+		})
+
+		It("Writes an info block per language", func() {
+			spanish := alert.Info[0]
+			spanish.Language = "es-US"
+			spanish.Headline = "Aviso de tornado"
+			alert.Info = append(alert.Info, spanish)
+
+			f, e := capxml.ToFeature(alert)
+			Expect(e).NotTo(HaveOccurred())
+			Expect(f.Localized("es").Headline).To(Equal("Aviso de tornado"))
+
+			a, e := capxml.FromFeature(f)
+			Expect(e).NotTo(HaveOccurred())
+			Expect(a.Info).To(HaveLen(2))
+			Expect(a.Info[0].Language).To(Equal("en-US"))
+			Expect(a.Info[1].Language).To(Equal("es-US"))
+			Expect(a.Info[1].Headline).To(Equal("Aviso de tornado"))
+			Expect(a.Info[1].Areas).To(Equal(a.Info[0].Areas))
+		})
+
+		It("Rejects features that aren't alerts", func() {
+			_, e := capxml.FromFeature(features.Feature{Properties: features.JSONObject{"@type": features.Zone}})
+			Expect(e).To(HaveOccurred())
+		})
+	})
+})
+
+func mustParsePolygon(s string) geojson.Polygon {
+	p, e := capxml.ParsePolygon(s)
+	Expect(e).NotTo(HaveOccurred())
+	return p
+}
@@ -0,0 +1,186 @@
+package capxml
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jghiloni/watchedsky-social/backend/geojson"
+)
+
+// circleSegments is the number of sides used when approximating a CAP circle
+// as a polygon
+const circleSegments = 32
+
+func parsePair(pair string) (geojson.Coordinate, error) {
+	latlon := strings.Split(pair, ",")
+	if len(latlon) != 2 {
+		return geojson.Coordinate{}, fmt.Errorf("expected lat,lon, got %q", pair)
+	}
+
+	lat, err := strconv.ParseFloat(strings.TrimSpace(latlon[0]), 64)
+	if err != nil {
+		return geojson.Coordinate{}, fmt.Errorf("invalid latitude %q: %w", latlon[0], err)
+	}
+
+	lon, err := strconv.ParseFloat(strings.TrimSpace(latlon[1]), 64)
+	if err != nil {
+		return geojson.Coordinate{}, fmt.Errorf("invalid longitude %q: %w", latlon[1], err)
+	}
+
+	return geojson.Coordinate{Latitude: lat, Longitude: lon}, nil
+}
+
+func formatPair(c geojson.Coordinate) string {
+	return strconv.FormatFloat(c.Latitude, 'f', -1, 64) + "," + strconv.FormatFloat(c.Longitude, 'f', -1, 64)
+}
+
+// ParsePolygon converts a CAP polygon into a single ring geojson.Polygon
+func ParsePolygon(s string) (geojson.Polygon, error) {
+	pairs := strings.Fields(s)
+	if len(pairs) < 4 {
+		return nil, fmt.Errorf("a polygon requires at least 4 points, got %d", len(pairs))
+	}
+
+	ring := make([]geojson.Coordinate, 0, len(pairs))
+	for _, pair := range pairs {
+		c, err := parsePair(pair)
+		if err != nil {
+			return nil, err
+		}
+
+		ring = append(ring, c)
+	}
+
+	if ring[0] != ring[len(ring)-1] {
+		return nil, errors.New("the first and last points of a polygon must be the same")
+	}
+
+	return geojson.Polygon{ring}, nil
+}
+
+// ParseCircle converts a CAP circle into a polygon approximating it. A circle
+// with a zero radius is returned as a Point
+func ParseCircle(s string) (geojson.Geometry, error) {
+	parts := strings.Fields(s)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("expected \"lat,lon radius\", got %q", s)
+	}
+
+	center, err := parsePair(parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	radius, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid radius %q: %w", parts[1], err)
+	}
+
+	if radius < 0 {
+		return nil, fmt.Errorf("radius must not be negative, got %v", radius)
+	}
+
+	if radius == 0 {
+		return geojson.Point(center), nil
+	}
+
+	return geojson.Circle(center, radius, circleSegments), nil
+}
+
+// FormatPolygon converts the outer ring of p into a CAP polygon
+func FormatPolygon(p geojson.Polygon) string {
+	if len(p) == 0 {
+		return ""
+	}
+
+	return strings.Join(formatRing(p[0]), " ")
+}
+
+func formatRing(ring []geojson.Coordinate) []string {
+	pairs := make([]string, 0, len(ring)+1)
+	for _, c := range ring {
+		pairs = append(pairs, formatPair(c))
+	}
+
+	// CAP requires closed rings even if the source geometry was sloppy
+	if len(ring) > 0 && ring[0] != ring[len(ring)-1] {
+		pairs = append(pairs, formatPair(ring[0]))
+	}
+
+	return pairs
+}
+
+// areaGeometry merges the polygons and circles of CAP areas into a geometry.
+// A single shape is returned as is, several polygons become a MultiPolygon,
+// and anything else becomes a GeometryCollection
+func areaGeometry(areas []Area) (geojson.Geometry, error) {
+	geos := []geojson.Geometry{}
+	polys := geojson.MultiPolygon{}
+	for _, area := range areas {
+		for _, s := range area.Polygons {
+			p, err := ParsePolygon(s)
+			if err != nil {
+				return nil, err
+			}
+
+			geos = append(geos, p)
+			polys = append(polys, p)
+		}
+
+		for _, s := range area.Circles {
+			g, err := ParseCircle(s)
+			if err != nil {
+				return nil, err
+			}
+
+			geos = append(geos, g)
+			if p, ok := g.(geojson.Polygon); ok {
+				polys = append(polys, p)
+			}
+		}
+	}
+
+	switch {
+	case len(geos) == 0:
+		return nil, nil
+	case len(geos) == 1:
+		return geos[0], nil
+	case len(polys) == len(geos):
+		return polys, nil
+	default:
+		return geojson.GeometryCollection{GT: geojson.GeometryCollectionType, Geometries: geos}, nil
+	}
+}
+
+// capPolygons flattens a geometry into CAP polygon strings. Points become
+// zero-radius circles, and lines are ignored since CAP cannot express them
+func capPolygons(g geojson.Geometry) (polygons []string, circles []string) {
+	switch geo := g.(type) {
+	case geojson.Polygon:
+		if s := FormatPolygon(geo); s != "" {
+			polygons = append(polygons, s)
+		}
+	case geojson.MultiPolygon:
+		for _, p := range geo {
+			if s := FormatPolygon(p); s != "" {
+				polygons = append(polygons, s)
+			}
+		}
+	case geojson.Point:
+		circles = append(circles, formatPair(geojson.Coordinate(geo))+" 0")
+	case geojson.MultiPoint:
+		for _, c := range geo {
+			circles = append(circles, formatPair(c)+" 0")
+		}
+	case geojson.GeometryCollection:
+		for _, child := range geo.Geometries {
+			p, c := capPolygons(child)
+			polygons = append(polygons, p...)
+			circles = append(circles, c...)
+		}
+	}
+
+	return polygons, circles
+}
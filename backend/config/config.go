@@ -3,20 +3,59 @@ package config
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log/slog"
 	"os"
 	"time"
 
+	"github.com/jghiloni/watchedsky-social/backend/features"
 	"github.com/kelseyhightower/envconfig"
 	"gopkg.in/yaml.v3"
 )
 
+// DatabaseConfig describes how to connect to Mongo. URI is a full
+// connection string, e.g. mongodb+srv://cluster.example.com/watchedsky, and
+// the other fields override what it says. Without a URI, Host is one or more
+// comma separated host:port pairs
 type DatabaseConfig struct {
+	URI                    string `yaml:"uri" envconfig:"uri"`
 	Host                   string `yaml:"host" envconfig:"host"`
 	Username               string `yaml:"username" envconfig:"username"`
 	Password               string `yaml:"password" envconfig:"password"`
 	Name                   string `yaml:"database" envconfig:"database"`
 	AuthenticationDatabase string `yaml:"authdb" envconfig:"authdb"`
+	ReplicaSet             string `yaml:"replica_set" envconfig:"replica_set"`
+	// ReadPreference is primary, primaryPreferred, secondary,
+	// secondaryPreferred or nearest
+	ReadPreference string             `yaml:"read_preference" envconfig:"read_preference"`
+	TLS            DatabaseTLSConfig  `yaml:"tls" envconfig:"tls"`
+	Pool           DatabasePoolConfig `yaml:"pool" envconfig:"pool"`
+}
+
+// Configured reports whether a database has been set up at all
+func (c DatabaseConfig) Configured() bool {
+	return c.URI != "" || c.Host != "" || c.Username != ""
+}
+
+// DatabaseTLSConfig turns on TLS for database connections. The files are
+// PEM encoded, and the certificate key file holds the client certificate and
+// its private key
+type DatabaseTLSConfig struct {
+	Enabled                    bool   `yaml:"enabled" envconfig:"enabled"`
+	CAFile                     string `yaml:"ca_file" envconfig:"ca_file"`
+	CertificateKeyFile         string `yaml:"certificate_key_file" envconfig:"certificate_key_file"`
+	CertificateKeyFilePassword string `yaml:"certificate_key_file_password" envconfig:"certificate_key_file_password"`
+	// Insecure skips verifying the server's certificate and host name
+	Insecure bool `yaml:"insecure" envconfig:"insecure"`
+}
+
+// DatabasePoolConfig sizes the pool of connections to each server. Zero
+// values leave the driver's defaults
+type DatabasePoolConfig struct {
+	MaxSize       uint64        `yaml:"max_size" envconfig:"max_size"`
+	MinSize       uint64        `yaml:"min_size" envconfig:"min_size"`
+	MaxConnecting uint64        `yaml:"max_connecting" envconfig:"max_connecting"`
+	MaxIdleTime   time.Duration `yaml:"max_idle_time" envconfig:"max_idle_time"`
 }
 
 type BlueskyConfig struct {
@@ -44,17 +83,63 @@ type AlertPollConfig struct {
 
 type FirehoseConfig struct {
 	Enabled bool `yaml:"enabled" envconfig:"enabled"`
+
+	// MinPostPriority keeps alerts that score lower off Bluesky. Updates to
+	// alerts that were already posted are posted regardless
+	MinPostPriority float64 `yaml:"min_post_priority" envconfig:"min_post_priority"`
+}
+
+// RetentionConfig controls archiving alerts that have ended, and how long
+// archived features are kept
+type RetentionConfig struct {
+	Enabled  bool          `yaml:"enabled" envconfig:"enabled"`
+	Interval time.Duration `yaml:"interval" envconfig:"interval"`
+
+	// Windows are how long archived features of each type are kept after
+	// they are archived, keyed by the type's short name, e.g. Alert for
+	// wx:Alert, since envconfig splits map entries on colons. Types without
+	// a window are kept forever
+	Windows map[string]time.Duration `yaml:"windows" envconfig:"windows"`
+}
+
+// TypeWindows returns Windows keyed by feature type, e.g. wx:Alert. Names
+// that aren't registered types are an error
+func (r RetentionConfig) TypeWindows() (map[string]time.Duration, error) {
+	windows := make(map[string]time.Duration, len(r.Windows))
+	for name, window := range r.Windows {
+		featureType, ok := features.Types.Named(name)
+		if !ok {
+			return nil, fmt.Errorf("retention window for %w %q", features.ErrUnknownType, name)
+		}
+
+		windows[featureType] = window
+	}
+
+	return windows, nil
+}
+
+// StoreConfig selects where features are kept. Backend is mongo, the
+// default; bolt, an embedded database in the file at Path; or memory, which
+// keeps nothing across restarts
+type StoreConfig struct {
+	Backend string `yaml:"backend" envconfig:"backend"`
+	Path    string `yaml:"path" envconfig:"path"`
 }
 
 type AppConfig struct {
 	BaseURL        string           `yaml:"base_url" envconfig:"base_url"`
 	LogLevel       LogLevel         `yaml:"log_level" envconfig:"log_level"`
+	Store          StoreConfig      `yaml:"store" envconfig:"store"`
 	MongoDB        DatabaseConfig   `yaml:"database" envconfig:"database"`
 	Bluesky        BlueskyConfig    `yaml:"bluesky" envconfig:"bluesky"`
 	Prometheus     PrometheusConfig `yaml:"metrics" envconfig:"metrics"`
 	HTTPServer     HTTPServerConfig `yaml:"http_server" envconfig:"http_server"`
 	AlertPoller    AlertPollConfig  `yaml:"alert_poller" envconfig:"alert_poller"`
 	FirehoseNozzle FirehoseConfig   `yaml:"firehose" envconfig:"firehose"`
+	Retention      RetentionConfig  `yaml:"retention" envconfig:"retention"`
+
+	// Priority overrides weights of features.DefaultPriorityModel
+	Priority features.PriorityModel `yaml:"priority" envconfig:"priority"`
 }
 
 type contextKey struct{}
@@ -0,0 +1,13 @@
+package cql2_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestCQL2(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "CQL2 Suite")
+}
@@ -0,0 +1,556 @@
+package cql2
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/geojson"
+	"github.com/jghiloni/watchedsky-social/backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Expr is a parsed filter. The same filter can be applied to features in
+// memory with Match, or compiled to a Mongo query with Mongo
+type Expr interface {
+	Match(f features.Feature) bool
+	Mongo() bson.D
+}
+
+// MongoWith compiles expr to a Mongo query like Expr.Mongo does, except that
+// each S_INTERSECTS on the geometry property compiles to what spatial
+// returns. spatial is given the predicate's geometry and the query it would
+// otherwise compile to, so that stores that keep part of a feature's
+// geometry elsewhere can widen it
+func MongoWith(expr Expr, spatial func(g geojson.Geometry, query bson.D) bson.D) bson.D {
+	switch e := expr.(type) {
+	case andExpr:
+		terms := bson.A{}
+		for _, term := range e {
+			terms = append(terms, MongoWith(term, spatial))
+		}
+		return bson.D{{Key: "$and", Value: terms}}
+	case orExpr:
+		terms := bson.A{}
+		for _, term := range e {
+			terms = append(terms, MongoWith(term, spatial))
+		}
+		return bson.D{{Key: "$or", Value: terms}}
+	case notExpr:
+		return bson.D{{Key: "$nor", Value: bson.A{MongoWith(e.expr, spatial)}}}
+	case spatialIntersects:
+		if e.property == "geometry" {
+			return spatial(e.geometry, e.Mongo())
+		}
+	}
+
+	return expr.Mongo()
+}
+
+// Filter returns the features that match expr
+func Filter(expr Expr, feats features.Features) features.Features {
+	matched := features.Features{}
+	for _, f := range feats {
+		if expr.Match(f) {
+			matched = append(matched, f)
+		}
+	}
+
+	return matched
+}
+
+// path is the document path of a property
+func path(property string) string {
+	if property == "geometry" {
+		return property
+	}
+
+	return "properties." + property
+}
+
+// lookup returns the value of a property. Nested properties are separated by
+// dots, and arrays along the way are flattened, so the result may hold
+// several values
+func lookup(f features.Feature, property string) []any {
+	if property == "geometry" {
+		if f.Geometry == nil {
+			return nil
+		}
+		return []any{f.Geometry}
+	}
+
+	values := []any{map[string]any(f.Properties)}
+	for _, key := range strings.Split(property, ".") {
+		next := []any{}
+		for _, v := range values {
+			m, ok := asMap(v)
+			if !ok {
+				continue
+			}
+
+			child, ok := m[key]
+			if !ok {
+				continue
+			}
+
+			if items, ok := utils.NormalizeSlice(child); ok {
+				next = append(next, items...)
+			} else {
+				next = append(next, child)
+			}
+		}
+		values = next
+	}
+
+	return values
+}
+
+// asMap converts nested documents to maps. Properties that were set from Go
+// structs, such as hazards, are converted through JSON
+func asMap(v any) (map[string]any, bool) {
+	switch m := v.(type) {
+	case map[string]any:
+		return m, true
+	case features.JSONObject:
+		return m, true
+	case primitive.M:
+		return m, true
+	case primitive.D:
+		return m.Map(), true
+	case nil, string, float64, int, int32, int64, bool:
+		return nil, false
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, false
+	}
+
+	var m map[string]any
+	if json.Unmarshal(raw, &m) != nil {
+		return nil, false
+	}
+
+	return m, true
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+
+	return 0, false
+}
+
+func toTime(v any) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case primitive.DateTime:
+		return t.Time(), true
+	case string:
+		parsed, err := time.Parse(time.RFC3339, t)
+		return parsed, err == nil
+	}
+
+	return time.Time{}, false
+}
+
+// compare compares a property value to a literal. ok is false if they can't
+// be compared
+func compare(v any, literal any) (cmp int, ok bool) {
+	switch lit := literal.(type) {
+	case float64:
+		f, ok := toFloat(v)
+		if !ok {
+			return 0, false
+		}
+
+		switch {
+		case f < lit:
+			return -1, true
+		case f > lit:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		s, ok := v.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(s, lit), true
+	case bool:
+		b, ok := v.(bool)
+		if !ok || b != lit {
+			return 1, ok
+		}
+		return 0, true
+	case time.Time:
+		t, ok := toTime(v)
+		if !ok {
+			return 0, false
+		}
+		return t.Compare(lit), true
+	}
+
+	return 0, false
+}
+
+type andExpr []Expr
+
+func (a andExpr) Match(f features.Feature) bool {
+	for _, e := range a {
+		if !e.Match(f) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (a andExpr) Mongo() bson.D {
+	terms := bson.A{}
+	for _, e := range a {
+		terms = append(terms, e.Mongo())
+	}
+
+	return bson.D{{Key: "$and", Value: terms}}
+}
+
+type orExpr []Expr
+
+func (o orExpr) Match(f features.Feature) bool {
+	for _, e := range o {
+		if e.Match(f) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (o orExpr) Mongo() bson.D {
+	terms := bson.A{}
+	for _, e := range o {
+		terms = append(terms, e.Mongo())
+	}
+
+	return bson.D{{Key: "$or", Value: terms}}
+}
+
+type notExpr struct {
+	expr Expr
+}
+
+func (n notExpr) Match(f features.Feature) bool {
+	return !n.expr.Match(f)
+}
+
+func (n notExpr) Mongo() bson.D {
+	return bson.D{{Key: "$nor", Value: bson.A{n.expr.Mongo()}}}
+}
+
+// comparison is property op literal. As in Mongo, a property with several
+// values matches if any of them do, except for <>, which matches if none of
+// them are equal
+type comparison struct {
+	property string
+	op       string
+	value    any
+}
+
+var mongoOperators = map[string]string{
+	"=":  "$eq",
+	"<>": "$ne",
+	"<":  "$lt",
+	"<=": "$lte",
+	">":  "$gt",
+	">=": "$gte",
+}
+
+func (c comparison) Match(f features.Feature) bool {
+	values := lookup(f, c.property)
+	if c.op == "<>" {
+		for _, v := range values {
+			if cmp, ok := compare(v, c.value); ok && cmp == 0 {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	for _, v := range values {
+		cmp, ok := compare(v, c.value)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case c.op == "=" && cmp == 0,
+			c.op == "<" && cmp < 0,
+			c.op == "<=" && cmp <= 0,
+			c.op == ">" && cmp > 0,
+			c.op == ">=" && cmp >= 0:
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c comparison) Mongo() bson.D {
+	op := mongoOperators[c.op]
+	if t, ok := c.value.(time.Time); ok {
+		// times are stored as strings with the issuing office's offset, so
+		// they have to be parsed to compare them
+		cmp := bson.D{{Key: op, Value: bson.A{dateField(c.property), t}}}
+		if c.op == "<>" {
+			return bson.D{{Key: "$expr", Value: cmp}}
+		}
+
+		return bson.D{{Key: "$expr", Value: bson.D{{Key: "$and", Value: bson.A{isString(c.property), cmp}}}}}
+	}
+
+	return bson.D{{Key: path(c.property), Value: bson.D{{Key: op, Value: c.value}}}}
+}
+
+// dateField parses a timestamp property in an aggregation expression.
+// Missing and invalid timestamps become null
+func dateField(property string) bson.D {
+	return bson.D{{Key: "$dateFromString", Value: bson.D{
+		{Key: "dateString", Value: "$" + path(property)},
+		{Key: "onError", Value: nil},
+		{Key: "onNull", Value: nil},
+	}}}
+}
+
+// isString is true in an aggregation expression if a property is a string.
+// $dateFromString turns missing properties into null, which sorts before
+// every date, so date comparisons check for this first
+func isString(property string) bson.D {
+	return bson.D{{Key: "$eq", Value: bson.A{bson.D{{Key: "$type", Value: "$" + path(property)}}, "string"}}}
+}
+
+type likeExpr struct {
+	property string
+	pattern  *regexp.Regexp
+	not      bool
+}
+
+// newLikeExpr converts a LIKE pattern to a regular expression. % matches any
+// number of characters, _ matches one, and \ escapes either
+func newLikeExpr(property string, pattern string, not bool) likeExpr {
+	sb := strings.Builder{}
+	sb.WriteString("^")
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			sb.WriteString(".*")
+		case r == '_':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+
+	return likeExpr{
+		property: property,
+		pattern:  regexp.MustCompile("(?s)" + sb.String()),
+		not:      not,
+	}
+}
+
+func (l likeExpr) Match(f features.Feature) bool {
+	matched := false
+	for _, v := range lookup(f, l.property) {
+		if s, ok := v.(string); ok && l.pattern.MatchString(s) {
+			matched = true
+			break
+		}
+	}
+
+	return matched != l.not
+}
+
+func (l likeExpr) Mongo() bson.D {
+	regex := primitive.Regex{Pattern: strings.TrimPrefix(l.pattern.String(), "(?s)"), Options: "s"}
+	if l.not {
+		return bson.D{{Key: path(l.property), Value: bson.D{{Key: "$not", Value: regex}}}}
+	}
+
+	return bson.D{{Key: path(l.property), Value: regex}}
+}
+
+type inExpr struct {
+	property string
+	values   []any
+	not      bool
+}
+
+func (in inExpr) Match(f features.Feature) bool {
+	matched := false
+	for _, v := range lookup(f, in.property) {
+		for _, value := range in.values {
+			if cmp, ok := compare(v, value); ok && cmp == 0 {
+				matched = true
+			}
+		}
+	}
+
+	return matched != in.not
+}
+
+func (in inExpr) Mongo() bson.D {
+	op := "$in"
+	if in.not {
+		op = "$nin"
+	}
+
+	return bson.D{{Key: path(in.property), Value: bson.D{{Key: op, Value: in.values}}}}
+}
+
+type nullExpr struct {
+	property string
+	not      bool
+}
+
+func (n nullExpr) Match(f features.Feature) bool {
+	isNull := true
+	for _, v := range lookup(f, n.property) {
+		if v != nil {
+			isNull = false
+		}
+	}
+
+	return isNull != n.not
+}
+
+func (n nullExpr) Mongo() bson.D {
+	if n.not {
+		return bson.D{{Key: path(n.property), Value: bson.D{{Key: "$ne", Value: nil}}}}
+	}
+
+	return bson.D{{Key: path(n.property), Value: nil}}
+}
+
+// instant is one end of an interval: a property, a literal time, or
+// unbounded
+type instant struct {
+	property string
+	literal  time.Time
+	open     bool
+}
+
+func (i instant) resolve(f features.Feature) (time.Time, bool) {
+	if i.property == "" {
+		return i.literal, true
+	}
+
+	for _, v := range lookup(f, i.property) {
+		if t, ok := toTime(v); ok {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func (i instant) mongo() any {
+	if i.property == "" {
+		return i.literal
+	}
+
+	return dateField(i.property)
+}
+
+type interval struct {
+	start instant
+	end   instant
+}
+
+type temporalIntersects struct {
+	a interval
+	b interval
+}
+
+// bounds are the pairs that must be in order for the intervals to intersect:
+// each interval has to start before the other one ends
+func (t temporalIntersects) bounds() [][2]instant {
+	return [][2]instant{{t.a.start, t.b.end}, {t.b.start, t.a.end}}
+}
+
+func (t temporalIntersects) Match(f features.Feature) bool {
+	for _, pair := range t.bounds() {
+		if pair[0].open || pair[1].open {
+			continue
+		}
+
+		before, ok1 := pair[0].resolve(f)
+		after, ok2 := pair[1].resolve(f)
+		if !ok1 || !ok2 || before.After(after) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (t temporalIntersects) Mongo() bson.D {
+	conditions := bson.A{}
+	for _, pair := range t.bounds() {
+		if pair[0].open || pair[1].open {
+			continue
+		}
+
+		for _, i := range pair {
+			if i.property != "" {
+				conditions = append(conditions, isString(i.property))
+			}
+		}
+
+		conditions = append(conditions, bson.D{{Key: "$lte", Value: bson.A{pair[0].mongo(), pair[1].mongo()}}})
+	}
+
+	return bson.D{{Key: "$expr", Value: bson.D{{Key: "$and", Value: conditions}}}}
+}
+
+type spatialIntersects struct {
+	property string
+	geometry geojson.Geometry
+}
+
+func (s spatialIntersects) Match(f features.Feature) bool {
+	for _, v := range lookup(f, s.property) {
+		if g, ok := v.(geojson.Geometry); ok && geojson.Intersects(g, s.geometry) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s spatialIntersects) Mongo() bson.D {
+	return bson.D{{Key: path(s.property), Value: bson.D{{Key: "$geoIntersects", Value: bson.D{
+		{Key: "$geometry", Value: s.geometry},
+	}}}}}
+}
@@ -0,0 +1,133 @@
+package cql2_test
+
+import (
+	"github.com/jghiloni/watchedsky-social/backend/cql2"
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/geojson"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var _ = Describe("Filters", func() {
+	alert := features.Feature{
+		ID: "https://api.weather.gov/alerts/urn:oid:2.49.0.1.840.0.2",
+		Geometry: geojson.Polygon{{
+			{Longitude: -88.5, Latitude: 41.5},
+			{Longitude: -87.5, Latitude: 41.5},
+			{Longitude: -87.5, Latitude: 42.5},
+			{Longitude: -88.5, Latitude: 42.5},
+			{Longitude: -88.5, Latitude: 41.5},
+		}},
+		Properties: features.JSONObject{
+			"@type":     features.Alert,
+			"event":     "Severe Thunderstorm Warning",
+			"severity":  "Severe",
+			"effective": "2024-06-05T16:42:00-05:00",
+			"expires":   "2024-06-05T17:30:00-05:00",
+			"ends":      nil,
+			"geocode":   map[string]any{"SAME": []any{"017031", "017043"}},
+			"hazards":   features.Hazards{MaxWindGustMPH: 60, Tags: []features.HazardTag{features.HazardWind}},
+		},
+	}
+
+	DescribeTable("Matching features in memory",
+		func(filter string, matches bool) {
+			expr, e := cql2.Parse(filter)
+			Expect(e).NotTo(HaveOccurred())
+			Expect(expr.Match(alert)).To(Equal(matches))
+		},
+		Entry("equality", `event = 'Severe Thunderstorm Warning'`, true),
+		Entry("flipped comparison", `'Severe' = severity`, true),
+		Entry("not equal", `severity <> 'Severe'`, false),
+		Entry("array elements", `geocode.SAME = '017043'`, true),
+		Entry("numbers in nested structs", `hazards.maxWindGustMph >= 58`, true),
+		Entry("LIKE", `event LIKE '%Thunderstorm%'`, true),
+		Entry("NOT LIKE", `event NOT LIKE 'Tornado%'`, true),
+		Entry("IN", `severity IN ('Extreme', 'Severe')`, true),
+		Entry("NOT IN", `severity NOT IN ('Extreme', 'Severe')`, false),
+		Entry("IS NULL", `ends IS NULL AND expires IS NOT NULL`, true),
+		Entry("precedence", `severity = 'Minor' AND event = 'x' OR "@type" = 'wx:Alert'`, true),
+		Entry("NOT", `NOT (severity = 'Severe')`, false),
+		Entry("timestamps", `expires > TIMESTAMP('2024-06-05T22:00:00Z')`, true),
+		Entry("intersecting intervals", `T_INTERSECTS(INTERVAL(effective, expires), INTERVAL('2024-06-05T22:00:00Z', '..'))`, true),
+		Entry("disjoint intervals", `T_INTERSECTS(INTERVAL(effective, expires), TIMESTAMP('2024-06-05T23:00:00Z'))`, false),
+		Entry("missing interval bounds", `T_INTERSECTS(INTERVAL(effective, ends), TIMESTAMP('2024-06-05T22:00:00Z'))`, false),
+		Entry("point in polygon", `S_INTERSECTS(geometry, POINT(-88 42))`, true),
+		Entry("crossing lines", `S_INTERSECTS(LINESTRING(-89 42, -87 42), geometry)`, true),
+		Entry("disjoint boxes", `S_INTERSECTS(geometry, BBOX(-100, 30, -99, 31))`, false),
+		Entry("containing polygons", `S_INTERSECTS(geometry, POLYGON((-90 40, -86 40, -86 44, -90 44, -90 40)))`, true),
+	)
+
+	DescribeTable("Rejecting invalid filters",
+		func(filter string) {
+			_, e := cql2.Parse(filter)
+			var serr *cql2.SyntaxError
+			Expect(e).To(BeAssignableToTypeOf(serr))
+		},
+		Entry("unterminated strings", `event = 'Tornado`),
+		Entry("missing operands", `event =`),
+		Entry("trailing tokens", `event = 'x' 'y'`),
+		Entry("bad timestamps", `sent > TIMESTAMP('yesterday')`),
+		Entry("bad geometries", `S_INTERSECTS(geometry, POINT(1))`),
+		Entry("unbalanced parentheses", `(event = 'x'`),
+	)
+
+	It("Compiles to Mongo queries", func() {
+		expr, e := cql2.Parse(`severity IN ('Extreme', 'Severe') AND NOT event LIKE 'Test%'`)
+		Expect(e).NotTo(HaveOccurred())
+
+		q := expr.Mongo()
+		Expect(q).To(HaveLen(1))
+		Expect(q[0].Key).To(Equal("$and"))
+
+		terms := q[0].Value.(bson.A)
+		Expect(terms[0]).To(Equal(bson.D{{Key: "properties.severity", Value: bson.D{{Key: "$in", Value: []any{"Extreme", "Severe"}}}}}))
+		Expect(terms[1].(bson.D)[0].Key).To(Equal("$nor"))
+
+		_, e = bson.Marshal(q)
+		Expect(e).NotTo(HaveOccurred())
+	})
+
+	It("Reads identifiers with multibyte characters", func() {
+		expr, e := cql2.Parse(`événement = 'x' AND sévérité = 'y'`)
+		Expect(e).NotTo(HaveOccurred())
+
+		terms := expr.Mongo()[0].Value.(bson.A)
+		Expect(terms[0].(bson.D)[0].Key).To(Equal("properties.événement"))
+		Expect(terms[1].(bson.D)[0].Key).To(Equal("properties.sévérité"))
+	})
+
+	It("Compiles spatial filters to $geoIntersects", func() {
+		expr, e := cql2.Parse(`S_INTERSECTS(geometry, POINT(-88 42))`)
+		Expect(e).NotTo(HaveOccurred())
+
+		q := expr.Mongo()
+		Expect(q[0].Key).To(Equal("geometry"))
+
+		_, e = bson.Marshal(q)
+		Expect(e).NotTo(HaveOccurred())
+	})
+
+	It("Lets spatial filters on geometry be compiled differently", func() {
+		expr, e := cql2.Parse(`event = 'x' AND NOT (S_INTERSECTS(geometry, POINT(-88 42)) OR S_INTERSECTS(location, POINT(-88 42)))`)
+		Expect(e).NotTo(HaveOccurred())
+
+		widened := bson.D{{Key: "widened", Value: true}}
+		seen := []geojson.Geometry{}
+		q := cql2.MongoWith(expr, func(g geojson.Geometry, query bson.D) bson.D {
+			seen = append(seen, g)
+			Expect(query[0].Key).To(Equal("geometry"))
+			return widened
+		})
+
+		Expect(seen).To(Equal([]geojson.Geometry{geojson.Point{Longitude: -88, Latitude: 42}}))
+
+		terms := q[0].Value.(bson.A)
+		Expect(terms[0]).To(Equal(expr.Mongo()[0].Value.(bson.A)[0]))
+
+		alternatives := terms[1].(bson.D)[0].Value.(bson.A)[0].(bson.D)[0].Value.(bson.A)
+		Expect(alternatives[0]).To(Equal(widened))
+		Expect(alternatives[1].(bson.D)[0].Key).To(Equal("properties.location"))
+	})
+})
@@ -0,0 +1,458 @@
+package cql2
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jghiloni/watchedsky-social/backend/geojson"
+)
+
+// Parse parses a filter written in a subset of CQL2 text:
+//
+//   - comparisons: =, <>, <, <=, >, >= between a property and a literal
+//   - prop [NOT] LIKE 'pattern', with % and _ wildcards
+//   - prop [NOT] IN (literal, ...)
+//   - prop IS [NOT] NULL
+//   - AND, OR, NOT and parentheses
+//   - T_INTERSECTS(a, b), where a and b are properties, TIMESTAMP('...'),
+//     DATE('...') or INTERVAL(start, end) with '..' for an open end
+//   - S_INTERSECTS(geometry, wkt), where wkt is a WKT geometry or
+//     BBOX(minlon, minlat, maxlon, maxlat)
+//
+// Literals are 'strings', numbers, TRUE, FALSE, TIMESTAMP('...') and
+// DATE('...'). Property names refer to feature properties, except for
+// geometry, which is the feature geometry
+func Parse(filter string) (Expr, error) {
+	tokens, err := lex(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+
+	return expr, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+	t := p.next()
+	if t.kind != kind {
+		return t, p.errorf(t, "expected %s, got %s", what, t)
+	}
+
+	return t, nil
+}
+
+// acceptKeyword consumes the next token if it is the keyword kw
+func (p *parser) acceptKeyword(kw string) bool {
+	if p.peek().is(kw) {
+		p.next()
+		return true
+	}
+
+	return false
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	terms := []Expr{left}
+	for p.acceptKeyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, right)
+	}
+
+	if len(terms) == 1 {
+		return left, nil
+	}
+
+	return orExpr(terms), nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	terms := []Expr{left}
+	for p.acceptKeyword("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, right)
+	}
+
+	if len(terms) == 1 {
+		return left, nil
+	}
+
+	return andExpr(terms), nil
+}
+
+func (p *parser) parseNot() (Expr, error) {
+	if p.acceptKeyword("NOT") {
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		return notExpr{expr}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokLParen:
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err = p.expect(tokRParen, ")"); err != nil {
+			return nil, err
+		}
+
+		return expr, nil
+	case t.is("T_INTERSECTS"):
+		return p.parseTemporal()
+	case t.is("S_INTERSECTS"):
+		return p.parseSpatial()
+	}
+
+	return p.parsePredicate()
+}
+
+// parsePredicate parses a comparison, LIKE, IN or IS NULL predicate
+func (p *parser) parsePredicate() (Expr, error) {
+	t := p.peek()
+
+	// literal op property is allowed for comparisons, and flipped around
+	if t.kind != tokIdent && t.kind != tokQuotedIdent || isLiteralKeyword(t) {
+		value, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+
+		opTok, err := p.expect(tokOperator, "a comparison operator")
+		if err != nil {
+			return nil, err
+		}
+
+		prop, err := p.parseProperty()
+		if err != nil {
+			return nil, err
+		}
+
+		return comparison{property: prop, op: flipped[opTok.text], value: value}, nil
+	}
+
+	prop, err := p.parseProperty()
+	if err != nil {
+		return nil, err
+	}
+
+	if opTok := p.peek(); opTok.kind == tokOperator {
+		p.next()
+		value, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+
+		return comparison{property: prop, op: opTok.text, value: value}, nil
+	}
+
+	if p.acceptKeyword("IS") {
+		not := p.acceptKeyword("NOT")
+		if t := p.next(); !t.is("NULL") {
+			return nil, p.errorf(t, "expected NULL, got %s", t)
+		}
+
+		return nullExpr{property: prop, not: not}, nil
+	}
+
+	not := p.acceptKeyword("NOT")
+	switch t := p.next(); {
+	case t.is("LIKE"):
+		pattern, err := p.expect(tokString, "a LIKE pattern")
+		if err != nil {
+			return nil, err
+		}
+
+		return newLikeExpr(prop, pattern.text, not), nil
+	case t.is("IN"):
+		values, err := parseSeq(p, p.parseLiteral)
+		if err != nil {
+			return nil, err
+		}
+
+		return inExpr{property: prop, values: values, not: not}, nil
+	default:
+		return nil, p.errorf(t, "expected a comparison, LIKE, IN or IS NULL after %s, got %s", prop, t)
+	}
+}
+
+func (p *parser) parseProperty() (string, error) {
+	t := p.next()
+	if t.kind == tokQuotedIdent || (t.kind == tokIdent && !isLiteralKeyword(t)) {
+		return t.text, nil
+	}
+
+	return "", p.errorf(t, "expected a property name, got %s", t)
+}
+
+func isLiteralKeyword(t token) bool {
+	return t.is("TRUE") || t.is("FALSE") || t.is("TIMESTAMP") || t.is("DATE")
+}
+
+// parseLiteral parses a string, number, boolean, timestamp or date
+func (p *parser) parseLiteral() (any, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokString:
+		return t.text, nil
+	case t.kind == tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf(t, "invalid number %s", t)
+		}
+
+		return f, nil
+	case t.is("TRUE"):
+		return true, nil
+	case t.is("FALSE"):
+		return false, nil
+	case t.is("TIMESTAMP"), t.is("DATE"):
+		return p.parseTimeLiteral(t)
+	}
+
+	return nil, p.errorf(t, "expected a value, got %s", t)
+}
+
+// parseTimeLiteral parses the ('...') after TIMESTAMP or DATE
+func (p *parser) parseTimeLiteral(fn token) (time.Time, error) {
+	if _, err := p.expect(tokLParen, "("); err != nil {
+		return time.Time{}, err
+	}
+
+	s, err := p.expect(tokString, "a quoted time")
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if _, err = p.expect(tokRParen, ")"); err != nil {
+		return time.Time{}, err
+	}
+
+	layout := time.RFC3339
+	if fn.is("DATE") {
+		layout = time.DateOnly
+	}
+
+	t, err := time.Parse(layout, s.text)
+	if err != nil {
+		return time.Time{}, p.errorf(s, "invalid %s %s", strings.ToLower(fn.text), s)
+	}
+
+	return t, nil
+}
+
+// parseTemporal parses T_INTERSECTS(a, b)
+func (p *parser) parseTemporal() (Expr, error) {
+	p.next()
+	if _, err := p.expect(tokLParen, "("); err != nil {
+		return nil, err
+	}
+
+	a, err := p.parseInterval()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = p.expect(tokComma, ","); err != nil {
+		return nil, err
+	}
+
+	b, err := p.parseInterval()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = p.expect(tokRParen, ")"); err != nil {
+		return nil, err
+	}
+
+	return temporalIntersects{a: a, b: b}, nil
+}
+
+// parseInterval parses a property, TIMESTAMP, DATE or INTERVAL. Properties
+// and instants are intervals that start and end at the same time
+func (p *parser) parseInterval() (interval, error) {
+	t := p.peek()
+	if t.is("INTERVAL") {
+		p.next()
+		if _, err := p.expect(tokLParen, "("); err != nil {
+			return interval{}, err
+		}
+
+		start, err := p.parseInstant()
+		if err != nil {
+			return interval{}, err
+		}
+
+		if _, err = p.expect(tokComma, ","); err != nil {
+			return interval{}, err
+		}
+
+		end, err := p.parseInstant()
+		if err != nil {
+			return interval{}, err
+		}
+
+		if _, err = p.expect(tokRParen, ")"); err != nil {
+			return interval{}, err
+		}
+
+		return interval{start: start, end: end}, nil
+	}
+
+	i, err := p.parseInstant()
+	if err != nil {
+		return interval{}, err
+	}
+
+	if i.open {
+		return interval{}, p.errorf(t, "only interval bounds can be open")
+	}
+
+	return interval{start: i, end: i}, nil
+}
+
+// parseInstant parses a property, TIMESTAMP, DATE, a quoted timestamp or
+// '..' for an open bound
+func (p *parser) parseInstant() (instant, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokString:
+		p.next()
+		if t.text == ".." {
+			return instant{open: true}, nil
+		}
+
+		for _, layout := range []string{time.RFC3339, time.DateOnly} {
+			if ts, err := time.Parse(layout, t.text); err == nil {
+				return instant{literal: ts}, nil
+			}
+		}
+
+		return instant{}, p.errorf(t, "invalid timestamp %s", t)
+	case t.is("TIMESTAMP"), t.is("DATE"):
+		p.next()
+		ts, err := p.parseTimeLiteral(t)
+		return instant{literal: ts}, err
+	}
+
+	prop, err := p.parseProperty()
+	return instant{property: prop}, err
+}
+
+// parseSpatial parses S_INTERSECTS(a, b), where one side is a property and
+// the other a WKT geometry
+func (p *parser) parseSpatial() (Expr, error) {
+	p.next()
+	if _, err := p.expect(tokLParen, "("); err != nil {
+		return nil, err
+	}
+
+	var prop string
+	var geo geojson.Geometry
+	for i := 0; i < 2; i++ {
+		if i == 1 {
+			if _, err := p.expect(tokComma, ","); err != nil {
+				return nil, err
+			}
+		}
+
+		t := p.peek()
+		if isWKTKeyword(t) {
+			if geo != nil {
+				return nil, p.errorf(t, "S_INTERSECTS needs a property")
+			}
+
+			g, err := p.parseWKT()
+			if err != nil {
+				return nil, err
+			}
+			geo = g
+			continue
+		}
+
+		if prop != "" {
+			return nil, p.errorf(t, "S_INTERSECTS needs a geometry literal")
+		}
+
+		var err error
+		if prop, err = p.parseProperty(); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := p.expect(tokRParen, ")"); err != nil {
+		return nil, err
+	}
+
+	return spatialIntersects{property: prop, geometry: geo}, nil
+}
+
+// flipped maps comparison operators to the ones used when the operands are
+// swapped
+var flipped = map[string]string{
+	"=":  "=",
+	"<>": "<>",
+	"<":  ">",
+	"<=": ">=",
+	">":  "<",
+	">=": "<=",
+}
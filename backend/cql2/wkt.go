@@ -0,0 +1,146 @@
+package cql2
+
+import (
+	"strconv"
+
+	"github.com/jghiloni/watchedsky-social/backend/geojson"
+)
+
+func isWKTKeyword(t token) bool {
+	for _, kw := range []string{"POINT", "LINESTRING", "POLYGON", "MULTIPOINT", "MULTILINESTRING", "MULTIPOLYGON", "GEOMETRYCOLLECTION", "BBOX"} {
+		if t.is(kw) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseWKT parses a WKT geometry, or a CQL2 BBOX. WKT coordinates are
+// longitude then latitude
+func (p *parser) parseWKT() (geojson.Geometry, error) {
+	t := p.next()
+	switch {
+	case t.is("POINT"):
+		c, err := p.parenthesized(p.parseCoordinate)
+		return geojson.Point(c), err
+	case t.is("LINESTRING"):
+		line, err := p.parseCoordinates()
+		return geojson.LineString(line), err
+	case t.is("POLYGON"):
+		poly, err := p.parsePolygon()
+		return geojson.Polygon(poly), err
+	case t.is("MULTIPOINT"):
+		points, err := parseSeq(p, func() (geojson.Coordinate, error) {
+			// points may or may not be wrapped in parentheses
+			if p.peek().kind == tokLParen {
+				return p.parenthesized(p.parseCoordinate)
+			}
+			return p.parseCoordinate()
+		})
+		return geojson.MultiPoint(points), err
+	case t.is("MULTILINESTRING"):
+		lines, err := parseSeq(p, p.parseCoordinates)
+		return geojson.MultiLineString(lines), err
+	case t.is("MULTIPOLYGON"):
+		polys, err := parseSeq(p, p.parsePolygon)
+		return geojson.MultiPolygon(polys), err
+	case t.is("GEOMETRYCOLLECTION"):
+		geos, err := parseSeq(p, p.parseWKT)
+		return geojson.GeometryCollection{GT: geojson.GeometryCollectionType, Geometries: geos}, err
+	case t.is("BBOX"):
+		nums, err := parseSeq(p, p.parseNumber)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(nums) != 4 {
+			return nil, p.errorf(t, "BBOX needs 4 numbers, got %d", len(nums))
+		}
+
+		return geojson.BoundingBox{
+			MinLongitude: nums[0],
+			MinLatitude:  nums[1],
+			MaxLongitude: nums[2],
+			MaxLatitude:  nums[3],
+		}.Polygon(), nil
+	}
+
+	return nil, p.errorf(t, "expected a WKT geometry, got %s", t)
+}
+
+func (p *parser) parseNumber() (float64, error) {
+	t, err := p.expect(tokNumber, "a number")
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := strconv.ParseFloat(t.text, 64)
+	if err != nil {
+		return 0, p.errorf(t, "invalid number %s", t)
+	}
+
+	return f, nil
+}
+
+func (p *parser) parseCoordinate() (geojson.Coordinate, error) {
+	lon, err := p.parseNumber()
+	if err != nil {
+		return geojson.Coordinate{}, err
+	}
+
+	lat, err := p.parseNumber()
+	if err != nil {
+		return geojson.Coordinate{}, err
+	}
+
+	return geojson.Coordinate{Longitude: lon, Latitude: lat}, nil
+}
+
+func (p *parser) parseCoordinates() ([]geojson.Coordinate, error) {
+	return parseSeq(p, p.parseCoordinate)
+}
+
+func (p *parser) parsePolygon() ([][]geojson.Coordinate, error) {
+	return parseSeq(p, p.parseCoordinates)
+}
+
+// parenthesized parses (item)
+func (p *parser) parenthesized(item func() (geojson.Coordinate, error)) (geojson.Coordinate, error) {
+	if _, err := p.expect(tokLParen, "("); err != nil {
+		return geojson.Coordinate{}, err
+	}
+
+	c, err := item()
+	if err != nil {
+		return c, err
+	}
+
+	_, err = p.expect(tokRParen, ")")
+	return c, err
+}
+
+// parseSeq parses a parenthesized, comma separated list of items
+func parseSeq[T any](p *parser, item func() (T, error)) ([]T, error) {
+	if _, err := p.expect(tokLParen, "("); err != nil {
+		return nil, err
+	}
+
+	items := []T{}
+	for {
+		i, err := item()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+
+		t := p.next()
+		if t.kind == tokRParen {
+			return items, nil
+		}
+
+		if t.kind != tokComma {
+			return nil, p.errorf(t, "expected , or ), got %s", t)
+		}
+	}
+}
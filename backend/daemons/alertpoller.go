@@ -12,6 +12,7 @@ import (
 	"github.com/jghiloni/watchedsky-social/backend/config"
 	"github.com/jghiloni/watchedsky-social/backend/features"
 	"github.com/jghiloni/watchedsky-social/backend/logging"
+	"github.com/jghiloni/watchedsky-social/backend/store"
 )
 
 const apiURL = "https://api.weather.gov/alerts/active?status=actual&urgency=Immediate,Expected,Future,Unknown&certainty=Observed,Likely,Possible,Unknown"
@@ -30,7 +31,7 @@ func AlertPoller(ctx context.Context) error {
 	logger.Info("starting alert poller")
 
 	bskyClient := bsky.GetClient(ctx)
-	if bskyClient != nil {
+	if bskyClient == nil {
 		logger.Warn("bluesky config not set, exiting")
 		return nil
 	}
@@ -62,6 +63,16 @@ func AlertPoller(ctx context.Context) error {
 								break
 							}
 
+							if err = feat.Validate(); err != nil {
+								logger.Warn("quarantining invalid alert", slog.String("id", feat.ID), slog.Any("err", err))
+								if dbClient := store.GetStore(ctx); dbClient != nil {
+									if err = dbClient.QuarantineFeature(ctx, feat, "poller", err); err != nil {
+										logger.Error("error quarantining alert", slog.Any("err", err))
+									}
+								}
+								continue
+							}
+
 							if err = bskyClient.PostAlert(ctx, feat); err != nil {
 								logger.Error("error posting alert to PDS", slog.Any("err", err))
 							}
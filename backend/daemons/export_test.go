@@ -0,0 +1,3 @@
+package daemons
+
+var CacheMiddleware = cacheMiddleware
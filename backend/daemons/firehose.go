@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
 
@@ -15,8 +16,9 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/ipfs/go-cid"
 	"github.com/jghiloni/watchedsky-social/backend/config"
+	"github.com/jghiloni/watchedsky-social/backend/features"
 	"github.com/jghiloni/watchedsky-social/backend/logging"
-	"github.com/jghiloni/watchedsky-social/backend/mongo"
+	"github.com/jghiloni/watchedsky-social/backend/store"
 
 	"github.com/jghiloni/watchedsky-social/backend/bsky"
 )
@@ -38,13 +40,14 @@ func FirehoseNozzle(ctx context.Context) error {
 		return nil
 	}
 
-	dbClient := mongo.GetClient(ctx)
+	dbClient := store.GetStore(ctx)
 	if dbClient == nil {
-		logger.Warn("mongo config not set, exiting")
+		logger.Warn("feature store not configured, exiting")
 		return nil
 	}
 
 	me := bskyClient.Me()
+	priorities := features.DefaultPriorityModel.Merge(cfg.Priority)
 
 	con, _, err := websocket.DefaultDialer.Dial(firehoseURI, http.Header{})
 	if err != nil {
@@ -81,11 +84,50 @@ func FirehoseNozzle(ctx context.Context) error {
 								return err
 							}
 
-							if err = dbClient.AddFeatures(ctx, feat); err != nil {
+							if err = feat.Validate(); err != nil {
+								// a bad record shouldn't stop the stream
+								logger.Warn("quarantining invalid alert", slog.String("id", feat.ID), slog.Any("err", err))
+								return dbClient.QuarantineFeature(ctx, feat, "firehose", err)
+							}
+
+							var diff *features.AlertDiff
+							previous, found, err := dbClient.GetPreviousVersion(ctx, feat)
+							if err != nil {
+								return err
+							}
+
+							if found {
+								d := features.Diff(previous, feat)
+								diff = &d
+								feat.Properties["diff"] = d
+							}
+
+							feat.Properties["priority"] = priorities.Score(feat)
+
+							written, err := dbClient.AddFeatures(ctx, feat)
+							if err != nil {
+								return err
+							}
+
+							// a redelivered record has already been posted
+							if written.Skipped > 0 {
+								logger.Debug("alert already stored", slog.String("id", feat.ID))
+								return nil
+							}
+
+							// once an alert is on Bluesky, so are its updates
+							wasPosted := found && previous.Properties.StringValue("postUri") != ""
+							if !wasPosted && feat.Priority() < cfg.FirehoseNozzle.MinPostPriority {
+								logger.Debug("not posting low priority alert", slog.String("id", feat.ID), slog.Float64("priority", feat.Priority()))
+								return nil
+							}
+
+							postURI, err := bskyClient.SkeetAlert(ctx, alert, diff)
+							if err != nil {
 								return err
 							}
 
-							return bskyClient.SkeetAlert(ctx, alert)
+							return dbClient.SetPostURI(ctx, feat.ID, postURI)
 						}
 					}
 				}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"net/http"
 	"os"
-	"strconv"
 	"strings"
 	"time"
 
@@ -25,6 +24,8 @@ import (
 	feedhttp "github.com/jghiloni/go-bsky-feed-generator/http"
 	"github.com/jghiloni/watchedsky-social/backend/api"
 	"github.com/jghiloni/watchedsky-social/backend/config"
+	"github.com/jghiloni/watchedsky-social/backend/feeds"
+	"github.com/jghiloni/watchedsky-social/backend/mongo"
 	"github.com/jghiloni/watchedsky-social/frontend"
 )
 
@@ -56,8 +57,13 @@ func HTTPServerDaemon(ctx context.Context) error {
 		StreamRequestBody:     true,
 	})
 
+	cacheHandler, err := cacheMiddleware(ctx)
+	if err != nil {
+		return err
+	}
+
 	app.Use(
-		cacheMiddleware(ctx),
+		cacheHandler,
 		compress.New(),
 		etag.New(),
 		favicon.New(),
@@ -80,47 +86,69 @@ func HTTPServerDaemon(ctx context.Context) error {
 	)
 
 	apiGroup := app.Group("/api")
-	features := apiGroup.Group("/features", api.ListFeatures(ctx))
+	features := apiGroup.Group("/features")
+	features.Get("/", api.ListFeatures(ctx))
 	features.Get("/:id", api.GetFeature(ctx))
+	features.Get("/:id/history", api.GetFeatureHistory(ctx))
+	apiGroup.Get("/search", api.SearchFeatures(ctx))
+	apiGroup.Get("/stats", api.GetAlertStats(ctx))
 
-	app.Get("/xrpc/app.bsky.feed.getFeedSkeleton", adaptor.HTTPHandler(feedhttp.FeedHandler(ctx, nil)))
+	app.Get("/xrpc/app.bsky.feed.getFeedSkeleton", adaptor.HTTPHandler(feedhttp.FeedHandler(ctx, feeds.Feeds(ctx))))
 
 	return app.Listen(fmt.Sprintf(":%d", port))
 }
 
-func cacheMiddleware(ctx context.Context) fiber.Handler {
-	cfg := config.GetConfig(ctx)
+// openStatsExpiration is how long stats without an end time are cached
+const openStatsExpiration = 10 * time.Minute
 
-	host := ""
-	port := 0
-	username := ""
-	password := ""
+// cacheMiddleware caches responses in Mongo, connecting the same way the
+// feature store does. Without Mongo, responses are cached in memory
+func cacheMiddleware(ctx context.Context) (fiber.Handler, error) {
+	cfg := config.GetConfig(ctx)
 
-	hp := strings.Split(cfg.MongoDB.Host, ":")
-	host = hp[0]
-	if len(hp) > 1 {
-		port, _ = strconv.Atoi(hp[1])
+	var cacheStorage fiber.Storage
+	if cfg.MongoDB.Configured() {
+		uri, err := mongo.ConnectionURI(cfg.MongoDB)
+		if err != nil {
+			return nil, err
+		}
+
+		cacheStorage = mongodb.New(mongodb.Config{
+			ConnectionURI: uri,
+			Database:      cfg.HTTPServer.StorageDB,
+			Reset:         false,
+		})
 	}
 
-	username = cfg.MongoDB.Username
-	password = cfg.MongoDB.Password
-
-	cacheStorage := mongodb.New(mongodb.Config{
-		Host:     host,
-		Port:     port,
-		Username: username,
-		Password: password,
-		Reset:    false,
-	})
-
 	return cache.New(cache.Config{
 		CacheControl: true,
 		Expiration:   time.Hour,
 		Storage:      cacheStorage,
+		// pages' Link headers and the stats CSV's Content-Disposition have
+		// to be sent with cached responses too
+		StoreResponseHeaders: true,
+		ExpirationGenerator: func(c *fiber.Ctx, cfg *cache.Config) time.Duration {
+			// stats up to now change as alerts come in
+			if strings.HasPrefix(c.Path(), "/api/stats") && c.Query("to") == "" {
+				return openStatsExpiration
+			}
+
+			return cfg.Expiration
+		},
+		// the same URL can be rendered as JSON, CAP or CSV, so the Accept
+		// header has to be part of the key
+		KeyGenerator: func(c *fiber.Ctx) string {
+			return c.OriginalURL() + "|" + c.Get(fiber.HeaderAccept)
+		},
 		Next: func(c *fiber.Ctx) bool {
+			// alerts active right now change by the minute
+			if c.QueryBool("active") && c.Query("at") == "" {
+				return true
+			}
+
 			return strings.HasPrefix(c.Path(), "/api/search") || strings.HasPrefix(c.Path(), "/xrpc/")
 		},
-	})
+	}), nil
 }
 
 func healthcheckMiddleware() fiber.Handler {
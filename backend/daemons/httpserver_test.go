@@ -0,0 +1,73 @@
+package daemons_test
+
+import (
+	"context"
+	"net/http/httptest"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jghiloni/watchedsky-social/backend/api"
+	"github.com/jghiloni/watchedsky-social/backend/daemons"
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/store"
+	"github.com/jghiloni/watchedsky-social/backend/store/memory"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Response cache", func() {
+	var app *fiber.App
+
+	BeforeEach(func() {
+		s := memory.New()
+		ctx := store.WithStore(context.Background(), s)
+
+		for _, id := range []string{"a", "b", "c"} {
+			_, err := s.AddFeatures(ctx, features.Feature{ID: id, Properties: features.JSONObject{
+				"@type":       features.Alert,
+				"id":          id,
+				"messageType": "Alert",
+				"event":       "Tornado Warning",
+				"sent":        "2024-06-05T21:00:00Z",
+				"expires":     "2024-06-05T23:30:00Z",
+			}})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		cacheHandler, err := daemons.CacheMiddleware(ctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		app = fiber.New()
+		app.Use(cacheHandler)
+		app.Get("/api/features", api.ListFeatures(ctx))
+		app.Get("/api/stats", api.GetAlertStats(ctx))
+	})
+
+	// get requests path twice, and returns header from the second response,
+	// which should have come from the cache
+	get := func(path string, header string) string {
+		values := []string{}
+		for i := 0; i < 2; i++ {
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, path, nil))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(fiber.StatusOK))
+			values = append(values, resp.Header.Get(header))
+
+			if i == 1 {
+				Expect(resp.Header.Get("X-Cache")).To(Equal("hit"))
+			}
+		}
+
+		Expect(values[1]).To(Equal(values[0]))
+		return values[1]
+	}
+
+	It("Keeps the Link header of cached pages", func() {
+		link := get("/api/features?limit=1", fiber.HeaderLink)
+		Expect(link).To(ContainSubstring(`rel="next"`))
+	})
+
+	It("Keeps the Content-Disposition header of cached stats", func() {
+		disposition := get("/api/stats?format=csv&from=2024-06-01T00:00:00Z&to=2024-06-30T00:00:00Z", fiber.HeaderContentDisposition)
+		Expect(disposition).To(ContainSubstring("alert-stats.csv"))
+	})
+})
@@ -23,6 +23,8 @@ func setEnabledDaemons(ctx context.Context, cfg config.AppConfig) (context.Conte
 		"HTTPServer":     cfg.HTTPServer.Enabled,
 		"AlertPoller":    cfg.AlertPoller.Enabled,
 		"FirehoseNozzle": cfg.FirehoseNozzle.Enabled,
+		"Retention":      cfg.Retention.Enabled,
+		"Metrics":        cfg.Prometheus.Enabled,
 	}
 
 	return ctx, nil
@@ -0,0 +1,48 @@
+package daemons
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/jghiloni/watchedsky-social/backend/config"
+	"github.com/jghiloni/watchedsky-social/backend/logging"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const defaultMetricsPort = 9090
+
+// Metrics serves Prometheus metrics, such as the Mongo connection pool's, at
+// /metrics on their own port until ctx is done
+func Metrics(ctx context.Context) error {
+	cfg := config.GetConfig(ctx)
+	logger := logging.GetLogger(ctx)
+
+	if !cfg.Prometheus.Enabled {
+		return nil
+	}
+
+	port := int(cfg.Prometheus.Port)
+	if port == 0 {
+		port = defaultMetricsPort
+	}
+
+	logger.Info("starting metrics")
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	server := &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+		logger.Info("context done, exiting")
+		server.Close()
+	}()
+
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
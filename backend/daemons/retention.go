@@ -0,0 +1,62 @@
+package daemons
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jghiloni/watchedsky-social/backend/config"
+	"github.com/jghiloni/watchedsky-social/backend/logging"
+	"github.com/jghiloni/watchedsky-social/backend/store"
+)
+
+const defaultRetentionInterval = time.Hour
+
+// Retention sets the archive's retention windows, and then periodically
+// moves alerts that have ended into the archive
+func Retention(ctx context.Context) error {
+	cfg := config.GetConfig(ctx)
+	logger := logging.GetLogger(ctx)
+
+	if !cfg.Retention.Enabled {
+		return nil
+	}
+
+	logger.Info("starting retention")
+
+	dbClient := store.GetStore(ctx)
+	if dbClient == nil {
+		logger.Warn("feature store not configured, exiting")
+		return nil
+	}
+
+	windows, err := cfg.Retention.TypeWindows()
+	if err != nil {
+		return err
+	}
+
+	if err = dbClient.ApplyRetention(ctx, windows); err != nil {
+		return err
+	}
+
+	interval := cfg.Retention.Interval
+	if interval <= 0 {
+		interval = defaultRetentionInterval
+	}
+
+	for {
+		moved, err := dbClient.ArchiveEndedAlerts(ctx, time.Now())
+		if err != nil {
+			logger.Error("error archiving alerts", slog.Any("err", err))
+		} else if moved > 0 {
+			logger.Info("archived ended alerts", slog.Int("count", moved))
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Info("context done, exiting")
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package features
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// AlertReference identifies an earlier version of an alert. The NWS sends
+// references as objects, but older documents store them as bare identifiers
+type AlertReference struct {
+	ID         string `json:"@id,omitempty" bson:"@id,omitempty"`
+	Identifier string `json:"identifier" bson:"identifier"`
+	Sender     string `json:"sender,omitempty" bson:"sender,omitempty"`
+	Sent       string `json:"sent,omitempty" bson:"sent,omitempty"`
+}
+
+func (r *AlertReference) UnmarshalJSON(data []byte) error {
+	var id string
+	if err := json.Unmarshal(data, &id); err == nil {
+		*r = AlertReference{Identifier: AlertIdentifier(id)}
+		return nil
+	}
+
+	type reference AlertReference
+	return json.Unmarshal(data, (*reference)(r))
+}
+
+// AlertProperties are the properties of a wx:Alert feature
+type AlertProperties struct {
+	ID            string              `json:"id" bson:"id"`
+	Type          string              `json:"@type" bson:"@type"`
+	AreaDesc      string              `json:"areaDesc,omitempty" bson:"areaDesc,omitempty"`
+	Geocode       map[string][]string `json:"geocode,omitempty" bson:"geocode,omitempty"`
+	AffectedZones []string            `json:"affectedZones" bson:"affectedZones"`
+	References    []AlertReference    `json:"references,omitempty" bson:"references,omitempty"`
+	Sent          string              `json:"sent" bson:"sent"`
+	Effective     string              `json:"effective,omitempty" bson:"effective,omitempty"`
+	Onset         string              `json:"onset,omitempty" bson:"onset,omitempty"`
+	Expires       string              `json:"expires,omitempty" bson:"expires,omitempty"`
+	Ends          string              `json:"ends,omitempty" bson:"ends,omitempty"`
+	Status        string              `json:"status" bson:"status"`
+	MessageType   string              `json:"messageType" bson:"messageType"`
+	Category      string              `json:"category,omitempty" bson:"category,omitempty"`
+	Severity      string              `json:"severity" bson:"severity"`
+	Certainty     string              `json:"certainty" bson:"certainty"`
+	Urgency       string              `json:"urgency" bson:"urgency"`
+	Event         string              `json:"event" bson:"event"`
+	EventCode     map[string][]string `json:"eventCode,omitempty" bson:"eventCode,omitempty"`
+	Sender        string              `json:"sender,omitempty" bson:"sender,omitempty"`
+	SenderName    string              `json:"senderName,omitempty" bson:"senderName,omitempty"`
+	Headline      string              `json:"headline,omitempty" bson:"headline,omitempty"`
+	Description   string              `json:"description,omitempty" bson:"description,omitempty"`
+	Instruction   string              `json:"instruction,omitempty" bson:"instruction,omitempty"`
+	Language      string              `json:"language,omitempty" bson:"language,omitempty"`
+	Localizations []Localization      `json:"localizations,omitempty" bson:"localizations,omitempty"`
+	Response      string              `json:"response,omitempty" bson:"response,omitempty"`
+	Parameters    map[string][]string `json:"parameters,omitempty" bson:"parameters,omitempty"`
+	ReplacedBy    string              `json:"replacedBy,omitempty" bson:"replacedBy,omitempty"`
+	ReplacedAt    string              `json:"replacedAt,omitempty" bson:"replacedAt,omitempty"`
+	Hazards       *Hazards            `json:"hazards,omitempty" bson:"hazards,omitempty"`
+	PostURI       string              `json:"postUri,omitempty" bson:"postUri,omitempty"`
+	Priority      float64             `json:"priority,omitempty" bson:"priority,omitempty"`
+}
+
+// Validate checks that an alert has the fields needed to store and post it
+func (a AlertProperties) Validate() error {
+	errs := []error{}
+	if a.ID == "" {
+		errs = append(errs, errors.New("id is required"))
+	}
+
+	if a.Event == "" {
+		errs = append(errs, errors.New("event is required"))
+	}
+
+	times := map[string]string{
+		"sent":      a.Sent,
+		"effective": a.Effective,
+		"onset":     a.Onset,
+		"expires":   a.Expires,
+		"ends":      a.Ends,
+	}
+
+	for _, name := range []string{"sent", "effective", "onset", "expires", "ends"} {
+		value := times[name]
+		if value == "" {
+			if name == "sent" {
+				errs = append(errs, errors.New("sent is required"))
+			}
+			continue
+		}
+
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s is not an RFC 3339 timestamp: %q", name, value))
+		}
+	}
+
+	return errors.Join(errs...)
+}
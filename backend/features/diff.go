@@ -0,0 +1,265 @@
+package features
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jghiloni/watchedsky-social/backend/geojson"
+)
+
+// PropertyChange is a property that was added, removed or changed between
+// two versions of an alert. Changes inside parameters, geocode and eventCode
+// are reported per key, e.g. parameters.maxHailSize
+type PropertyChange struct {
+	Property string `json:"property" bson:"property"`
+	Old      any    `json:"old,omitempty" bson:"old,omitempty"`
+	New      any    `json:"new,omitempty" bson:"new,omitempty"`
+}
+
+// AlertDiff describes what changed from one version of an alert to the next
+type AlertDiff struct {
+	From        string           `json:"from" bson:"from"`
+	To          string           `json:"to" bson:"to"`
+	MessageType string           `json:"messageType" bson:"messageType"`
+	Changes     []PropertyChange `json:"changes" bson:"changes"`
+
+	// OldEnd and NewEnd are when each version ends, as given by EndsAt
+	OldEnd *time.Time `json:"oldEnd,omitempty" bson:"oldEnd,omitempty"`
+	NewEnd *time.Time `json:"newEnd,omitempty" bson:"newEnd,omitempty"`
+
+	ZonesAdded   []string `json:"zonesAdded" bson:"zonesAdded"`
+	ZonesRemoved []string `json:"zonesRemoved" bson:"zonesRemoved"`
+
+	OldAreaKm2  float64 `json:"oldAreaKm2" bson:"oldAreaKm2"`
+	NewAreaKm2  float64 `json:"newAreaKm2" bson:"newAreaKm2"`
+	AreaAdded   bool    `json:"areaAdded" bson:"areaAdded"`
+	AreaRemoved bool    `json:"areaRemoved" bson:"areaRemoved"`
+
+	// TextChanged lists which of the headline, description and instruction
+	// were revised
+	TextChanged []string `json:"textChanged" bson:"textChanged"`
+}
+
+// unversionedProperties change with every version, or are bookkeeping, so
+// they aren't reported as changes
+var unversionedProperties = map[string]bool{
+	"id":          true,
+	"@id":         true,
+	"sent":        true,
+	"messageType": true,
+	"references":  true,
+	"replacedBy":  true,
+	"replacedAt":  true,
+	"postUri":     true,
+	"diff":        true,
+	"hazards":     true,
+	"stormMotion": true,
+}
+
+// nestedProperties are diffed key by key
+var nestedProperties = map[string]bool{
+	"parameters": true,
+	"geocode":    true,
+	"eventCode":  true,
+}
+
+// textProperties are the free text of an alert
+var textProperties = []string{"headline", "description", "instruction"}
+
+// Diff compares an alert with the version that preceded it
+func Diff(previous Feature, current Feature) AlertDiff {
+	d := AlertDiff{
+		From:         previous.AlertID(),
+		To:           current.AlertID(),
+		MessageType:  current.Properties.StringValue("messageType"),
+		Changes:      []PropertyChange{},
+		ZonesAdded:   []string{},
+		ZonesRemoved: []string{},
+		TextChanged:  []string{},
+	}
+
+	oldProps, newProps := plainProperties(previous), plainProperties(current)
+	for _, key := range unionKeys(oldProps, newProps) {
+		if unversionedProperties[key] {
+			continue
+		}
+
+		oldValue, newValue := oldProps[key], newProps[key]
+		oldMap, oldIsMap := oldValue.(map[string]any)
+		newMap, newIsMap := newValue.(map[string]any)
+		if nestedProperties[key] && (oldIsMap || oldValue == nil) && (newIsMap || newValue == nil) {
+			for _, nested := range unionKeys(oldMap, newMap) {
+				d.addChange(key+"."+nested, oldMap[nested], newMap[nested])
+			}
+			continue
+		}
+
+		d.addChange(key, oldValue, newValue)
+	}
+
+	for _, key := range textProperties {
+		if !reflect.DeepEqual(oldProps[key], newProps[key]) {
+			d.TextChanged = append(d.TextChanged, key)
+		}
+	}
+
+	if end, ok := previous.EndsAt(); ok {
+		d.OldEnd = &end
+	}
+
+	if end, ok := current.EndsAt(); ok {
+		d.NewEnd = &end
+	}
+
+	oldZones, newZones := toSet(previous.AffectedZones()), toSet(current.AffectedZones())
+	for zone := range newZones {
+		if !oldZones[zone] {
+			d.ZonesAdded = append(d.ZonesAdded, zone)
+		}
+	}
+
+	for zone := range oldZones {
+		if !newZones[zone] {
+			d.ZonesRemoved = append(d.ZonesRemoved, zone)
+		}
+	}
+	sort.Strings(d.ZonesAdded)
+	sort.Strings(d.ZonesRemoved)
+
+	d.diffGeometry(previous.Geometry, current.Geometry)
+
+	return d
+}
+
+func (d *AlertDiff) addChange(property string, oldValue any, newValue any) {
+	if !reflect.DeepEqual(oldValue, newValue) {
+		d.Changes = append(d.Changes, PropertyChange{Property: property, Old: oldValue, New: newValue})
+	}
+}
+
+// diffGeometry compares the areas of two versions. A vertex of the new area
+// outside the old one means area was added, and vice versa. Vertices are
+// only a sample, but a polygon edited by the NWS always moves one
+func (d *AlertDiff) diffGeometry(previous geojson.Geometry, current geojson.Geometry) {
+	if previous != nil {
+		d.OldAreaKm2 = geojson.Area(previous)
+	}
+
+	if current != nil {
+		d.NewAreaKm2 = geojson.Area(current)
+	}
+
+	if d.OldAreaKm2 > 0 && d.NewAreaKm2 > 0 {
+		d.AreaAdded = anyUncovered(current, previous)
+		d.AreaRemoved = anyUncovered(previous, current)
+	}
+
+	d.AreaAdded = d.AreaAdded || len(d.ZonesAdded) > 0
+	d.AreaRemoved = d.AreaRemoved || len(d.ZonesRemoved) > 0
+}
+
+func anyUncovered(g geojson.Geometry, by geojson.Geometry) bool {
+	for _, v := range geojson.Vertices(g) {
+		if !geojson.Covers(by, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Changed returns true if anything other than bookkeeping changed
+func (d AlertDiff) Changed() bool {
+	return len(d.Changes) > 0 || d.AreaAdded || d.AreaRemoved
+}
+
+// Extended returns true if the new version ends later than the old one
+func (d AlertDiff) Extended() bool {
+	return d.OldEnd != nil && d.NewEnd != nil && d.NewEnd.After(*d.OldEnd)
+}
+
+// Shortened returns true if the new version ends earlier than the old one
+func (d AlertDiff) Shortened() bool {
+	return d.OldEnd != nil && d.NewEnd != nil && d.NewEnd.Before(*d.OldEnd)
+}
+
+// Summary describes the diff for use in post text, such as "extended until 5
+// PM, area reduced". Times are in the offset the alert was issued with
+func (d AlertDiff) Summary() string {
+	parts := []string{}
+	switch {
+	case d.Extended():
+		parts = append(parts, "extended until "+shortTime(*d.NewEnd))
+	case d.Shortened():
+		parts = append(parts, "now ends at "+shortTime(*d.NewEnd))
+	}
+
+	switch {
+	case d.AreaAdded && d.AreaRemoved:
+		parts = append(parts, "area changed")
+	case d.AreaAdded:
+		parts = append(parts, "area expanded")
+	case d.AreaRemoved:
+		parts = append(parts, "area reduced")
+	}
+
+	// the headline always changes, because it says when the update was sent
+	for _, key := range d.TextChanged {
+		if key != "headline" {
+			parts = append(parts, "text revised")
+			break
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// shortTime formats a time like 5 PM, or 5:30 PM
+func shortTime(t time.Time) string {
+	if t.Minute() == 0 {
+		return t.Format("3 PM")
+	}
+
+	return t.Format("3:04 PM")
+}
+
+// plainProperties converts properties to plain JSON values, so that values
+// decoded from BSON and from JSON compare equal
+func plainProperties(f Feature) map[string]any {
+	raw, err := json.Marshal(f.Properties)
+	if err != nil {
+		return map[string]any{}
+	}
+
+	props := map[string]any{}
+	if err = json.Unmarshal(raw, &props); err != nil {
+		return map[string]any{}
+	}
+
+	return props
+}
+
+func unionKeys(a map[string]any, b map[string]any) []string {
+	keys := map[string]bool{}
+	for k := range a {
+		keys[k] = true
+	}
+
+	for k := range b {
+		keys[k] = true
+	}
+
+	return sortedKeys(keys)
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+
+	return set
+}
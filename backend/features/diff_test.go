@@ -0,0 +1,81 @@
+package features_test
+
+import (
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/geojson"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Alert diffs", func() {
+	square := func(minLon, minLat, maxLon, maxLat float64) geojson.Polygon {
+		return geojson.Polygon{{
+			{Longitude: minLon, Latitude: minLat},
+			{Longitude: maxLon, Latitude: minLat},
+			{Longitude: maxLon, Latitude: maxLat},
+			{Longitude: minLon, Latitude: maxLat},
+			{Longitude: minLon, Latitude: minLat},
+		}}
+	}
+
+	original := features.Feature{
+		ID:       "urn:oid:2.49.0.1.840.0.1",
+		Geometry: square(-88.5, 41.5, -87.5, 42.5),
+		Properties: features.JSONObject{
+			"@type":         features.Alert,
+			"id":            "urn:oid:2.49.0.1.840.0.1",
+			"messageType":   "Alert",
+			"sent":          "2024-06-05T16:42:00-05:00",
+			"expires":       "2024-06-05T17:30:00-05:00",
+			"headline":      "Severe Thunderstorm Warning issued June 5 at 4:42PM CDT",
+			"description":   "HAZARD...60 mph wind gusts.",
+			"affectedZones": []any{"ILC031", "ILC043"},
+			"parameters":    map[string]any{"maxWindGust": []any{"60 MPH"}},
+		},
+	}
+
+	update := features.Feature{
+		ID:       "urn:oid:2.49.0.1.840.0.2",
+		Geometry: square(-88.5, 41.5, -88, 42.5),
+		Properties: features.JSONObject{
+			"@type":         features.Alert,
+			"id":            "urn:oid:2.49.0.1.840.0.2",
+			"messageType":   "Update",
+			"sent":          "2024-06-05T16:55:00-05:00",
+			"expires":       "2024-06-05T18:00:00-05:00",
+			"headline":      "Severe Thunderstorm Warning issued June 5 at 4:55PM CDT",
+			"description":   "HAZARD...60 mph wind gusts.",
+			"affectedZones": []any{"ILC031"},
+			"references":    []any{map[string]any{"identifier": "urn:oid:2.49.0.1.840.0.1"}},
+			"parameters":    map[string]any{"maxWindGust": []any{"70 MPH"}},
+		},
+	}
+
+	It("Reports property changes", func() {
+		d := features.Diff(original, update)
+		Expect(d.From).To(Equal("urn:oid:2.49.0.1.840.0.1"))
+		Expect(d.To).To(Equal("urn:oid:2.49.0.1.840.0.2"))
+		Expect(d.Changed()).To(BeTrue())
+
+		changed := []string{}
+		for _, c := range d.Changes {
+			changed = append(changed, c.Property)
+		}
+		Expect(changed).To(ConsistOf("affectedZones", "expires", "headline", "parameters.maxWindGust"))
+		Expect(d.TextChanged).To(Equal([]string{"headline"}))
+	})
+
+	It("Reports area and zone changes", func() {
+		d := features.Diff(original, update)
+		Expect(d.ZonesRemoved).To(Equal([]string{"ILC043"}))
+		Expect(d.ZonesAdded).To(BeEmpty())
+		Expect(d.AreaRemoved).To(BeTrue())
+		Expect(d.AreaAdded).To(BeFalse())
+		Expect(d.NewAreaKm2).To(BeNumerically("~", d.OldAreaKm2/2, 1))
+	})
+
+	It("Summarizes the changes", func() {
+		Expect(features.Diff(original, update).Summary()).To(Equal("extended until 6 PM, area reduced"))
+		Expect(features.Diff(update, original).Summary()).To(Equal("now ends at 5:30 PM, area expanded"))
+	})
+})
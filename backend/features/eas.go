@@ -0,0 +1,198 @@
+package features
+
+import (
+	"strings"
+
+	"github.com/jghiloni/watchedsky-social/backend/utils"
+)
+
+// EASEvent is an Emergency Alert System event code, as defined in 47 CFR
+// 11.31, along with the CAP severity used when an alert doesn't supply one
+type EASEvent struct {
+	Code     string `json:"code" bson:"code"`
+	Name     string `json:"name" bson:"name"`
+	Severity string `json:"severity" bson:"severity"`
+}
+
+// EAS originator codes, sent in the EAS-ORG parameter
+const (
+	EASOriginatorPrimaryEntryPoint = "PEP"
+	EASOriginatorCivilAuthorities  = "CIV"
+	EASOriginatorWeather           = "WXR"
+	EASOriginatorBroadcaster       = "EAS"
+)
+
+var easEvents = map[string]EASEvent{}
+
+func registerEASEvents(severity string, events map[string]string) {
+	for code, name := range events {
+		easEvents[code] = EASEvent{Code: code, Name: name, Severity: severity}
+	}
+}
+
+func init() {
+	registerEASEvents("Extreme", map[string]string{
+		"EAN": "Emergency Action Notification",
+		"EWW": "Extreme Wind Warning",
+		"NUW": "Nuclear Power Plant Warning",
+		"RHW": "Radiological Hazard Warning",
+		"TOR": "Tornado Warning",
+		"TSW": "Tsunami Warning",
+	})
+
+	registerEASEvents("Severe", map[string]string{
+		"AVW": "Avalanche Warning",
+		"BZW": "Blizzard Warning",
+		"CAE": "Child Abduction Emergency",
+		"CDW": "Civil Danger Warning",
+		"CEM": "Civil Emergency Message",
+		"CFW": "Coastal Flood Warning",
+		"DSW": "Dust Storm Warning",
+		"EQW": "Earthquake Warning",
+		"EVI": "Evacuation Immediate",
+		"FFW": "Flash Flood Warning",
+		"FLW": "Flood Warning",
+		"FRW": "Fire Warning",
+		"HMW": "Hazardous Materials Warning",
+		"HUW": "Hurricane Warning",
+		"HWW": "High Wind Warning",
+		"LAE": "Local Area Emergency",
+		"LEW": "Law Enforcement Warning",
+		"SMW": "Special Marine Warning",
+		"SPW": "Shelter in Place Warning",
+		"SQW": "Snow Squall Warning",
+		"SSW": "Storm Surge Warning",
+		"SVR": "Severe Thunderstorm Warning",
+		"TOE": "911 Telephone Outage Emergency",
+		"TRW": "Tropical Storm Warning",
+		"VOW": "Volcano Warning",
+		"WSW": "Winter Storm Warning",
+	})
+
+	registerEASEvents("Moderate", map[string]string{
+		"AVA": "Avalanche Watch",
+		"BLU": "Blue Alert",
+		"CFA": "Coastal Flood Watch",
+		"FFA": "Flash Flood Watch",
+		"FFS": "Flash Flood Statement",
+		"FLA": "Flood Watch",
+		"FLS": "Flood Statement",
+		"HLS": "Hurricane Statement",
+		"HUA": "Hurricane Watch",
+		"HWA": "High Wind Watch",
+		"MEP": "Missing and Endangered Persons",
+		"SSA": "Storm Surge Watch",
+		"SVA": "Severe Thunderstorm Watch",
+		"SVS": "Severe Weather Statement",
+		"TOA": "Tornado Watch",
+		"TRA": "Tropical Storm Watch",
+		"TSA": "Tsunami Watch",
+		"WSA": "Winter Storm Watch",
+	})
+
+	registerEASEvents("Minor", map[string]string{
+		"ADR": "Administrative Message",
+		"NIC": "National Information Center",
+		"NMN": "Network Message Notification",
+		"SPS": "Special Weather Statement",
+	})
+
+	registerEASEvents("Unknown", map[string]string{
+		"DMO": "Practice/Demo Warning",
+		"NPT": "National Periodic Test",
+		"RMT": "Required Monthly Test",
+		"RWT": "Required Weekly Test",
+	})
+}
+
+// LookupEASEvent returns the EAS event for a three letter event code
+func LookupEASEvent(code string) (EASEvent, bool) {
+	e, ok := easEvents[strings.ToUpper(strings.TrimSpace(code))]
+	return e, ok
+}
+
+// EASEvents returns every known EAS event
+func EASEvents() []EASEvent {
+	events := make([]EASEvent, 0, len(easEvents))
+	for _, e := range easEvents {
+		events = append(events, e)
+	}
+
+	return events
+}
+
+// codeValues returns the values of a property shaped like the NWS geocode and
+// eventCode objects, e.g. {"SAME": ["017197"], "UGC": ["ILC197"]}
+func (j JSONObject) codeValues(key string, name string) []string {
+	obj, ok := j[key].(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	if s, ok := obj[name].(string); ok {
+		return []string{s}
+	}
+
+	values, ok := utils.NormalizeSlice(obj[name])
+	if !ok {
+		return nil
+	}
+
+	strs := make([]string, 0, len(values))
+	for _, value := range values {
+		if s, ok := value.(string); ok {
+			strs = append(strs, s)
+		}
+	}
+
+	return strs
+}
+
+// EASEventCode returns the SAME event code of an alert, e.g. TOR
+func (f Feature) EASEventCode() string {
+	codes := f.Properties.codeValues("eventCode", "SAME")
+	if len(codes) == 0 {
+		return ""
+	}
+
+	return codes[0]
+}
+
+// EASEvent returns the EAS event of an alert, if it has a known event code
+func (f Feature) EASEvent() (EASEvent, bool) {
+	return LookupEASEvent(f.EASEventCode())
+}
+
+// Parameter returns the first value of an NWS alert parameter
+func (f Feature) Parameter(name string) string {
+	values := f.Properties.codeValues("parameters", name)
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+// EASOriginator returns the EAS-ORG parameter of an alert, e.g. WXR
+func (f Feature) EASOriginator() string {
+	return f.Parameter("EAS-ORG")
+}
+
+// SAMECodes returns the 6 digit SAME geocodes of an alert
+func (f Feature) SAMECodes() []string {
+	return f.Properties.codeValues("geocode", "SAME")
+}
+
+// Counties returns the counties an alert covers, as resolved from its SAME
+// geocodes. Codes that cover an entire state resolve to the state entry
+func (f Feature) Counties() []FIPSCounty {
+	codes := f.SAMECodes()
+	counties := make([]FIPSCounty, 0, len(codes))
+	for _, code := range codes {
+		if c, ok := LookupSAME(code); ok {
+			counties = append(counties, c)
+		}
+	}
+
+	return counties
+}
@@ -10,8 +10,10 @@ import (
 )
 
 const (
-	Alert string = "wx:Alert"
-	Zone  string = "wx:Zone"
+	Alert  string = "wx:Alert"
+	Zone   string = "wx:Zone"
+	County string = "wx:County"
+	Office string = "wx:Office"
 
 	CollectionName string = "features"
 )
@@ -33,46 +35,28 @@ func (j JSONObject) FloatValue(key string) float64 {
 	return f
 }
 
+// TimeValue parses an RFC 3339 timestamp property. The second return value is
+// false if the property is missing or isn't a valid timestamp
+func (j JSONObject) TimeValue(key string) (time.Time, bool) {
+	s := j.StringValue(key)
+	if s == "" {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	return t, err == nil
+}
+
 type Feature struct {
 	ID         string           `json:"id" bson:"_id"`
 	Geometry   geojson.Geometry `json:"geometry"`
 	Properties JSONObject       `json:"properties"`
 }
 
+// Features are sorted with SortByTime, which parses each timestamp once
+// rather than on every comparison
 type Features []Feature
 
-func (f Features) Len() int {
-	return len(f)
-}
-
-func (f Features) Less(i, j int) bool {
-	// sort by sent time descending
-	t1s, ok1 := f[i].Properties["sent"].(string)
-	t2s, ok2 := f[j].Properties["sent"].(string)
-
-	var t1, t2 = time.UnixMicro(0), time.UnixMicro(0)
-	var err error
-	if ok1 {
-		t1, err = time.Parse(time.RFC3339, t1s)
-		if err != nil {
-			return false
-		}
-	}
-
-	if ok2 {
-		t2, err = time.Parse(time.RFC3339, t2s)
-		if err != nil {
-			return false
-		}
-	}
-
-	return t1.After(t2)
-}
-
-func (f Features) Swap(i, j int) {
-	f[i], f[j] = f[j], f[i]
-}
-
 type FeatureCollection struct {
 	Features Features `json:"features"`
 }
@@ -0,0 +1,123 @@
+package features
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// fipsData is a CSV of state,state_fips,county_fips,name. Rows with a county
+// FIPS of 000 describe an entire state. It is generated by cmd/fipsgen from
+// the Census Bureau's national county list
+//
+//go:embed data/fips.csv
+var fipsData string
+
+// FIPSCounty is a county (or county equivalent) identified by its FIPS code. A
+// FIPSCounty with a CountyFIPS of 000 represents an entire state
+type FIPSCounty struct {
+	FIPS       string `json:"fips" bson:"fips"`
+	State      string `json:"state" bson:"state"`
+	StateFIPS  string `json:"stateFips" bson:"stateFips"`
+	CountyFIPS string `json:"countyFips" bson:"countyFips"`
+	Name       string `json:"name" bson:"name"`
+}
+
+// WholeState returns true if c describes an entire state
+func (c FIPSCounty) WholeState() bool {
+	return c.CountyFIPS == "000"
+}
+
+var (
+	fipsOnce     sync.Once
+	fipsCounties map[string]FIPSCounty
+	fipsStates   map[string]string
+	fipsPostal   map[string]string
+	fipsErr      error
+)
+
+func loadFIPS() {
+	fipsCounties = map[string]FIPSCounty{}
+	fipsStates = map[string]string{}
+	fipsPostal = map[string]string{}
+
+	r := csv.NewReader(strings.NewReader(fipsData))
+	r.FieldsPerRecord = 4
+
+	// skip the header
+	if _, fipsErr = r.Read(); fipsErr != nil {
+		return
+	}
+
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return
+		}
+
+		if err != nil {
+			fipsErr = fmt.Errorf("invalid FIPS dataset: %w", err)
+			return
+		}
+
+		c := FIPSCounty{
+			State:      rec[0],
+			StateFIPS:  rec[1],
+			CountyFIPS: rec[2],
+			FIPS:       rec[1] + rec[2],
+			Name:       rec[3],
+		}
+
+		fipsCounties[c.FIPS] = c
+		fipsStates[c.State] = c.StateFIPS
+		fipsPostal[c.StateFIPS] = c.State
+	}
+}
+
+// LookupFIPS returns the county for a 5 digit state+county FIPS code
+func LookupFIPS(fips string) (FIPSCounty, bool) {
+	fipsOnce.Do(loadFIPS)
+	if fipsErr != nil {
+		return FIPSCounty{}, false
+	}
+
+	c, ok := fipsCounties[fips]
+	return c, ok
+}
+
+// LookupSAME returns the county for a 6 digit SAME geocode. The first digit
+// of a SAME code identifies a subdivision of the county and is ignored
+func LookupSAME(same string) (FIPSCounty, bool) {
+	if len(same) != 6 {
+		return FIPSCounty{}, false
+	}
+
+	return LookupFIPS(same[1:])
+}
+
+// StateFIPS returns the 2 digit FIPS code of a state, by its postal
+// abbreviation
+func StateFIPS(state string) (string, bool) {
+	fipsOnce.Do(loadFIPS)
+	if fipsErr != nil {
+		return "", false
+	}
+
+	fips, ok := fipsStates[strings.ToUpper(state)]
+	return fips, ok
+}
+
+// StateAbbreviation returns the postal abbreviation of a state, by its 2
+// digit FIPS code
+func StateAbbreviation(fips string) (string, bool) {
+	fipsOnce.Do(loadFIPS)
+	if fipsErr != nil {
+		return "", false
+	}
+
+	state, ok := fipsPostal[fips]
+	return state, ok
+}
@@ -0,0 +1,46 @@
+package features_test
+
+import (
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FIPS codes", func() {
+	It("Looks up whole states", func() {
+		c, ok := features.LookupFIPS("17000")
+		Expect(ok).To(BeTrue())
+		Expect(c).To(Equal(features.FIPSCounty{FIPS: "17000", State: "IL", StateFIPS: "17", CountyFIPS: "000", Name: "Illinois"}))
+		Expect(c.WholeState()).To(BeTrue())
+	})
+
+	It("Ignores the subdivision digit of SAME codes", func() {
+		c, ok := features.LookupSAME("917000")
+		Expect(ok).To(BeTrue())
+		Expect(c.State).To(Equal("IL"))
+
+		_, ok = features.LookupSAME("17000")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("Converts between state FIPS codes and postal abbreviations", func() {
+		fips, ok := features.StateFIPS("il")
+		Expect(ok).To(BeTrue())
+		Expect(fips).To(Equal("17"))
+
+		state, ok := features.StateAbbreviation("17")
+		Expect(ok).To(BeTrue())
+		Expect(state).To(Equal("IL"))
+	})
+
+	It("Doesn't find unknown codes", func() {
+		_, ok := features.LookupFIPS("99999")
+		Expect(ok).To(BeFalse())
+
+		_, ok = features.StateFIPS("XX")
+		Expect(ok).To(BeFalse())
+
+		_, ok = features.StateAbbreviation("99")
+		Expect(ok).To(BeFalse())
+	})
+})
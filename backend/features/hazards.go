@@ -0,0 +1,275 @@
+package features
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jghiloni/watchedsky-social/backend/utils"
+)
+
+// HazardTag is a broad category of hazard, used for filtering
+type HazardTag string
+
+const (
+	HazardTornado HazardTag = "tornado"
+	HazardHail    HazardTag = "hail"
+	HazardWind    HazardTag = "wind"
+	HazardFlood   HazardTag = "flood"
+	HazardSnow    HazardTag = "snow"
+	HazardIce     HazardTag = "ice"
+	HazardFire    HazardTag = "fire"
+	HazardHeat    HazardTag = "heat"
+	HazardCold    HazardTag = "cold"
+	HazardSurge   HazardTag = "surge"
+	HazardTsunami HazardTag = "tsunami"
+	HazardMarine  HazardTag = "marine"
+)
+
+// Hazards are the structured hazards of an alert, taken from the NWS
+// parameters and the HAZARD/SOURCE/IMPACT sections of its description
+type Hazards struct {
+	Tags                     []HazardTag `json:"tags" bson:"tags"`
+	Hazard                   string      `json:"hazard,omitempty" bson:"hazard,omitempty"`
+	Source                   string      `json:"source,omitempty" bson:"source,omitempty"`
+	Impact                   string      `json:"impact,omitempty" bson:"impact,omitempty"`
+	MaxWindGustMPH           float64     `json:"maxWindGustMph,omitempty" bson:"maxWindGustMph,omitempty"`
+	MaxHailSizeInches        float64     `json:"maxHailSizeIn,omitempty" bson:"maxHailSizeIn,omitempty"`
+	TornadoDetection         string      `json:"tornadoDetection,omitempty" bson:"tornadoDetection,omitempty"`
+	TornadoDamageThreat      string      `json:"tornadoDamageThreat,omitempty" bson:"tornadoDamageThreat,omitempty"`
+	ThunderstormDamageThreat string      `json:"thunderstormDamageThreat,omitempty" bson:"thunderstormDamageThreat,omitempty"`
+	FlashFloodDetection      string      `json:"flashFloodDetection,omitempty" bson:"flashFloodDetection,omitempty"`
+	FlashFloodDamageThreat   string      `json:"flashFloodDamageThreat,omitempty" bson:"flashFloodDamageThreat,omitempty"`
+}
+
+// HazardParameters are the NWS alert parameters that describe hazards
+var HazardParameters = []string{
+	"maxWindGust",
+	"maxHailSize",
+	"tornadoDetection",
+	"tornadoDamageThreat",
+	"thunderstormDamageThreat",
+	"flashFloodDetection",
+	"flashFloodDamageThreat",
+}
+
+// hailSizes maps the objects the NWS compares hail to onto their diameter in
+// inches
+var hailSizes = map[string]float64{
+	"pea":         0.25,
+	"penny":       0.75,
+	"nickel":      0.88,
+	"quarter":     1.00,
+	"half dollar": 1.25,
+	"ping pong":   1.50,
+	"golf ball":   1.75,
+	"hen egg":     2.00,
+	"tennis ball": 2.50,
+	"baseball":    2.75,
+	"tea cup":     3.00,
+	"grapefruit":  4.00,
+	"softball":    4.25,
+}
+
+// eventTags maps words in an event name or hazard description onto hazard
+// tags. Keywords only match at the start of a word, so "ice" doesn't match
+// "notice"
+var eventTags = []struct {
+	keyword string
+	tag     HazardTag
+}{
+	{"tornado", HazardTornado},
+	{"hail", HazardHail},
+	{"wind", HazardWind},
+	{"thunderstorm", HazardWind},
+	{"flood", HazardFlood},
+	{"hydrologic", HazardFlood},
+	{"snow", HazardSnow},
+	{"blizzard", HazardSnow},
+	{"winter", HazardSnow},
+	{"ice", HazardIce},
+	{"freezing", HazardIce},
+	{"fire", HazardFire},
+	{"red flag", HazardFire},
+	{"heat", HazardHeat},
+	{"cold", HazardCold},
+	{"freeze", HazardCold},
+	{"frost", HazardCold},
+	{"chill", HazardCold},
+	{"surge", HazardSurge},
+	{"tsunami", HazardTsunami},
+	{"marine", HazardMarine},
+	{"small craft", HazardMarine},
+	{"gale", HazardMarine},
+}
+
+var (
+	sectionPattern  = regexp.MustCompile(`(?s)\b(HAZARD|SOURCE|IMPACT)\.\.\.(.*?)(?:\n\s*\n|\z)`)
+	mphPattern      = regexp.MustCompile(`(?i)(\d+)\s*(mph|kts?|knots)`)
+	hailSizePattern = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)\s*(?:inch|in)\b`)
+	whitespace      = regexp.MustCompile(`\s+`)
+)
+
+var eventTagPatterns = func() []*regexp.Regexp {
+	patterns := make([]*regexp.Regexp, 0, len(eventTags))
+	for _, et := range eventTags {
+		patterns = append(patterns, regexp.MustCompile(`(?i)\b`+regexp.QuoteMeta(et.keyword)))
+	}
+
+	return patterns
+}()
+
+// Hazards extracts the structured hazards of an alert
+func (f Feature) Hazards() Hazards {
+	params := map[string]string{}
+	for _, name := range HazardParameters {
+		if value := f.Parameter(name); value != "" {
+			params[name] = value
+		}
+	}
+
+	return ParseHazards(f.Properties.StringValue("event"), f.Properties.StringValue("description"), params)
+}
+
+// ParseHazards extracts hazards from an alert's event name, description and
+// parameters. The parameters win over anything found in the description
+func ParseHazards(event string, description string, params map[string]string) Hazards {
+	h := Hazards{
+		TornadoDetection:         strings.ToUpper(params["tornadoDetection"]),
+		TornadoDamageThreat:      strings.ToUpper(params["tornadoDamageThreat"]),
+		ThunderstormDamageThreat: strings.ToUpper(params["thunderstormDamageThreat"]),
+		FlashFloodDetection:      strings.ToUpper(params["flashFloodDetection"]),
+		FlashFloodDamageThreat:   strings.ToUpper(params["flashFloodDamageThreat"]),
+	}
+
+	for _, m := range sectionPattern.FindAllStringSubmatch(description, -1) {
+		text := strings.TrimSpace(whitespace.ReplaceAllString(m[2], " "))
+		switch m[1] {
+		case "HAZARD":
+			h.Hazard = text
+		case "SOURCE":
+			h.Source = text
+		case "IMPACT":
+			h.Impact = text
+		}
+	}
+
+	if gust, ok := parseWindSpeed(params["maxWindGust"]); ok {
+		h.MaxWindGustMPH = gust
+	} else if gust, ok := parseWindSpeed(h.Hazard); ok {
+		h.MaxWindGustMPH = gust
+	}
+
+	if size, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(strings.ToLower(params["maxHailSize"]), "in")), 64); err == nil {
+		h.MaxHailSizeInches = size
+	} else {
+		h.MaxHailSizeInches = parseHailSize(h.Hazard)
+	}
+
+	tags := map[HazardTag]bool{}
+	for i, et := range eventTags {
+		if eventTagPatterns[i].MatchString(event) || eventTagPatterns[i].MatchString(h.Hazard) {
+			tags[et.tag] = true
+		}
+	}
+
+	if h.TornadoDetection != "" || h.TornadoDamageThreat != "" {
+		tags[HazardTornado] = true
+	}
+
+	if h.MaxHailSizeInches > 0 {
+		tags[HazardHail] = true
+	}
+
+	if h.MaxWindGustMPH > 0 {
+		tags[HazardWind] = true
+	}
+
+	if h.FlashFloodDetection != "" || h.FlashFloodDamageThreat != "" {
+		tags[HazardFlood] = true
+	}
+
+	h.Tags = make([]HazardTag, 0, len(tags))
+	for tag := range tags {
+		h.Tags = append(h.Tags, tag)
+	}
+	sort.Slice(h.Tags, func(i, j int) bool { return h.Tags[i] < h.Tags[j] })
+
+	return h
+}
+
+func parseWindSpeed(s string) (float64, bool) {
+	m := mphPattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, false
+	}
+
+	speed, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, false
+	}
+
+	if !strings.EqualFold(m[2], "mph") {
+		// knots
+		speed = speed * 1.15078
+	}
+
+	return speed, true
+}
+
+func parseHailSize(s string) float64 {
+	if !strings.Contains(strings.ToLower(s), "hail") {
+		return 0
+	}
+
+	if m := hailSizePattern.FindStringSubmatch(s); m != nil {
+		size, _ := strconv.ParseFloat(m[1], 64)
+		return size
+	}
+
+	lower := strings.ToLower(s)
+	largest := 0.0
+	for name, size := range hailSizes {
+		if strings.Contains(lower, name) && size > largest {
+			largest = size
+		}
+	}
+
+	return largest
+}
+
+// HasTag returns true if the hazards include tag
+func (h Hazards) HasTag(tag HazardTag) bool {
+	for _, t := range h.Tags {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Summary is a short description of the hazards for use in post text, such
+// as "60 mph gusts, 1.00 in hail, tornado radar indicated"
+func (h Hazards) Summary() string {
+	parts := []string{}
+	if h.MaxWindGustMPH > 0 {
+		parts = append(parts, fmt.Sprintf("%.0f mph gusts", h.MaxWindGustMPH))
+	}
+
+	if h.MaxHailSizeInches > 0 {
+		parts = append(parts, fmt.Sprintf("%.2f in hail", h.MaxHailSizeInches))
+	}
+
+	if h.TornadoDetection != "" {
+		parts = append(parts, "tornado "+strings.ToLower(h.TornadoDetection))
+	}
+
+	if threat := utils.Coalesce(h.TornadoDamageThreat, h.ThunderstormDamageThreat, h.FlashFloodDamageThreat); threat != "" {
+		parts = append(parts, strings.ToLower(threat)+" damage threat")
+	}
+
+	return strings.Join(parts, ", ")
+}
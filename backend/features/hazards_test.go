@@ -0,0 +1,57 @@
+package features_test
+
+import (
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const svrDescription = `SVRLOT
+
+At 442 PM CDT, a severe thunderstorm was located near Joliet, moving
+northeast at 30 mph.
+
+HAZARD...60 mph wind gusts and quarter size hail.
+
+SOURCE...Radar indicated.
+
+IMPACT...Hail damage to vehicles is expected. Expect wind damage to
+roofs, siding, and trees.`
+
+var _ = Describe("Hazards", func() {
+	It("Parses the HAZARD, SOURCE and IMPACT sections", func() {
+		h := features.ParseHazards("Severe Thunderstorm Warning", svrDescription, nil)
+
+		Expect(h.Hazard).To(Equal("60 mph wind gusts and quarter size hail."))
+		Expect(h.Source).To(Equal("Radar indicated."))
+		Expect(h.Impact).To(Equal("Hail damage to vehicles is expected. Expect wind damage to roofs, siding, and trees."))
+		Expect(h.MaxWindGustMPH).To(Equal(60.0))
+		Expect(h.MaxHailSizeInches).To(Equal(1.0))
+		Expect(h.Tags).To(Equal([]features.HazardTag{features.HazardHail, features.HazardWind}))
+	})
+
+	It("Prefers the NWS parameters", func() {
+		f := features.Feature{
+			Properties: features.JSONObject{
+				"event":       "Tornado Warning",
+				"description": svrDescription,
+				"parameters": map[string]any{
+					"maxWindGust":      []any{"70 MPH"},
+					"maxHailSize":      []any{"1.75"},
+					"tornadoDetection": []any{"RADAR INDICATED"},
+				},
+			},
+		}
+
+		h := f.Hazards()
+		Expect(h.MaxWindGustMPH).To(Equal(70.0))
+		Expect(h.MaxHailSizeInches).To(Equal(1.75))
+		Expect(h.HasTag(features.HazardTornado)).To(BeTrue())
+		Expect(h.Summary()).To(Equal("70 mph gusts, 1.75 in hail, tornado radar indicated"))
+	})
+
+	It("Does not tag partial words", func() {
+		h := features.ParseHazards("Administrative Message", "HAZARD...Notice of service outage.", nil)
+		Expect(h.Tags).To(BeEmpty())
+	})
+})
@@ -0,0 +1,86 @@
+package features
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/jghiloni/watchedsky-social/backend/utils"
+)
+
+// DefaultLanguage is the language of alerts that don't say otherwise. It is
+// also the CAP default
+const DefaultLanguage = "en-US"
+
+// Localization is the text of an alert in one language. The NWS sends a
+// separate CAP <info> block for each language an alert is issued in
+type Localization struct {
+	Language    string `json:"language" bson:"language"`
+	Headline    string `json:"headline,omitempty" bson:"headline,omitempty"`
+	Description string `json:"description,omitempty" bson:"description,omitempty"`
+	Instruction string `json:"instruction,omitempty" bson:"instruction,omitempty"`
+}
+
+// Language returns the language of the headline, description and
+// instruction properties
+func (f Feature) Language() string {
+	return utils.Coalesce(f.Properties.StringValue("language"), DefaultLanguage)
+}
+
+// Localizations returns the text of an alert in every language it was
+// issued in, starting with the primary language
+func (f Feature) Localizations() []Localization {
+	locs := []Localization{{
+		Language:    f.Language(),
+		Headline:    f.Properties.StringValue("headline"),
+		Description: f.Properties.StringValue("description"),
+		Instruction: f.Properties.StringValue("instruction"),
+	}}
+
+	// localizations may be set from Go structs or decoded from BSON or JSON,
+	// so they are converted through JSON
+	raw, err := json.Marshal(f.Properties["localizations"])
+	if err != nil {
+		return locs
+	}
+
+	var others []Localization
+	if json.Unmarshal(raw, &others) != nil {
+		return locs
+	}
+
+	for _, loc := range others {
+		if loc.Language != "" && !strings.EqualFold(loc.Language, locs[0].Language) {
+			locs = append(locs, loc)
+		}
+	}
+
+	return locs
+}
+
+// Localized returns the text of an alert in the language closest to lang: an
+// exact match, then one with the same base language (es for es-US), and
+// finally the primary language
+func (f Feature) Localized(lang string) Localization {
+	locs := f.Localizations()
+	for _, loc := range locs {
+		if strings.EqualFold(loc.Language, lang) {
+			return loc
+		}
+	}
+
+	base := BaseLanguage(lang)
+	for _, loc := range locs {
+		if strings.EqualFold(BaseLanguage(loc.Language), base) {
+			return loc
+		}
+	}
+
+	return locs[0]
+}
+
+// BaseLanguage returns the primary subtag of a language tag, such as es for
+// es-US
+func BaseLanguage(lang string) string {
+	base, _, _ := strings.Cut(lang, "-")
+	return strings.ToLower(base)
+}
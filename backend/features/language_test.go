@@ -0,0 +1,52 @@
+package features_test
+
+import (
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var _ = Describe("Languages", func() {
+	alert := features.Feature{
+		ID: "a",
+		Properties: features.JSONObject{
+			"@type":       features.Alert,
+			"headline":    "Flood Warning issued",
+			"description": "Heavy rain is falling.",
+			"instruction": "Turn around, don't drown.",
+			"localizations": primitive.A{
+				primitive.M{
+					"language":    "es-US",
+					"headline":    "Aviso de inundación emitido",
+					"description": "Está lloviendo fuerte.",
+				},
+			},
+		},
+	}
+
+	It("Defaults to US English", func() {
+		Expect(alert.Language()).To(Equal(features.DefaultLanguage))
+	})
+
+	It("Lists the primary language first", func() {
+		locs := alert.Localizations()
+		Expect(locs).To(HaveLen(2))
+		Expect(locs[0].Language).To(Equal("en-US"))
+		Expect(locs[0].Instruction).To(Equal("Turn around, don't drown."))
+		Expect(locs[1].Language).To(Equal("es-US"))
+		Expect(locs[1].Headline).To(Equal("Aviso de inundación emitido"))
+	})
+
+	It("Picks the closest language", func() {
+		Expect(alert.Localized("es-US").Headline).To(Equal("Aviso de inundación emitido"))
+		Expect(alert.Localized("es").Headline).To(Equal("Aviso de inundación emitido"))
+		Expect(alert.Localized("EN-us").Headline).To(Equal("Flood Warning issued"))
+		Expect(alert.Localized("fr").Headline).To(Equal("Flood Warning issued"))
+	})
+
+	It("Extracts the base language", func() {
+		Expect(features.BaseLanguage("es-US")).To(Equal("es"))
+		Expect(features.BaseLanguage("EN")).To(Equal("en"))
+	})
+})
@@ -0,0 +1,183 @@
+package features
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jghiloni/watchedsky-social/backend/utils"
+)
+
+// AlertState is the state of an alert chain, as determined by its most
+// recent version
+type AlertState string
+
+const (
+	AlertStateActive    AlertState = "active"
+	AlertStateCancelled AlertState = "cancelled"
+	AlertStateExpired   AlertState = "expired"
+	AlertStateReplaced  AlertState = "replaced"
+)
+
+// AlertLink connects an alert version to the version that updated, cancelled
+// or replaced it
+type AlertLink struct {
+	From        string `json:"from" bson:"from"`
+	To          string `json:"to" bson:"to"`
+	MessageType string `json:"messageType" bson:"messageType"`
+}
+
+// AlertChain is every known version of an alert, oldest first
+type AlertChain struct {
+	ID       string      `json:"id" bson:"id"`
+	Current  string      `json:"current" bson:"current"`
+	State    AlertState  `json:"state" bson:"state"`
+	Versions Features    `json:"versions" bson:"versions"`
+	Links    []AlertLink `json:"links" bson:"links"`
+}
+
+// AlertIdentifier converts an alert ID or URL, such as
+// https://api.weather.gov/alerts/urn:oid:2.49.0.1.840.0.xxx, to its bare
+// identifier
+func AlertIdentifier(id string) string {
+	return id[strings.LastIndex(id, "/")+1:]
+}
+
+// AlertID returns the bare identifier of an alert
+func (f Feature) AlertID() string {
+	return AlertIdentifier(utils.Coalesce(f.Properties.StringValue("id"), f.ID))
+}
+
+// References returns the identifiers of the earlier versions an alert updates
+// or cancels
+func (f Feature) References() []string {
+	refs, ok := utils.NormalizeSlice(f.Properties["references"])
+	if !ok {
+		return nil
+	}
+
+	ids := make([]string, 0, len(refs))
+	for _, r := range refs {
+		switch ref := r.(type) {
+		case string:
+			ids = append(ids, AlertIdentifier(ref))
+		case map[string]any:
+			id, _ := ref["identifier"].(string)
+			if id == "" {
+				id, _ = ref["@id"].(string)
+			}
+
+			if id != "" {
+				ids = append(ids, AlertIdentifier(id))
+			}
+		}
+	}
+
+	return ids
+}
+
+// ReplacedBy returns the identifier of the alert that replaced this one, if
+// any
+func (f Feature) ReplacedBy() string {
+	if replacedBy := f.Properties.StringValue("replacedBy"); replacedBy != "" {
+		return AlertIdentifier(replacedBy)
+	}
+
+	return ""
+}
+
+// EndsAt returns when the hazard an alert describes ends, falling back to
+// when the alert message expires
+func (f Feature) EndsAt() (time.Time, bool) {
+	if ends, ok := f.Properties.TimeValue("ends"); ok {
+		return ends, true
+	}
+
+	return f.Properties.TimeValue("expires")
+}
+
+// BuildAlertChain links versions of an alert through their references and
+// replacedBy properties, and determines the state of the chain as of now
+func BuildAlertChain(versions Features, now time.Time) AlertChain {
+	byID := make(map[string]Feature, len(versions))
+	for _, v := range versions {
+		byID[v.AlertID()] = v
+	}
+
+	ordered := make(Features, 0, len(byID))
+	for _, v := range byID {
+		ordered = append(ordered, v)
+	}
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		ti, _ := ordered[i].Properties.TimeValue("sent")
+		tj, _ := ordered[j].Properties.TimeValue("sent")
+		if ti.Equal(tj) {
+			return ordered[i].AlertID() < ordered[j].AlertID()
+		}
+
+		return ti.Before(tj)
+	})
+
+	chain := AlertChain{
+		Versions: ordered,
+		Links:    []AlertLink{},
+	}
+
+	if len(ordered) == 0 {
+		return chain
+	}
+
+	linked := map[AlertLink]bool{}
+	superseded := map[string]bool{}
+	link := func(from string, to Feature) {
+		l := AlertLink{From: from, To: to.AlertID(), MessageType: to.Properties.StringValue("messageType")}
+		if !linked[l] {
+			linked[l] = true
+			superseded[from] = true
+			chain.Links = append(chain.Links, l)
+		}
+	}
+
+	for _, v := range ordered {
+		for _, ref := range v.References() {
+			if _, ok := byID[ref]; ok {
+				link(ref, v)
+			}
+		}
+
+		if next, ok := byID[v.ReplacedBy()]; ok {
+			link(v.AlertID(), next)
+		}
+	}
+
+	current := ordered[len(ordered)-1]
+	for i := len(ordered) - 1; i >= 0; i-- {
+		if !superseded[ordered[i].AlertID()] {
+			current = ordered[i]
+			break
+		}
+	}
+
+	chain.ID = ordered[0].AlertID()
+	chain.Current = current.AlertID()
+	chain.State = current.alertState(now)
+
+	return chain
+}
+
+func (f Feature) alertState(now time.Time) AlertState {
+	if strings.EqualFold(f.Properties.StringValue("messageType"), "Cancel") {
+		return AlertStateCancelled
+	}
+
+	if f.ReplacedBy() != "" {
+		return AlertStateReplaced
+	}
+
+	if f.Expired(now) {
+		return AlertStateExpired
+	}
+
+	return AlertStateActive
+}
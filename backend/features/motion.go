@@ -0,0 +1,186 @@
+package features
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jghiloni/watchedsky-social/backend/geojson"
+)
+
+// knotsToKmh converts knots to kilometers per hour
+const knotsToKmh = 1.852
+
+// DefaultProjectionMinutes are the offsets at which storm positions are
+// projected
+var DefaultProjectionMinutes = []int{15, 30, 60}
+
+// StormMotion is a parsed eventMotionDescription parameter, such as
+// "2024-06-05T21:42:00-00:00...storm...235DEG...30KT...4120 8810"
+type StormMotion struct {
+	Time       time.Time            `json:"time" bson:"time"`
+	Descriptor string               `json:"descriptor" bson:"descriptor"`
+	Direction  float64              `json:"direction" bson:"direction"`
+	SpeedKnots float64              `json:"speedKnots" bson:"speedKnots"`
+	Locations  []geojson.Coordinate `json:"locations" bson:"locations"`
+}
+
+// ProjectedPosition is where a storm is expected to be at a given offset
+// from the observation time. Geometry is a Point for a single storm, and a
+// LineString for a line of storms
+type ProjectedPosition struct {
+	Minutes  int              `json:"minutes" bson:"minutes"`
+	Time     time.Time        `json:"time" bson:"time"`
+	Geometry geojson.Geometry `json:"geometry" bson:"geometry"`
+}
+
+// StormProjection is the projected track of a storm. Path is a LineString
+// for a single storm, and a MultiLineString with one track per location for a
+// line of storms
+type StormProjection struct {
+	Motion    StormMotion         `json:"motion" bson:"motion"`
+	Heading   float64             `json:"heading" bson:"heading"`
+	Path      geojson.Geometry    `json:"path" bson:"path"`
+	Positions []ProjectedPosition `json:"positions" bson:"positions"`
+}
+
+// ParseStormMotion parses an eventMotionDescription. The direction is the
+// one the storm is moving from, in degrees, and locations are pairs of
+// latitude and west longitude in hundredths of a degree
+func ParseStormMotion(s string) (StormMotion, error) {
+	parts := strings.Split(s, "...")
+	if len(parts) != 5 {
+		return StormMotion{}, fmt.Errorf("expected 5 parts in the event motion, got %d", len(parts))
+	}
+
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(parts[0]))
+	if err != nil {
+		return StormMotion{}, fmt.Errorf("invalid event motion time: %w", err)
+	}
+
+	dir, err := parseSuffixedNumber(parts[2], "DEG")
+	if err != nil {
+		return StormMotion{}, fmt.Errorf("invalid event motion direction: %w", err)
+	}
+
+	speed, err := parseSuffixedNumber(parts[3], "KT")
+	if err != nil {
+		return StormMotion{}, fmt.Errorf("invalid event motion speed: %w", err)
+	}
+
+	locs := strings.Fields(parts[4])
+	if len(locs) == 0 || len(locs)%2 != 0 {
+		return StormMotion{}, fmt.Errorf("expected pairs of coordinates in the event motion, got %q", parts[4])
+	}
+
+	m := StormMotion{
+		Time:       t,
+		Descriptor: strings.TrimSpace(parts[1]),
+		Direction:  dir,
+		SpeedKnots: speed,
+		Locations:  make([]geojson.Coordinate, 0, len(locs)/2),
+	}
+
+	for i := 0; i < len(locs); i += 2 {
+		lat, err := strconv.ParseFloat(locs[i], 64)
+		if err != nil {
+			return StormMotion{}, fmt.Errorf("invalid event motion latitude %q", locs[i])
+		}
+
+		lon, err := strconv.ParseFloat(locs[i+1], 64)
+		if err != nil {
+			return StormMotion{}, fmt.Errorf("invalid event motion longitude %q", locs[i+1])
+		}
+
+		m.Locations = append(m.Locations, geojson.Coordinate{Latitude: lat / 100, Longitude: -lon / 100})
+	}
+
+	return m, nil
+}
+
+func parseSuffixedNumber(s string, suffix string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasSuffix(strings.ToUpper(s), suffix) {
+		return 0, fmt.Errorf("expected %q to end with %s", s, suffix)
+	}
+
+	return strconv.ParseFloat(s[:len(s)-len(suffix)], 64)
+}
+
+// Heading is the direction the storm is moving towards, in degrees
+func (m StormMotion) Heading() float64 {
+	return math.Mod(m.Direction+180, 360)
+}
+
+// PositionsAt returns where each storm location will be the given number of
+// minutes after the observation, assuming constant speed and direction
+func (m StormMotion) PositionsAt(minutes int) []geojson.Coordinate {
+	dist := m.SpeedKnots * knotsToKmh * float64(minutes) / 60
+	positions := make([]geojson.Coordinate, 0, len(m.Locations))
+	for _, loc := range m.Locations {
+		positions = append(positions, geojson.Destination(loc, m.Heading(), dist))
+	}
+
+	return positions
+}
+
+// Project computes the path of the storm and its positions at each offset,
+// in minutes. DefaultProjectionMinutes is used if no offsets are given
+func (m StormMotion) Project(minutes ...int) StormProjection {
+	if len(minutes) == 0 {
+		minutes = DefaultProjectionMinutes
+	}
+
+	p := StormProjection{
+		Motion:    m,
+		Heading:   m.Heading(),
+		Positions: make([]ProjectedPosition, 0, len(minutes)),
+	}
+
+	tracks := make([][]geojson.Coordinate, len(m.Locations))
+	for i, loc := range m.Locations {
+		tracks[i] = []geojson.Coordinate{loc}
+	}
+
+	for _, offset := range minutes {
+		positions := m.PositionsAt(offset)
+		for i, pos := range positions {
+			tracks[i] = append(tracks[i], pos)
+		}
+
+		var geo geojson.Geometry = geojson.LineString(positions)
+		if len(positions) == 1 {
+			geo = geojson.Point(positions[0])
+		}
+
+		p.Positions = append(p.Positions, ProjectedPosition{
+			Minutes:  offset,
+			Time:     m.Time.Add(time.Duration(offset) * time.Minute),
+			Geometry: geo,
+		})
+	}
+
+	if len(tracks) == 1 {
+		p.Path = geojson.LineString(tracks[0])
+	} else {
+		p.Path = geojson.MultiLineString(tracks)
+	}
+
+	return p
+}
+
+// StormMotion returns the parsed eventMotionDescription of an alert
+func (f Feature) StormMotion() (StormMotion, error) {
+	desc := f.Parameter("eventMotionDescription")
+	if desc == "" {
+		return StormMotion{}, ErrNoStormMotion
+	}
+
+	return ParseStormMotion(desc)
+}
+
+// ErrNoStormMotion is returned when an alert has no eventMotionDescription
+var ErrNoStormMotion = errors.New("alert has no event motion")
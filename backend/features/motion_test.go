@@ -0,0 +1,57 @@
+package features_test
+
+import (
+	"time"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/geojson"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("StormMotion", func() {
+	It("Parses the event motion description", func() {
+		m, e := features.ParseStormMotion("2024-06-05T21:42:00-00:00...storm...235DEG...30KT...4120 8810")
+		Expect(e).NotTo(HaveOccurred())
+
+		Expect(m.Time).To(BeTemporally("==", time.Date(2024, 6, 5, 21, 42, 0, 0, time.UTC)))
+		Expect(m.Descriptor).To(Equal("storm"))
+		Expect(m.Direction).To(Equal(235.0))
+		Expect(m.Heading()).To(Equal(55.0))
+		Expect(m.SpeedKnots).To(Equal(30.0))
+		Expect(m.Locations).To(Equal([]geojson.Coordinate{{Latitude: 41.2, Longitude: -88.1}}))
+	})
+
+	It("Rejects malformed descriptions", func() {
+		_, e := features.ParseStormMotion("2024-06-05T21:42:00-00:00...storm...235DEG...4120 8810")
+		Expect(e).To(HaveOccurred())
+	})
+
+	It("Projects a single storm along its heading", func() {
+		m, e := features.ParseStormMotion("2024-06-05T21:42:00-00:00...storm...270DEG...30KT...4120 8810")
+		Expect(e).NotTo(HaveOccurred())
+
+		p := m.Project()
+		Expect(p.Path).To(BeAssignableToTypeOf(geojson.LineString{}))
+		Expect(p.Path).To(HaveLen(4))
+		Expect(p.Positions).To(HaveLen(3))
+
+		hour := p.Positions[2]
+		Expect(hour.Minutes).To(Equal(60))
+		Expect(hour.Time).To(BeTemporally("==", m.Time.Add(time.Hour)))
+
+		pt, ok := hour.Geometry.(geojson.Point)
+		Expect(ok).To(BeTrue())
+		Expect(pt.Longitude).To(BeNumerically(">", -88.1))
+		Expect(geojson.Distance(m.Locations[0], geojson.Coordinate(pt))).To(BeNumerically("~", 30*1.852, 0.01))
+	})
+
+	It("Projects a line of storms", func() {
+		m, e := features.ParseStormMotion("2024-06-05T21:42:00-00:00...storms...235DEG...30KT...4120 8810 4100 8830")
+		Expect(e).NotTo(HaveOccurred())
+
+		p := m.Project(30)
+		Expect(p.Path).To(BeAssignableToTypeOf(geojson.MultiLineString{}))
+		Expect(p.Positions[0].Geometry).To(BeAssignableToTypeOf(geojson.LineString{}))
+	})
+})
@@ -0,0 +1,136 @@
+package features
+
+import "strings"
+
+// PriorityModel scores alerts so that the most dangerous ones can be listed
+// first, and minor ones kept off Bluesky. The base score is the sum of the
+// severity, urgency and certainty weights, unless the event has an override.
+// The highest matching hazard tag and damage threat weights are added to
+// the base score either way, so that a Tornado Emergency (a Tornado Warning
+// with a catastrophic damage threat) outranks an ordinary Tornado Warning
+type PriorityModel struct {
+	Severity      map[string]float64    `yaml:"severity"`
+	Urgency       map[string]float64    `yaml:"urgency"`
+	Certainty     map[string]float64    `yaml:"certainty"`
+	Events        map[string]float64    `yaml:"events"`
+	HazardTags    map[HazardTag]float64 `yaml:"hazard_tags"`
+	DamageThreats map[string]float64    `yaml:"damage_threats"`
+}
+
+// DefaultPriorityModel scores an Extreme, Immediate, Observed alert at 100
+// before any bonuses
+var DefaultPriorityModel = PriorityModel{
+	Severity: map[string]float64{
+		"Extreme":  40,
+		"Severe":   30,
+		"Moderate": 20,
+		"Minor":    10,
+	},
+	Urgency: map[string]float64{
+		"Immediate": 30,
+		"Expected":  20,
+		"Future":    10,
+	},
+	Certainty: map[string]float64{
+		"Observed": 30,
+		"Likely":   20,
+		"Possible": 10,
+	},
+	Events: map[string]float64{
+		"Special Weather Statement": 20,
+		"Air Quality Alert":         10,
+		"Test Message":              0,
+	},
+	HazardTags: map[HazardTag]float64{
+		HazardTornado: 10,
+		HazardTsunami: 10,
+		HazardSurge:   5,
+	},
+	DamageThreats: map[string]float64{
+		"CONSIDERABLE": 20,
+		"DESTRUCTIVE":  30,
+		"CATASTROPHIC": 50,
+	},
+}
+
+// Merge returns a copy of m with the weights in overrides replacing or
+// adding to its own
+func (m PriorityModel) Merge(overrides PriorityModel) PriorityModel {
+	return PriorityModel{
+		Severity:      mergeWeights(m.Severity, overrides.Severity),
+		Urgency:       mergeWeights(m.Urgency, overrides.Urgency),
+		Certainty:     mergeWeights(m.Certainty, overrides.Certainty),
+		Events:        mergeWeights(m.Events, overrides.Events),
+		HazardTags:    mergeWeights(m.HazardTags, overrides.HazardTags),
+		DamageThreats: mergeWeights(m.DamageThreats, overrides.DamageThreats),
+	}
+}
+
+func mergeWeights[K comparable](base map[K]float64, overrides map[K]float64) map[K]float64 {
+	merged := make(map[K]float64, len(base)+len(overrides))
+	for k, v := range base {
+		merged[k] = v
+	}
+
+	for k, v := range overrides {
+		merged[k] = v
+	}
+
+	return merged
+}
+
+// Score returns the priority of an alert. Anything else scores 0
+func (m PriorityModel) Score(f Feature) float64 {
+	if f.Type() != Alert {
+		return 0
+	}
+
+	props := f.Properties
+	score, ok := lookupFold(m.Events, props.StringValue("event"))
+	if !ok {
+		severity, _ := lookupFold(m.Severity, props.StringValue("severity"))
+		urgency, _ := lookupFold(m.Urgency, props.StringValue("urgency"))
+		certainty, _ := lookupFold(m.Certainty, props.StringValue("certainty"))
+		score = severity + urgency + certainty
+	}
+
+	hazards := f.Hazards()
+	tagBonus := 0.0
+	for _, tag := range hazards.Tags {
+		tagBonus = max(tagBonus, m.HazardTags[tag])
+	}
+
+	threatBonus := 0.0
+	for _, threat := range []string{hazards.TornadoDamageThreat, hazards.ThunderstormDamageThreat, hazards.FlashFloodDamageThreat} {
+		if weight, ok := lookupFold(m.DamageThreats, threat); ok {
+			threatBonus = max(threatBonus, weight)
+		}
+	}
+
+	return score + tagBonus + threatBonus
+}
+
+// lookupFold looks a key up ignoring case, since the NWS isn't consistent
+// about it
+func lookupFold(weights map[string]float64, key string) (float64, bool) {
+	if key == "" {
+		return 0, false
+	}
+
+	if w, ok := weights[key]; ok {
+		return w, true
+	}
+
+	for k, w := range weights {
+		if strings.EqualFold(k, key) {
+			return w, true
+		}
+	}
+
+	return 0, false
+}
+
+// Priority returns the score stored on an alert when it was ingested
+func (f Feature) Priority() float64 {
+	return f.Properties.FloatValue("priority")
+}
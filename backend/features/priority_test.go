@@ -0,0 +1,61 @@
+package features_test
+
+import (
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Priority", func() {
+	alert := func(event string, severity string, urgency string, certainty string, params map[string]any) features.Feature {
+		return features.Feature{
+			ID: event,
+			Properties: features.JSONObject{
+				"@type":      features.Alert,
+				"event":      event,
+				"severity":   severity,
+				"urgency":    urgency,
+				"certainty":  certainty,
+				"parameters": params,
+			},
+		}
+	}
+
+	model := features.DefaultPriorityModel
+
+	It("Combines severity, urgency and certainty", func() {
+		Expect(model.Score(alert("Flood Advisory", "Minor", "Expected", "Likely", nil))).To(Equal(50.0))
+		Expect(model.Score(alert("Severe Thunderstorm Warning", "Severe", "Immediate", "Observed", nil))).To(Equal(90.0))
+	})
+
+	It("Ranks a Tornado Emergency above a Tornado Warning above a Special Weather Statement", func() {
+		statement := model.Score(alert("Special Weather Statement", "Moderate", "Expected", "Observed", nil))
+		warning := model.Score(alert("Tornado Warning", "Extreme", "Immediate", "Observed", map[string]any{
+			"tornadoDetection": []any{"OBSERVED"},
+		}))
+		emergency := model.Score(alert("Tornado Warning", "Extreme", "Immediate", "Observed", map[string]any{
+			"tornadoDetection":    []any{"OBSERVED"},
+			"tornadoDamageThreat": []any{"CATASTROPHIC"},
+		}))
+
+		Expect(statement).To(Equal(20.0))
+		Expect(warning).To(Equal(110.0))
+		Expect(emergency).To(Equal(160.0))
+	})
+
+	It("Merges overrides into the defaults", func() {
+		merged := model.Merge(features.PriorityModel{
+			Severity: map[string]float64{"Minor": 0},
+			Events:   map[string]float64{"Flood Advisory": 5},
+		})
+
+		Expect(merged.Severity).To(HaveKeyWithValue("Minor", 0.0))
+		Expect(merged.Severity).To(HaveKeyWithValue("Extreme", 40.0))
+		Expect(merged.Score(alert("Flood Advisory", "Minor", "Expected", "Likely", nil))).To(Equal(5.0))
+		Expect(model.Severity).To(HaveKeyWithValue("Minor", 10.0))
+	})
+
+	It("Only scores alerts", func() {
+		Expect(model.Score(features.Feature{Properties: features.JSONObject{"@type": features.Zone}})).To(BeZero())
+	})
+})
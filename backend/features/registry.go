@@ -0,0 +1,197 @@
+package features
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ErrUnknownType is returned when a feature's @type hasn't been registered
+var ErrUnknownType = errors.New("unknown feature type")
+
+// Index is an index on the features collection that a feature type needs.
+// Keys are full document paths, e.g. properties.sent
+type Index struct {
+	Name   string
+	Keys   bson.D
+	Unique bool
+	Sparse bool
+}
+
+// TypeDefinition describes a feature @type: the struct its properties decode
+// into, how they are validated, and the indexes used to query it
+type TypeDefinition struct {
+	Type string
+
+	// New returns a pointer to an empty properties struct
+	New func() any
+
+	// Validate checks decoded properties, which are the value returned by New.
+	// It may be nil
+	Validate func(props any) error
+
+	Indexes []Index
+}
+
+type typeRegistry struct {
+	types map[string]TypeDefinition
+	mu    *sync.RWMutex
+}
+
+// Register adds or replaces a feature type
+func (r *typeRegistry) Register(def TypeDefinition) {
+	if def.Type == "" || def.New == nil {
+		panic("feature types need a name and a properties constructor")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.types[def.Type] = def
+}
+
+// Lookup returns the definition of a feature type
+func (r *typeRegistry) Lookup(featureType string) (TypeDefinition, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	def, ok := r.types[featureType]
+	return def, ok
+}
+
+// Named returns the type a name refers to: either the type itself, e.g.
+// wx:Alert, or its name without the prefix, e.g. Alert. Short names are
+// handy where a colon can't be used, as in environment variables
+func (r *typeRegistry) Named(name string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, ok := r.types[name]; ok {
+		return name, true
+	}
+
+	for featureType := range r.types {
+		if featureType[strings.LastIndex(featureType, ":")+1:] == name {
+			return featureType, true
+		}
+	}
+
+	return "", false
+}
+
+// All returns every registered type, ordered by name
+func (r *typeRegistry) All() []TypeDefinition {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	defs := make([]TypeDefinition, 0, len(r.types))
+	for _, def := range r.types {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Type < defs[j].Type })
+
+	return defs
+}
+
+// Types is the registry of known feature types
+var Types = &typeRegistry{
+	types: map[string]TypeDefinition{},
+	mu:    &sync.RWMutex{},
+}
+
+// Type returns the @type of a feature
+func (f Feature) Type() string {
+	return f.Properties.StringValue("@type")
+}
+
+// Typed decodes the properties of a feature into the struct registered for
+// its @type and validates them. The result is a pointer, e.g. *AlertProperties
+func (f Feature) Typed() (any, error) {
+	def, ok := Types.Lookup(f.Type())
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownType, f.Type())
+	}
+
+	props := def.New()
+	if err := f.DecodeProperties(props); err != nil {
+		return nil, fmt.Errorf("could not decode %s properties: %w", def.Type, err)
+	}
+
+	if def.Validate != nil {
+		if err := def.Validate(props); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", def.Type, err)
+		}
+	}
+
+	return props, nil
+}
+
+// DecodeAs decodes and validates the properties of a feature as T, which must
+// be the properties struct registered for the feature's @type
+func DecodeAs[T any](f Feature) (T, error) {
+	var zero T
+	props, err := f.Typed()
+	if err != nil {
+		return zero, err
+	}
+
+	typed, ok := props.(*T)
+	if !ok {
+		return zero, fmt.Errorf("%s properties are a %T, not a %T", f.Type(), props, zero)
+	}
+
+	return *typed, nil
+}
+
+// GeometryIndex is the 2dsphere index that geospatial queries use. Every type
+// with a geometry shares it
+var GeometryIndex = Index{Name: "geometry_2dsphere", Keys: bson.D{{Key: "geometry", Value: "2dsphere"}}}
+
+func init() {
+	Types.Register(TypeDefinition{
+		Type:     Alert,
+		New:      func() any { return &AlertProperties{} },
+		Validate: func(props any) error { return props.(*AlertProperties).Validate() },
+		Indexes: []Index{
+			{Name: "alert_sent", Keys: bson.D{{Key: "properties.@type", Value: 1}, {Key: "properties.sent", Value: -1}}},
+			{Name: "alert_id", Keys: bson.D{{Key: "properties.id", Value: 1}}},
+			{Name: "alert_references", Keys: bson.D{{Key: "properties.references.identifier", Value: 1}}},
+			{Name: "alert_same", Keys: bson.D{{Key: "properties.geocode.SAME", Value: 1}}},
+			{Name: "alert_event_code", Keys: bson.D{{Key: "properties.eventCode.SAME", Value: 1}}},
+			{Name: "alert_hazards", Keys: bson.D{{Key: "properties.hazards.tags", Value: 1}}},
+			{Name: "alert_priority", Keys: bson.D{{Key: "properties.priority", Value: -1}, {Key: "properties.sent", Value: -1}}},
+			GeometryIndex,
+		},
+	})
+
+	zone := TypeDefinition{
+		Type:     Zone,
+		New:      func() any { return &ZoneProperties{} },
+		Validate: func(props any) error { return props.(*ZoneProperties).Validate() },
+		Indexes: []Index{
+			{Name: "zone_type", Keys: bson.D{{Key: "properties.@type", Value: 1}, {Key: "properties.type", Value: 1}}},
+			{Name: "zone_state", Keys: bson.D{{Key: "properties.state", Value: 1}}, Sparse: true},
+			{Name: "zone_cwa", Keys: bson.D{{Key: "properties.cwa", Value: 1}}, Sparse: true},
+			GeometryIndex,
+		},
+	}
+	Types.Register(zone)
+
+	Types.Register(TypeDefinition{
+		Type:     County,
+		New:      func() any { return &CountyProperties{} },
+		Validate: func(props any) error { return props.(*CountyProperties).Validate() },
+		Indexes:  append(zone.Indexes, Index{Name: "county_fips", Keys: bson.D{{Key: "properties.fips", Value: 1}}, Sparse: true}),
+	})
+
+	Types.Register(TypeDefinition{
+		Type:     Office,
+		New:      func() any { return &OfficeProperties{} },
+		Validate: func(props any) error { return props.(*OfficeProperties).Validate() },
+		Indexes:  []Index{GeometryIndex},
+	})
+}
@@ -0,0 +1,71 @@
+package features_test
+
+import (
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Type registry", func() {
+	alert := func() features.Feature {
+		return features.Feature{
+			ID: "https://api.weather.gov/alerts/urn:oid:2.49.0.1.840.0.2",
+			Properties: features.JSONObject{
+				"@type":      features.Alert,
+				"id":         "urn:oid:2.49.0.1.840.0.2",
+				"event":      "Severe Thunderstorm Warning",
+				"sent":       "2024-06-05T16:42:00-05:00",
+				"expires":    "2024-06-05T17:30:00-05:00",
+				"ends":       nil,
+				"references": []any{"urn:oid:2.49.0.1.840.0.1", map[string]any{"identifier": "urn:oid:2.49.0.1.840.0.0"}},
+				"parameters": map[string]any{"maxHailSize": []any{"1.00"}},
+			},
+		}
+	}
+
+	It("Decodes alerts into their typed properties", func() {
+		a, e := features.DecodeAs[features.AlertProperties](alert())
+		Expect(e).NotTo(HaveOccurred())
+		Expect(a.Event).To(Equal("Severe Thunderstorm Warning"))
+		Expect(a.Parameters["maxHailSize"]).To(Equal([]string{"1.00"}))
+		Expect(a.References).To(HaveLen(2))
+		Expect(a.References[0].Identifier).To(Equal("urn:oid:2.49.0.1.840.0.1"))
+		Expect(a.References[1].Identifier).To(Equal("urn:oid:2.49.0.1.840.0.0"))
+	})
+
+	It("Validates typed properties", func() {
+		f := alert()
+		f.Properties["sent"] = "yesterday"
+		_, e := f.Typed()
+		Expect(e).To(MatchError(ContainSubstring("sent is not an RFC 3339 timestamp")))
+	})
+
+	It("Rejects unregistered types", func() {
+		_, e := features.Feature{Properties: features.JSONObject{"@type": "wx:Radar"}}.Typed()
+		Expect(e).To(MatchError(features.ErrUnknownType))
+	})
+
+	It("Rejects the wrong properties struct", func() {
+		_, e := features.DecodeAs[features.ZoneProperties](alert())
+		Expect(e).To(HaveOccurred())
+	})
+
+	It("Registers indexes for each type", func() {
+		def, ok := features.Types.Lookup(features.Alert)
+		Expect(ok).To(BeTrue())
+		Expect(def.Indexes).NotTo(BeEmpty())
+	})
+
+	It("Looks types up by their short names", func() {
+		featureType, ok := features.Types.Named("Alert")
+		Expect(ok).To(BeTrue())
+		Expect(featureType).To(Equal(features.Alert))
+
+		featureType, ok = features.Types.Named(features.Zone)
+		Expect(ok).To(BeTrue())
+		Expect(featureType).To(Equal(features.Zone))
+
+		_, ok = features.Types.Named("Radar")
+		Expect(ok).To(BeFalse())
+	})
+})
@@ -0,0 +1,137 @@
+package features
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+
+	"github.com/jghiloni/watchedsky-social/backend/utils"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// schemaFiles are the JSON Schemas of each feature type, named after the type
+// with the colon replaced, e.g. wx_Alert.json. The alert schema is generated
+// from the lexicon by cmd/schemagen
+//
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+const schemaBaseURL = "https://watchedsky.social/schemas/"
+
+var (
+	schemaOnce sync.Once
+	schemas    map[string]*jsonschema.Schema
+	schemaErr  error
+)
+
+func schemaFileName(featureType string) string {
+	return strings.ReplaceAll(featureType, ":", "_") + ".json"
+}
+
+func loadSchemas() {
+	schemas = map[string]*jsonschema.Schema{}
+
+	entries, err := fs.ReadDir(schemaFiles, "schemas")
+	if err != nil {
+		schemaErr = err
+		return
+	}
+
+	c := jsonschema.NewCompiler()
+	c.Draft = jsonschema.Draft2020
+	c.AssertFormat = true
+
+	for _, entry := range entries {
+		data, err := schemaFiles.ReadFile("schemas/" + entry.Name())
+		if err != nil {
+			schemaErr = err
+			return
+		}
+
+		if err = c.AddResource(schemaBaseURL+entry.Name(), bytes.NewReader(data)); err != nil {
+			schemaErr = fmt.Errorf("invalid schema %s: %w", entry.Name(), err)
+			return
+		}
+	}
+
+	for _, def := range Types.All() {
+		name := schemaFileName(def.Type)
+		if _, err := fs.Stat(schemaFiles, "schemas/"+name); err != nil {
+			continue
+		}
+
+		s, err := c.Compile(schemaBaseURL + name)
+		if err != nil {
+			schemaErr = fmt.Errorf("invalid schema %s: %w", name, err)
+			return
+		}
+
+		schemas[def.Type] = s
+	}
+}
+
+// ValidationError lists everything wrong with a feature
+type ValidationError struct {
+	Type   string   `json:"type" bson:"type"`
+	Errors []string `json:"errors" bson:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s feature: %s", e.Type, strings.Join(e.Errors, "; "))
+}
+
+// Validate checks a feature against the JSON Schema of its type, and then
+// against the validator registered for the type. Failures are returned as a
+// *ValidationError
+func (f Feature) Validate() error {
+	schemaOnce.Do(loadSchemas)
+	if schemaErr != nil {
+		return schemaErr
+	}
+
+	verr := &ValidationError{Type: f.Type()}
+	if s, ok := schemas[f.Type()]; ok {
+		raw, err := json.Marshal(f)
+		if err != nil {
+			return fmt.Errorf("could not serialize feature: %w", err)
+		}
+
+		var doc any
+		if err = json.Unmarshal(raw, &doc); err != nil {
+			return fmt.Errorf("could not serialize feature: %w", err)
+		}
+
+		if err = s.Validate(doc); err != nil {
+			var serr *jsonschema.ValidationError
+			if !errors.As(err, &serr) {
+				return err
+			}
+
+			for _, unit := range serr.BasicOutput().Errors {
+				// the units without an instance location just say which
+				// subschema failed, not why
+				if unit.InstanceLocation == "" && strings.HasPrefix(unit.Error, "doesn't validate") {
+					continue
+				}
+
+				verr.Errors = append(verr.Errors, fmt.Sprintf("%s: %s", utils.Coalesce(unit.InstanceLocation, "/"), unit.Error))
+			}
+		}
+	}
+
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+
+	if _, err := f.Typed(); err != nil {
+		verr.Errors = append(verr.Errors, err.Error())
+		return verr
+	}
+
+	return nil
+}
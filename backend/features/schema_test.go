@@ -0,0 +1,79 @@
+package features_test
+
+import (
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Schema validation", func() {
+	alert := func() features.Feature {
+		return features.Feature{
+			ID: "https://api.weather.gov/alerts/urn:oid:2.49.0.1.840.0.2",
+			Properties: features.JSONObject{
+				"@type":       features.Alert,
+				"id":          "urn:oid:2.49.0.1.840.0.2",
+				"sent":        "2024-06-05T16:42:00-05:00",
+				"effective":   "2024-06-05T16:42:00-05:00",
+				"expires":     "2024-06-05T17:30:00-05:00",
+				"ends":        nil,
+				"status":      "Actual",
+				"messageType": "Alert",
+				"severity":    "Severe",
+				"certainty":   "Observed",
+				"urgency":     "Immediate",
+				"event":       "Severe Thunderstorm Warning",
+				"sender":      "w-nws.webmaster@noaa.gov",
+				"senderName":  "NWS Chicago IL",
+				"headline":    "Severe Thunderstorm Warning issued June 5 at 4:42PM CDT",
+				"description": "HAZARD...60 mph wind gusts and quarter size hail.",
+				"instruction": nil,
+				"references":  []any{map[string]any{"identifier": "urn:oid:2.49.0.1.840.0.1"}},
+			},
+		}
+	}
+
+	It("Accepts a complete alert", func() {
+		Expect(alert().Validate()).To(Succeed())
+	})
+
+	It("Lists every problem with an alert", func() {
+		f := alert()
+		delete(f.Properties, "headline")
+		f.Properties["expires"] = "later"
+
+		e := f.Validate()
+		var verr *features.ValidationError
+		Expect(e).To(BeAssignableToTypeOf(verr))
+
+		verr = e.(*features.ValidationError)
+		Expect(verr.Type).To(Equal(features.Alert))
+		Expect(verr.Errors).To(ContainElements(
+			ContainSubstring("headline"),
+			ContainSubstring("/properties/expires"),
+		))
+	})
+
+	It("Validates counties against the zone schema", func() {
+		f := features.Feature{
+			ID: "https://api.weather.gov/zones/county/ILC031",
+			Properties: features.JSONObject{
+				"@type": features.County,
+				"id":    "ILC031",
+				"type":  "county",
+				"name":  "Cook",
+				"fips":  "170",
+			},
+		}
+
+		Expect(f.Validate()).To(MatchError(ContainSubstring("/properties/fips")))
+
+		f.Properties["fips"] = "17031"
+		Expect(f.Validate()).To(Succeed())
+	})
+
+	It("Rejects unknown types", func() {
+		f := features.Feature{ID: "x", Properties: features.JSONObject{"@type": "wx:Radar"}}
+		Expect(f.Validate()).To(MatchError(ContainSubstring("unknown feature type")))
+	})
+})
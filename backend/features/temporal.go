@@ -0,0 +1,120 @@
+package features
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// TimeField is a timestamp property of an alert
+type TimeField string
+
+const (
+	SentField      TimeField = "sent"
+	EffectiveField TimeField = "effective"
+	OnsetField     TimeField = "onset"
+	ExpiresField   TimeField = "expires"
+	EndsField      TimeField = "ends"
+)
+
+// Time parses a timestamp property of a feature
+func (f Feature) Time(field TimeField) (time.Time, bool) {
+	return f.Properties.TimeValue(string(field))
+}
+
+// StartsAt returns when an alert takes effect, falling back to when it was
+// sent
+func (f Feature) StartsAt() (time.Time, bool) {
+	if effective, ok := f.Time(EffectiveField); ok {
+		return effective, true
+	}
+
+	return f.Time(SentField)
+}
+
+// Expired returns true if the hazard an alert describes has ended as of now.
+// Alerts without an end or expiry time never expire
+func (f Feature) Expired(now time.Time) bool {
+	ends, ok := f.EndsAt()
+	return ok && !ends.After(now)
+}
+
+// IsActiveAt returns true if an alert is in force at t: it has taken effect,
+// hasn't expired, and hadn't been replaced yet. Cancel messages are never in
+// force themselves
+func (f Feature) IsActiveAt(t time.Time) bool {
+	if f.Type() != Alert || strings.EqualFold(f.Properties.StringValue("messageType"), "Cancel") {
+		return false
+	}
+
+	starts, ok := f.StartsAt()
+	if !ok || starts.After(t) || f.Expired(t) {
+		return false
+	}
+
+	if replacedAt, ok := f.Properties.TimeValue("replacedAt"); ok && !replacedAt.After(t) {
+		return false
+	}
+
+	return true
+}
+
+// ActiveAt returns the alerts that are in force at t
+func (f Features) ActiveAt(t time.Time) Features {
+	active := Features{}
+	for _, feat := range f {
+		if feat.IsActiveAt(t) {
+			active = append(active, feat)
+		}
+	}
+
+	return active
+}
+
+// timeSorter sorts features by timestamps that were parsed once up front
+type timeSorter struct {
+	feats      Features
+	times      []time.Time
+	ok         []bool
+	descending bool
+}
+
+func (s timeSorter) Len() int {
+	return len(s.feats)
+}
+
+func (s timeSorter) Less(i, j int) bool {
+	// features without the timestamp always go last
+	if s.ok[i] != s.ok[j] {
+		return s.ok[i]
+	}
+
+	if s.descending {
+		return s.times[i].After(s.times[j])
+	}
+
+	return s.times[i].Before(s.times[j])
+}
+
+func (s timeSorter) Swap(i, j int) {
+	s.feats[i], s.feats[j] = s.feats[j], s.feats[i]
+	s.times[i], s.times[j] = s.times[j], s.times[i]
+	s.ok[i], s.ok[j] = s.ok[j], s.ok[i]
+}
+
+// SortByTime sorts features in place by a timestamp property, parsing each
+// timestamp once. Features without the property sort last either way
+func (f Features) SortByTime(field TimeField, descending bool) {
+	s := timeSorter{
+		feats:      f,
+		times:      make([]time.Time, len(f)),
+		ok:         make([]bool, len(f)),
+		descending: descending,
+	}
+
+	for i, feat := range f {
+		s.times[i], s.ok[i] = feat.Time(field)
+	}
+
+	sort.Stable(s)
+}
@@ -0,0 +1,73 @@
+package features_test
+
+import (
+	"time"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Alert times", func() {
+	alert := func(id string, props features.JSONObject) features.Feature {
+		p := features.JSONObject{
+			"@type":       features.Alert,
+			"id":          id,
+			"messageType": "Alert",
+			"sent":        "2024-06-05T16:40:00-05:00",
+			"effective":   "2024-06-05T16:42:00-05:00",
+			"expires":     "2024-06-05T17:30:00-05:00",
+			"ends":        nil,
+		}
+		for k, v := range props {
+			p[k] = v
+		}
+
+		return features.Feature{ID: id, Properties: p}
+	}
+
+	at := func(s string) time.Time {
+		t, e := time.Parse(time.RFC3339, s)
+		Expect(e).NotTo(HaveOccurred())
+		return t
+	}
+
+	It("Knows when an alert is in force", func() {
+		a := alert("a", nil)
+		Expect(a.IsActiveAt(at("2024-06-05T21:41:00Z"))).To(BeFalse())
+		Expect(a.IsActiveAt(at("2024-06-05T21:42:00Z"))).To(BeTrue())
+		Expect(a.IsActiveAt(at("2024-06-05T22:29:59Z"))).To(BeTrue())
+		Expect(a.IsActiveAt(at("2024-06-05T22:30:00Z"))).To(BeFalse())
+		Expect(a.Expired(at("2024-06-05T22:30:00Z"))).To(BeTrue())
+	})
+
+	It("Prefers the end of the hazard to the expiry of the message", func() {
+		a := alert("a", features.JSONObject{"ends": "2024-06-05T19:00:00-05:00"})
+		Expect(a.IsActiveAt(at("2024-06-05T23:00:00Z"))).To(BeTrue())
+	})
+
+	It("Excludes replaced alerts and cancellations", func() {
+		replaced := alert("a", features.JSONObject{"replacedAt": "2024-06-05T17:00:00-05:00"})
+		Expect(replaced.IsActiveAt(at("2024-06-05T21:50:00Z"))).To(BeTrue())
+		Expect(replaced.IsActiveAt(at("2024-06-05T22:00:00Z"))).To(BeFalse())
+
+		cancel := alert("b", features.JSONObject{"messageType": "Cancel"})
+		Expect(cancel.IsActiveAt(at("2024-06-05T21:50:00Z"))).To(BeFalse())
+	})
+
+	It("Sorts by any timestamp, with missing timestamps last", func() {
+		feats := features.Features{
+			alert("early", features.JSONObject{"expires": "2024-06-05T17:00:00-05:00"}),
+			alert("none", features.JSONObject{"expires": nil}),
+			alert("late", features.JSONObject{"expires": "2024-06-05T23:00:00Z"}),
+		}
+
+		feats.SortByTime(features.ExpiresField, true)
+		Expect([]string{feats[0].ID, feats[1].ID, feats[2].ID}).To(Equal([]string{"late", "early", "none"}))
+
+		feats.SortByTime(features.ExpiresField, false)
+		Expect([]string{feats[0].ID, feats[1].ID, feats[2].ID}).To(Equal([]string{"early", "late", "none"}))
+
+		Expect(feats.ActiveAt(at("2024-06-05T22:10:00Z"))).To(HaveLen(2))
+	})
+})
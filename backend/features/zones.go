@@ -0,0 +1,280 @@
+package features
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jghiloni/watchedsky-social/backend/utils"
+)
+
+// ZoneType is the kind of NWS zone
+type ZoneType string
+
+const (
+	LandZone     ZoneType = "land"
+	MarineZone   ZoneType = "marine"
+	ForecastZone ZoneType = "forecast"
+	PublicZone   ZoneType = "public"
+	CoastalZone  ZoneType = "coastal"
+	OffshoreZone ZoneType = "offshore"
+	FireZone     ZoneType = "fire"
+	CountyZone   ZoneType = "county"
+)
+
+// ZoneProperties are the properties of a wx:Zone feature
+type ZoneProperties struct {
+	ID                  string   `json:"id" bson:"id"`
+	Type                string   `json:"@type" bson:"@type"`
+	ZoneType            ZoneType `json:"type" bson:"type"`
+	Name                string   `json:"name" bson:"name"`
+	State               string   `json:"state,omitempty" bson:"state,omitempty"`
+	EffectiveDate       string   `json:"effectiveDate,omitempty" bson:"effectiveDate,omitempty"`
+	ExpirationDate      string   `json:"expirationDate,omitempty" bson:"expirationDate,omitempty"`
+	CWA                 []string `json:"cwa" bson:"cwa"`
+	ForecastOffices     []string `json:"forecastOffices" bson:"forecastOffices"`
+	TimeZones           []string `json:"timeZone" bson:"timeZone"`
+	ObservationStations []string `json:"observationStations" bson:"observationStations"`
+	RadarStation        string   `json:"radarStation,omitempty" bson:"radarStation,omitempty"`
+}
+
+// CountyProperties are the properties of a wx:County feature, which is a
+// county zone along with the county's FIPS code
+type CountyProperties struct {
+	ZoneProperties `json:",inline" bson:",inline"`
+	FIPS           string `json:"fips,omitempty" bson:"fips,omitempty"`
+}
+
+// OfficeAddress is the postal address of a forecast office
+type OfficeAddress struct {
+	StreetAddress   string `json:"streetAddress" bson:"streetAddress"`
+	AddressLocality string `json:"addressLocality" bson:"addressLocality"`
+	AddressRegion   string `json:"addressRegion" bson:"addressRegion"`
+	PostalCode      string `json:"postalCode" bson:"postalCode"`
+}
+
+// OfficeProperties are the properties of a wx:Office feature
+type OfficeProperties struct {
+	ID                          string        `json:"id" bson:"id"`
+	Type                        string        `json:"@type" bson:"@type"`
+	Name                        string        `json:"name" bson:"name"`
+	Address                     OfficeAddress `json:"address" bson:"address"`
+	Telephone                   string        `json:"telephone,omitempty" bson:"telephone,omitempty"`
+	FaxNumber                   string        `json:"faxNumber,omitempty" bson:"faxNumber,omitempty"`
+	Email                       string        `json:"email,omitempty" bson:"email,omitempty"`
+	SameAs                      string        `json:"sameAs,omitempty" bson:"sameAs,omitempty"`
+	NWSRegion                   string        `json:"nwsRegion,omitempty" bson:"nwsRegion,omitempty"`
+	ParentOrganization          string        `json:"parentOrganization,omitempty" bson:"parentOrganization,omitempty"`
+	ResponsibleCounties         []string      `json:"responsibleCounties" bson:"responsibleCounties"`
+	ResponsibleForecastZones    []string      `json:"responsibleForecastZones" bson:"responsibleForecastZones"`
+	ResponsibleFireZones        []string      `json:"responsibleFireZones" bson:"responsibleFireZones"`
+	ApprovedObservationStations []string      `json:"approvedObservationStations" bson:"approvedObservationStations"`
+}
+
+// Validate checks that a zone has an ID and a zone type
+func (z ZoneProperties) Validate() error {
+	errs := []error{}
+	if z.ID == "" {
+		errs = append(errs, errors.New("id is required"))
+	}
+
+	if z.ZoneType == "" {
+		errs = append(errs, errors.New("type is required"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validate checks the zone properties of a county, and that its FIPS code,
+// if it has one, is 5 digits
+func (c CountyProperties) Validate() error {
+	err := c.ZoneProperties.Validate()
+	if c.FIPS != "" && !fipsCodePattern.MatchString(c.FIPS) {
+		err = errors.Join(err, fmt.Errorf("fips must be 5 digits, got %q", c.FIPS))
+	}
+
+	return err
+}
+
+// Validate checks that an office has an ID and a name
+func (o OfficeProperties) Validate() error {
+	errs := []error{}
+	if o.ID == "" {
+		errs = append(errs, errors.New("id is required"))
+	}
+
+	if o.Name == "" {
+		errs = append(errs, errors.New("name is required"))
+	}
+
+	return errors.Join(errs...)
+}
+
+var fipsCodePattern = regexp.MustCompile(`^\d{5}$`)
+
+// DecodeProperties converts the loose properties of a feature into a typed
+// properties struct
+func (f Feature) DecodeProperties(out any) error {
+	raw, err := json.Marshal(f.Properties)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(raw, out)
+}
+
+// ZoneProperties returns the typed properties of a wx:Zone or wx:County
+// feature
+func (f Feature) ZoneProperties() (ZoneProperties, error) {
+	t := f.Properties.StringValue("@type")
+	if t != Zone && t != County {
+		return ZoneProperties{}, fmt.Errorf("expected a %s or %s feature, got %q", Zone, County, t)
+	}
+
+	var z ZoneProperties
+	err := f.DecodeProperties(&z)
+	return z, err
+}
+
+// CountyProperties returns the typed properties of a county. Zones of type
+// county are accepted as well, and their FIPS code is derived from the zone
+// ID, e.g. MNC057 becomes 27057
+func (f Feature) CountyProperties() (CountyProperties, error) {
+	var c CountyProperties
+	z, err := f.ZoneProperties()
+	if err != nil {
+		return c, err
+	}
+
+	if z.ZoneType != CountyZone {
+		return c, fmt.Errorf("zone %s is a %s zone, not a county", z.ID, z.ZoneType)
+	}
+
+	if err = f.DecodeProperties(&c); err != nil {
+		return c, err
+	}
+
+	if c.FIPS == "" {
+		c.FIPS = CountyFIPSFromZoneID(z.ID)
+	}
+
+	return c, nil
+}
+
+// CountyFIPSFromZoneID converts a county zone ID such as MNC057 to a 5 digit
+// FIPS code, or returns "" if it can't
+func CountyFIPSFromZoneID(id string) string {
+	if len(id) != 6 || id[2] != 'C' {
+		return ""
+	}
+
+	stateFIPS, ok := StateFIPS(id[:2])
+	if !ok {
+		return ""
+	}
+
+	return stateFIPS + id[3:]
+}
+
+// OfficeProperties returns the typed properties of a wx:Office feature
+func (f Feature) OfficeProperties() (OfficeProperties, error) {
+	if t := f.Properties.StringValue("@type"); t != Office {
+		return OfficeProperties{}, fmt.Errorf("expected a %s feature, got %q", Office, t)
+	}
+
+	var o OfficeProperties
+	err := f.DecodeProperties(&o)
+	return o, err
+}
+
+// AffectedZones returns the IDs of the zones an alert affects
+func (f Feature) AffectedZones() []string {
+	zones, ok := utils.NormalizeSlice(f.Properties["affectedZones"])
+	if !ok {
+		return nil
+	}
+
+	ids := make([]string, 0, len(zones))
+	for _, z := range zones {
+		if id, ok := z.(string); ok {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// IssuingOffice returns the ID of the office that issued an alert, e.g. LOT,
+// taken from its AWIPS identifier
+func (f Feature) IssuingOffice() string {
+	awips := f.Parameter("AWIPSidentifier")
+	if len(awips) < 6 {
+		return ""
+	}
+
+	return strings.ToUpper(awips[len(awips)-3:])
+}
+
+// AlertOffices returns the IDs of every forecast office involved with an
+// alert: the issuing office, and the offices responsible for its zones
+func AlertOffices(alert Feature, zones Features) []string {
+	offices := map[string]bool{}
+	if office := alert.IssuingOffice(); office != "" {
+		offices[office] = true
+	}
+
+	for _, zone := range zones {
+		z, err := zone.ZoneProperties()
+		if err != nil {
+			continue
+		}
+
+		for _, cwa := range z.CWA {
+			offices[cwa] = true
+		}
+
+		for _, fo := range z.ForecastOffices {
+			offices[AlertIdentifier(fo)] = true
+		}
+	}
+
+	return sortedKeys(offices)
+}
+
+// AlertTimeZones returns the IANA time zones of the zones an alert affects
+func AlertTimeZones(zones Features) []*time.Location {
+	names := map[string]bool{}
+	for _, zone := range zones {
+		z, err := zone.ZoneProperties()
+		if err != nil {
+			continue
+		}
+
+		for _, tz := range z.TimeZones {
+			names[tz] = true
+		}
+	}
+
+	locs := make([]*time.Location, 0, len(names))
+	for _, name := range sortedKeys(names) {
+		if loc, err := time.LoadLocation(name); err == nil {
+			locs = append(locs, loc)
+		}
+	}
+
+	return locs
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,62 @@
+package features_test
+
+import (
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Zones", func() {
+	county := features.Feature{
+		ID: "https://api.weather.gov/zones/county/ILC031",
+		Properties: features.JSONObject{
+			"id":              "ILC031",
+			"@type":           features.Zone,
+			"type":            "county",
+			"name":            "Cook",
+			"state":           "IL",
+			"cwa":             []any{"LOT"},
+			"forecastOffices": []any{"https://api.weather.gov/offices/LOT"},
+			"timeZone":        []any{"America/Chicago"},
+		},
+	}
+
+	It("Decodes zone properties", func() {
+		z, e := county.ZoneProperties()
+		Expect(e).NotTo(HaveOccurred())
+		Expect(z.ZoneType).To(Equal(features.CountyZone))
+		Expect(z.CWA).To(Equal([]string{"LOT"}))
+		Expect(z.TimeZones).To(Equal([]string{"America/Chicago"}))
+	})
+
+	It("Derives the FIPS code of county zones", func() {
+		c, e := county.CountyProperties()
+		Expect(e).NotTo(HaveOccurred())
+		Expect(c.Name).To(Equal("Cook"))
+		Expect(c.FIPS).To(Equal("17031"))
+	})
+
+	It("Rejects features of the wrong type", func() {
+		_, e := county.OfficeProperties()
+		Expect(e).To(HaveOccurred())
+	})
+
+	It("Finds the offices and time zones of an alert", func() {
+		alert := features.Feature{
+			Properties: features.JSONObject{
+				"@type":         features.Alert,
+				"affectedZones": []any{county.ID},
+				"parameters": map[string]any{
+					"AWIPSidentifier": []any{"SVRMKX"},
+				},
+			},
+		}
+
+		Expect(alert.AffectedZones()).To(Equal([]string{county.ID}))
+		Expect(features.AlertOffices(alert, features.Features{county})).To(Equal([]string{"LOT", "MKX"}))
+
+		tz := features.AlertTimeZones(features.Features{county})
+		Expect(tz).To(HaveLen(1))
+		Expect(tz[0].String()).To(Equal("America/Chicago"))
+	})
+})
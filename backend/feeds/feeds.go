@@ -0,0 +1,47 @@
+package feeds
+
+import (
+	"context"
+	"strings"
+
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/jghiloni/go-bsky-feed-generator/algos"
+	feedconfig "github.com/jghiloni/go-bsky-feed-generator/config"
+	"github.com/jghiloni/watchedsky-social/backend/appcontext"
+	"github.com/jghiloni/watchedsky-social/backend/config"
+	"github.com/jghiloni/watchedsky-social/backend/features"
+)
+
+func loadConfigToContext(ctx context.Context, cfg config.AppConfig) (context.Context, error) {
+	if cfg.Bluesky.FeedServiceDID == "" {
+		return ctx, nil
+	}
+
+	did := cfg.Bluesky.FeedServiceDID
+	if !strings.HasPrefix(did, "at://") {
+		did = "at://" + did
+	}
+
+	return feedconfig.WithConfig(ctx, feedconfig.FeedGeneratorConfig{
+		ServiceDID:   syntax.ATURI(did),
+		PublisherDID: syntax.ATURI(did),
+	}), nil
+}
+
+func init() {
+	appcontext.Registry.RegisterClient(loadConfigToContext)
+}
+
+// Feeds returns every feed served by WatchedSky
+func Feeds(ctx context.Context) []algos.BlueskyFeed {
+	return []algos.BlueskyFeed{
+		&hazardFeed{ctx: ctx, name: "alerts"},
+		&hazardFeed{ctx: ctx, name: "top", priority: true},
+		&hazardFeed{ctx: ctx, name: "tornado", tags: []features.HazardTag{features.HazardTornado}},
+		&hazardFeed{ctx: ctx, name: "hail", tags: []features.HazardTag{features.HazardHail}},
+		&hazardFeed{ctx: ctx, name: "wind", tags: []features.HazardTag{features.HazardWind}},
+		&hazardFeed{ctx: ctx, name: "flood", tags: []features.HazardTag{features.HazardFlood}},
+		&hazardFeed{ctx: ctx, name: "winter", tags: []features.HazardTag{features.HazardSnow, features.HazardIce}},
+		&hazardFeed{ctx: ctx, name: "fire", tags: []features.HazardTag{features.HazardFire}},
+	}
+}
@@ -0,0 +1,92 @@
+package feeds
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/bluesky-social/indigo/api/bsky"
+	"github.com/jghiloni/go-bsky-feed-generator/algos"
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/store"
+	"github.com/jghiloni/watchedsky-social/backend/utils"
+)
+
+const (
+	defaultFeedLimit = 30
+	maxFeedLimit     = 100
+)
+
+// hazardFeed is a feed of alert posts, newest first, optionally limited to
+// alerts with any of the given hazard tags. Priority feeds only list alerts
+// that are in force, highest priority first
+type hazardFeed struct {
+	ctx      context.Context
+	name     string
+	tags     []features.HazardTag
+	priority bool
+}
+
+// ShortName implements algos.BlueskyFeed
+func (h *hazardFeed) ShortName() string {
+	return h.name
+}
+
+// GenerateFeed implements algos.BlueskyFeed. The cursor is the one returned
+// with the previous page. Plain page numbers, which older versions returned,
+// are still accepted
+func (h *hazardFeed) GenerateFeed(input algos.FeedInput) (bsky.FeedGetFeedSkeleton_Output, error) {
+	dbClient := store.GetStore(h.ctx)
+	if dbClient == nil {
+		return bsky.FeedGetFeedSkeleton_Output{}, errors.New("no feature store configured")
+	}
+
+	limit := input.Limit
+	if limit < 1 {
+		limit = defaultFeedLimit
+	}
+	limit = utils.WNMin(limit, maxFeedLimit)
+
+	filter := store.FeatureFilter{
+		Type:       features.Alert,
+		HazardTags: h.tags,
+		Posted:     true,
+	}
+	pageInfo := store.PageOptions{PageSize: uint(limit), Cursor: input.Cursor}
+
+	if page, err := strconv.Atoi(input.Cursor); err == nil {
+		if page < 0 {
+			return bsky.FeedGetFeedSkeleton_Output{}, errors.New("invalid cursor")
+		}
+
+		pageInfo.Page = uint(page)
+		pageInfo.Cursor = ""
+	}
+
+	if h.priority {
+		filter.ActiveAt = time.Now()
+		pageInfo.Order = store.OrderPriority
+	}
+
+	result, err := dbClient.ListFeatures(h.ctx, filter, pageInfo)
+	if err != nil {
+		return bsky.FeedGetFeedSkeleton_Output{}, err
+	}
+
+	out := bsky.FeedGetFeedSkeleton_Output{
+		Feed: make([]*bsky.FeedDefs_SkeletonFeedPost, 0, len(result.Features)),
+	}
+
+	for _, f := range result.Features {
+		out.Feed = append(out.Feed, &bsky.FeedDefs_SkeletonFeedPost{
+			Post: f.Properties.StringValue("postUri"),
+		})
+	}
+
+	if result.Next != "" {
+		out.Cursor = utils.Ptr(result.Next)
+	}
+
+	return out, nil
+}
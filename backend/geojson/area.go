@@ -0,0 +1,76 @@
+package geojson
+
+import "math"
+
+// ringArea is the area enclosed by a ring on a spherical earth, in square
+// kilometers
+func ringArea(ring []Coordinate) float64 {
+	if len(ring) < 3 {
+		return 0
+	}
+
+	total := 0.0
+	for i := range ring {
+		p1 := ring[i]
+		p2 := ring[(i+1)%len(ring)]
+		total += toRadians(p2.Longitude-p1.Longitude) *
+			(2 + math.Sin(toRadians(p1.Latitude)) + math.Sin(toRadians(p2.Latitude)))
+	}
+
+	return math.Abs(total * EarthRadiusKm * EarthRadiusKm / 2)
+}
+
+func polygonArea(poly Polygon) float64 {
+	if len(poly) == 0 {
+		return 0
+	}
+
+	area := ringArea(poly[0])
+	for _, hole := range poly[1:] {
+		area -= ringArea(hole)
+	}
+
+	return math.Max(area, 0)
+}
+
+// Area returns the area of a geometry in square kilometers. Points and lines
+// have no area, and overlapping parts of a collection are counted twice
+func Area(g Geometry) float64 {
+	area := 0.0
+	for _, poly := range partsOf(g).polygons {
+		area += polygonArea(poly)
+	}
+
+	return area
+}
+
+// Vertices returns every coordinate of a geometry
+func Vertices(g Geometry) []Coordinate {
+	p := partsOf(g)
+	vertices := append([]Coordinate{}, p.points...)
+	for _, s := range p.segments {
+		vertices = append(vertices, s[1])
+	}
+
+	return vertices
+}
+
+// Covers returns true if c is inside or on the boundary of one of the
+// polygons of g
+func Covers(g Geometry, c Coordinate) bool {
+	for _, poly := range partsOf(g).polygons {
+		if PolygonContains(poly, c) {
+			return true
+		}
+
+		for _, ring := range poly {
+			for i := 1; i < len(ring); i++ {
+				if orientation(ring[i-1], ring[i], c) == 0 && onSegment(ring[i-1], ring[i], c) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,53 @@
+package geojson
+
+import "math"
+
+// BoundingBox is a box between two longitudes and two latitudes
+type BoundingBox struct {
+	MinLongitude float64 `json:"minLon"`
+	MinLatitude  float64 `json:"minLat"`
+	MaxLongitude float64 `json:"maxLon"`
+	MaxLatitude  float64 `json:"maxLat"`
+}
+
+// Polygon returns the box as a polygon, counterclockwise from the south west
+// corner
+func (b BoundingBox) Polygon() Polygon {
+	return Polygon{{
+		{Longitude: b.MinLongitude, Latitude: b.MinLatitude},
+		{Longitude: b.MaxLongitude, Latitude: b.MinLatitude},
+		{Longitude: b.MaxLongitude, Latitude: b.MaxLatitude},
+		{Longitude: b.MinLongitude, Latitude: b.MaxLatitude},
+		{Longitude: b.MinLongitude, Latitude: b.MinLatitude},
+	}}
+}
+
+// Empty returns true if the box has no area
+func (b BoundingBox) Empty() bool {
+	return b.MinLongitude >= b.MaxLongitude || b.MinLatitude >= b.MaxLatitude
+}
+
+// Bounds returns the smallest box that holds every coordinate of g. ok is
+// false if g has no coordinates
+func Bounds(g Geometry) (box BoundingBox, ok bool) {
+	box = BoundingBox{
+		MinLongitude: math.Inf(1),
+		MinLatitude:  math.Inf(1),
+		MaxLongitude: math.Inf(-1),
+		MaxLatitude:  math.Inf(-1),
+	}
+
+	for _, c := range Vertices(g) {
+		box.MinLongitude = math.Min(box.MinLongitude, c.Longitude)
+		box.MinLatitude = math.Min(box.MinLatitude, c.Latitude)
+		box.MaxLongitude = math.Max(box.MaxLongitude, c.Longitude)
+		box.MaxLatitude = math.Max(box.MaxLatitude, c.Latitude)
+		ok = true
+	}
+
+	if !ok {
+		return BoundingBox{}, false
+	}
+
+	return box, true
+}
@@ -59,6 +59,10 @@ func getPoint(data any) (Coordinate, error) {
 		return Coordinate{}, fmt.Errorf("expected [lon, lat], got %T", data)
 	}
 
+	if len(f) < 2 {
+		return Coordinate{}, fmt.Errorf("expected [lon, lat], got %d values", len(f))
+	}
+
 	lon, lonok := toFloat64(f[0])
 	lat, latok := toFloat64(f[1])
 
@@ -0,0 +1,60 @@
+package geojson
+
+import "math"
+
+// EarthRadiusKm is the mean radius of the earth, in kilometers
+const EarthRadiusKm float64 = 6371.0088
+
+func toRadians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func toDegrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+// Destination returns the coordinate reached by travelling distanceKm from c
+// along the great circle with the given initial bearing (degrees clockwise
+// from true north)
+func Destination(c Coordinate, bearing float64, distanceKm float64) Coordinate {
+	lat1 := toRadians(c.Latitude)
+	lon1 := toRadians(c.Longitude)
+	brng := toRadians(bearing)
+	dist := distanceKm / EarthRadiusKm
+
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(dist) + math.Cos(lat1)*math.Sin(dist)*math.Cos(brng))
+	lon2 := lon1 + math.Atan2(math.Sin(brng)*math.Sin(dist)*math.Cos(lat1), math.Cos(dist)-math.Sin(lat1)*math.Sin(lat2))
+
+	// normalize to [-180, 180)
+	lon := math.Mod(toDegrees(lon2)+540, 360) - 180
+
+	return Coordinate{Latitude: toDegrees(lat2), Longitude: lon}
+}
+
+// Distance returns the great circle distance between two coordinates, in
+// kilometers
+func Distance(c1 Coordinate, c2 Coordinate) float64 {
+	lat1 := toRadians(c1.Latitude)
+	lat2 := toRadians(c2.Latitude)
+	dLat := lat2 - lat1
+	dLon := toRadians(c2.Longitude - c1.Longitude)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EarthRadiusKm * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// Circle approximates a circle of radiusKm around center as a closed polygon
+// with the given number of segments
+func Circle(center Coordinate, radiusKm float64, segments int) Polygon {
+	if segments < 3 {
+		segments = 3
+	}
+
+	ring := make([]Coordinate, 0, segments+1)
+	for i := 0; i < segments; i++ {
+		ring = append(ring, Destination(center, 360*float64(i)/float64(segments), radiusKm))
+	}
+
+	ring = append(ring, ring[0])
+	return Polygon{ring}
+}
@@ -0,0 +1,167 @@
+package geojson
+
+// parts is a geometry broken down into the pieces that intersection tests
+// work on. Points holds standalone points plus one vertex of every line and
+// polygon, so that a geometry lying entirely within a polygon is detected
+type parts struct {
+	points   []Coordinate
+	segments [][2]Coordinate
+	polygons []Polygon
+}
+
+func (p *parts) addLine(line []Coordinate) {
+	if len(line) == 0 {
+		return
+	}
+
+	p.points = append(p.points, line[0])
+	for i := 1; i < len(line); i++ {
+		p.segments = append(p.segments, [2]Coordinate{line[i-1], line[i]})
+	}
+}
+
+func (p *parts) addPolygon(poly Polygon) {
+	if len(poly) == 0 {
+		return
+	}
+
+	p.polygons = append(p.polygons, poly)
+	for _, ring := range poly {
+		p.addLine(ring)
+	}
+}
+
+func (p *parts) add(g Geometry) {
+	switch geo := g.(type) {
+	case Point:
+		p.points = append(p.points, Coordinate(geo))
+	case MultiPoint:
+		p.points = append(p.points, geo...)
+	case LineString:
+		p.addLine(geo)
+	case MultiLineString:
+		for _, line := range geo {
+			p.addLine(line)
+		}
+	case Polygon:
+		p.addPolygon(geo)
+	case MultiPolygon:
+		for _, poly := range geo {
+			p.addPolygon(poly)
+		}
+	case GeometryCollection:
+		for _, child := range geo.Geometries {
+			p.add(child)
+		}
+	}
+}
+
+func partsOf(g Geometry) parts {
+	p := parts{}
+	p.add(g)
+	return p
+}
+
+// Intersects returns true if two geometries share at least one point. It
+// treats longitude and latitude as planar coordinates, which is accurate
+// enough for the areas that alerts cover
+func Intersects(a Geometry, b Geometry) bool {
+	if a == nil || b == nil {
+		return false
+	}
+
+	pa, pb := partsOf(a), partsOf(b)
+
+	for _, sa := range pa.segments {
+		for _, sb := range pb.segments {
+			if segmentsIntersect(sa[0], sa[1], sb[0], sb[1]) {
+				return true
+			}
+		}
+	}
+
+	return pointsTouch(pa.points, pb) || pointsTouch(pb.points, pa)
+}
+
+func pointsTouch(points []Coordinate, other parts) bool {
+	for _, c := range points {
+		for _, o := range other.points {
+			if c == o {
+				return true
+			}
+		}
+
+		for _, s := range other.segments {
+			if orientation(s[0], s[1], c) == 0 && onSegment(s[0], s[1], c) {
+				return true
+			}
+		}
+
+		for _, poly := range other.polygons {
+			if PolygonContains(poly, c) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// PolygonContains returns true if c is inside the outer ring of poly and not
+// inside any of its holes
+func PolygonContains(poly Polygon, c Coordinate) bool {
+	if len(poly) == 0 || !ringContains(poly[0], c) {
+		return false
+	}
+
+	for _, hole := range poly[1:] {
+		if ringContains(hole, c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ringContains is the even-odd ray casting test
+func ringContains(ring []Coordinate, c Coordinate) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Latitude > c.Latitude) != (b.Latitude > c.Latitude) &&
+			c.Longitude < (b.Longitude-a.Longitude)*(c.Latitude-a.Latitude)/(b.Latitude-a.Latitude)+a.Longitude {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+// orientation is positive if p, q, r turn counterclockwise, negative if they
+// turn clockwise, and 0 if they are collinear
+func orientation(p, q, r Coordinate) float64 {
+	return (q.Longitude-p.Longitude)*(r.Latitude-p.Latitude) - (q.Latitude-p.Latitude)*(r.Longitude-p.Longitude)
+}
+
+// onSegment returns true if r, which is collinear with p and q, lies between
+// them
+func onSegment(p, q, r Coordinate) bool {
+	return r.Longitude >= min(p.Longitude, q.Longitude) && r.Longitude <= max(p.Longitude, q.Longitude) &&
+		r.Latitude >= min(p.Latitude, q.Latitude) && r.Latitude <= max(p.Latitude, q.Latitude)
+}
+
+func segmentsIntersect(p1, p2, q1, q2 Coordinate) bool {
+	d1 := orientation(q1, q2, p1)
+	d2 := orientation(q1, q2, p2)
+	d3 := orientation(p1, p2, q1)
+	d4 := orientation(p1, p2, q2)
+
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return true
+	}
+
+	return (d1 == 0 && onSegment(q1, q2, p1)) ||
+		(d2 == 0 && onSegment(q1, q2, p2)) ||
+		(d3 == 0 && onSegment(p1, p2, q1)) ||
+		(d4 == 0 && onSegment(p1, p2, q2))
+}
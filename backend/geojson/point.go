@@ -44,13 +44,14 @@ func (p *Point) unmarshal(data []byte, unmarshal coreUnmarshaler) error {
 		return fmt.Errorf("unexpected geometry type %q", m["type"])
 	}
 
-	l, ok := m["coordinates"].([]float64)
+	coords, ok := m["coordinates"]
 	if !ok {
-		return errors.New("incorrect coordinate format")
+		return errors.New("missing coordinates")
 	}
 
-	*p = Point{Longitude: l[0], Latitude: l[1]}
-	return nil
+	c, err := getPoint(coords)
+	*p = Point(c)
+	return err
 }
 
 func (p *Point) UnmarshalJSON(data []byte) error {
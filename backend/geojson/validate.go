@@ -0,0 +1,223 @@
+package geojson
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Validate checks a geometry against the rules that MongoDB enforces for
+// 2dsphere indexes: coordinates in range, lines with at least two points,
+// closed rings with at least four, and rings whose edges don't cross
+func Validate(g Geometry) error {
+	switch geo := g.(type) {
+	case nil:
+		return errors.New("geometry is missing")
+	case Point:
+		return validateCoordinate(Coordinate(geo))
+	case MultiPoint:
+		return validateCoordinates(geo)
+	case LineString:
+		return validateLine(geo)
+	case MultiLineString:
+		for i, line := range geo {
+			if err := validateLine(line); err != nil {
+				return fmt.Errorf("line %d: %w", i, err)
+			}
+		}
+	case Polygon:
+		return validatePolygon(geo)
+	case MultiPolygon:
+		for i, poly := range geo {
+			if err := validatePolygon(poly); err != nil {
+				return fmt.Errorf("polygon %d: %w", i, err)
+			}
+		}
+	case GeometryCollection:
+		if geo.GT != GeometryCollectionType {
+			return fmt.Errorf("geometry collection has type %q", geo.GT)
+		}
+
+		for i, child := range geo.Geometries {
+			if err := Validate(child); err != nil {
+				return fmt.Errorf("geometry %d: %w", i, err)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported geometry %T", g)
+	}
+
+	return nil
+}
+
+func validateCoordinate(c Coordinate) error {
+	if c.Longitude < -180 || c.Longitude > 180 || c.Latitude < -90 || c.Latitude > 90 {
+		return fmt.Errorf("coordinate %v,%v is out of range", c.Longitude, c.Latitude)
+	}
+
+	return nil
+}
+
+func validateCoordinates(cs []Coordinate) error {
+	for _, c := range cs {
+		if err := validateCoordinate(c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateLine(line []Coordinate) error {
+	if len(line) < 2 {
+		return errors.New("line needs at least 2 points")
+	}
+
+	return validateCoordinates(line)
+}
+
+func validatePolygon(poly Polygon) error {
+	if len(poly) == 0 {
+		return errors.New("polygon has no rings")
+	}
+
+	for i, ring := range poly {
+		if err := validateRing(ring); err != nil {
+			return fmt.Errorf("ring %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func validateRing(ring []Coordinate) error {
+	if len(ring) < 4 {
+		return errors.New("ring needs at least 4 points")
+	}
+
+	if ring[0] != ring[len(ring)-1] {
+		return errors.New("ring isn't closed")
+	}
+
+	if err := validateCoordinates(ring); err != nil {
+		return err
+	}
+
+	for i := 1; i < len(ring); i++ {
+		if ring[i] == ring[i-1] {
+			return fmt.Errorf("ring repeats point %d", i)
+		}
+	}
+
+	// edges that share a vertex always touch, so only edges that are at
+	// least two apart are checked, and the first and last edges are skipped
+	edges := len(ring) - 1
+	for i := 0; i < edges; i++ {
+		for j := i + 2; j < edges; j++ {
+			if i == 0 && j == edges-1 {
+				continue
+			}
+
+			if segmentsIntersect(ring[i], ring[i+1], ring[j], ring[j+1]) {
+				return fmt.Errorf("ring edges %d and %d cross", i, j)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Repair returns a version of g that passes Validate. Rings are closed and
+// repeated points dropped, as are empty parts of collections. If that isn't
+// enough, the bounding box of g is used instead, so the geometry at least
+// covers the right area. ok is false if g can't be repaired at all
+func Repair(g Geometry) (repaired Geometry, ok bool) {
+	if Validate(g) == nil {
+		return g, true
+	}
+
+	repaired = clean(g)
+	if repaired != nil && Validate(repaired) == nil {
+		return repaired, true
+	}
+
+	box, found := Bounds(g)
+	if !found {
+		return nil, false
+	}
+
+	if box.Empty() {
+		point := Point{Longitude: box.MinLongitude, Latitude: box.MinLatitude}
+		return point, Validate(point) == nil
+	}
+
+	poly := box.Polygon()
+	return poly, Validate(poly) == nil
+}
+
+// clean fixes the mistakes that don't change the shape of a geometry
+func clean(g Geometry) Geometry {
+	switch geo := g.(type) {
+	case Polygon:
+		return cleanPolygon(geo)
+	case MultiPolygon:
+		cleaned := MultiPolygon{}
+		for _, poly := range geo {
+			if p := cleanPolygon(poly); p != nil {
+				cleaned = append(cleaned, p)
+			}
+		}
+
+		if len(cleaned) == 0 {
+			return nil
+		}
+
+		return cleaned
+	case GeometryCollection:
+		cleaned := GeometryCollection{GT: GeometryCollectionType}
+		for _, child := range geo.Geometries {
+			if c := clean(child); c != nil {
+				cleaned.Geometries = append(cleaned.Geometries, c)
+			}
+		}
+
+		if len(cleaned.Geometries) == 0 {
+			return nil
+		}
+
+		return cleaned
+	}
+
+	return g
+}
+
+func cleanPolygon(poly Polygon) Polygon {
+	cleaned := Polygon{}
+	for i, ring := range poly {
+		r := cleanRing(ring)
+		if len(r) < 4 {
+			if i == 0 {
+				return nil
+			}
+			continue
+		}
+
+		cleaned = append(cleaned, r)
+	}
+
+	return cleaned
+}
+
+func cleanRing(ring []Coordinate) []Coordinate {
+	cleaned := make([]Coordinate, 0, len(ring)+1)
+	for _, c := range ring {
+		if len(cleaned) == 0 || cleaned[len(cleaned)-1] != c {
+			cleaned = append(cleaned, c)
+		}
+	}
+
+	if len(cleaned) > 0 && cleaned[0] != cleaned[len(cleaned)-1] {
+		cleaned = append(cleaned, cleaned[0])
+	}
+
+	return cleaned
+}
@@ -0,0 +1,65 @@
+package geojson_test
+
+import (
+	"github.com/jghiloni/watchedsky-social/backend/geojson"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Validate", func() {
+	c := func(lon, lat float64) geojson.Coordinate {
+		return geojson.Coordinate{Longitude: lon, Latitude: lat}
+	}
+
+	square := geojson.Polygon{{c(0, 0), c(1, 0), c(1, 1), c(0, 1), c(0, 0)}}
+	bowtie := geojson.Polygon{{c(0, 0), c(1, 1), c(1, 0), c(0, 1), c(0, 0)}}
+
+	It("Accepts valid geometries", func() {
+		Expect(geojson.Validate(square)).To(Succeed())
+		Expect(geojson.Validate(geojson.Point(c(-88, 41)))).To(Succeed())
+		Expect(geojson.Validate(geojson.GeometryCollection{
+			GT:         geojson.GeometryCollectionType,
+			Geometries: []geojson.Geometry{square, geojson.LineString{c(0, 0), c(2, 2)}},
+		})).To(Succeed())
+	})
+
+	It("Rejects geometries Mongo can't index", func() {
+		Expect(geojson.Validate(nil)).NotTo(Succeed())
+		Expect(geojson.Validate(bowtie)).To(MatchError(ContainSubstring("cross")))
+		Expect(geojson.Validate(geojson.Polygon{{c(0, 0), c(1, 0), c(1, 1), c(0, 1)}})).To(MatchError(ContainSubstring("closed")))
+		Expect(geojson.Validate(geojson.Point(c(200, 0)))).To(MatchError(ContainSubstring("out of range")))
+		Expect(geojson.Validate(geojson.GeometryCollection{Geometries: []geojson.Geometry{square}})).NotTo(Succeed())
+	})
+
+	Describe("Repair", func() {
+		It("Closes rings and drops repeated points", func() {
+			g, ok := geojson.Repair(geojson.Polygon{{c(0, 0), c(1, 0), c(1, 0), c(1, 1), c(0, 1)}})
+			Expect(ok).To(BeTrue())
+			Expect(g).To(Equal(square))
+		})
+
+		It("Falls back to the bounding box", func() {
+			g, ok := geojson.Repair(bowtie)
+			Expect(ok).To(BeTrue())
+			Expect(g).To(Equal(geojson.BoundingBox{MaxLongitude: 1, MaxLatitude: 1}.Polygon()))
+		})
+
+		It("Drops empty members of collections", func() {
+			g, ok := geojson.Repair(geojson.GeometryCollection{Geometries: []geojson.Geometry{nil, square}})
+			Expect(ok).To(BeTrue())
+			Expect(g).To(Equal(geojson.GeometryCollection{
+				GT:         geojson.GeometryCollectionType,
+				Geometries: []geojson.Geometry{square},
+			}))
+		})
+	})
+
+	It("Finds the bounds of a geometry", func() {
+		box, ok := geojson.Bounds(geojson.MultiPoint{c(-90, 40), c(-88, 42), c(-89, 41)})
+		Expect(ok).To(BeTrue())
+		Expect(box).To(Equal(geojson.BoundingBox{MinLongitude: -90, MinLatitude: 40, MaxLongitude: -88, MaxLatitude: 42}))
+
+		_, ok = geojson.Bounds(nil)
+		Expect(ok).To(BeFalse())
+	})
+})
@@ -35,7 +35,12 @@ func init() {
 	appcontext.Registry.RegisterClient(loadClientToContext)
 }
 
+// GetLogger returns the context's logger, or the default logger if it
+// doesn't have one
 func GetLogger(ctx context.Context) *slog.Logger {
-	log, _ := ctx.Value(loggerContextKey).(*slog.Logger)
-	return log
+	if log, ok := ctx.Value(loggerContextKey).(*slog.Logger); ok {
+		return log
+	}
+
+	return slog.Default()
 }
@@ -0,0 +1,176 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ArchiveCollectionName is where alerts are moved once they have ended
+const ArchiveCollectionName = "alerts_archive"
+
+const (
+	archiveBatchSize = 500
+
+	// retentionIndexPrefix names the TTL index for each feature type in the
+	// archive
+	retentionIndexPrefix = "retention_"
+)
+
+// endedBefore matches alerts that ended, or expired if they have no end
+// time, before t
+func endedBefore(t time.Time) bson.D {
+	ends := parsedDate(bson.D{{Key: "$ifNull", Value: bson.A{"$properties.ends", "$properties.expires"}}})
+	return bson.D{
+		{Key: "properties.@type", Value: features.Alert},
+		{Key: "$expr", Value: bson.D{{Key: "$and", Value: bson.A{
+			bson.D{{Key: "$ne", Value: bson.A{ends, nil}}},
+			bson.D{{Key: "$lt", Value: bson.A{ends, t}}},
+		}}}},
+	}
+}
+
+// ArchiveEndedAlerts moves the alerts that ended before t from the features
+// collection to the archive, and returns how many it moved. Alerts are
+// copied before they are removed, and a version of an alert that is stored
+// while it is being archived is left alone. Geometry files that nothing
+// refers to any more, once the archive has expired what did, are removed
+// afterwards
+func (c *MongoClient) ArchiveEndedAlerts(ctx context.Context, t time.Time) (int, error) {
+	moved, err := c.archiveEnded(ctx, t)
+	if err != nil {
+		return moved, err
+	}
+
+	return moved, c.pruneGeometryFiles(ctx)
+}
+
+func (c *MongoClient) archiveEnded(ctx context.Context, t time.Time) (int, error) {
+	coll := c.cli.Collection(features.CollectionName)
+	archive := c.cli.Collection(ArchiveCollectionName)
+
+	moved := 0
+	for {
+		cursor, err := coll.Find(ctx, endedBefore(t), options.Find().SetLimit(archiveBatchSize))
+		if err != nil {
+			return moved, err
+		}
+
+		var batch features.Features
+		if err = cursor.All(ctx, &batch); err != nil {
+			return moved, fmt.Errorf("could not decode features: %w", err)
+		}
+
+		if len(batch) == 0 {
+			return moved, nil
+		}
+
+		archivedAt := time.Now().UTC()
+		copies := make([]mongo.WriteModel, len(batch))
+		removals := make([]mongo.WriteModel, len(batch))
+		for i, f := range batch {
+			removals[i] = mongo.NewDeleteOneModel().SetFilter(bson.D{
+				{Key: "_id", Value: f.ID},
+				{Key: "properties.sent", Value: f.Properties["sent"]},
+			})
+
+			// a date rather than a string, so that TTL indexes can use it
+			f.Properties[store.ArchivedAtField] = archivedAt
+			copies[i] = mongo.NewReplaceOneModel().SetFilter(bson.D{{Key: "_id", Value: f.ID}}).SetReplacement(f).SetUpsert(true)
+		}
+
+		if _, err = archive.BulkWrite(ctx, copies); err != nil {
+			return moved, fmt.Errorf("could not archive alerts: %w", err)
+		}
+
+		res, err := coll.BulkWrite(ctx, removals)
+		if err != nil {
+			return moved, fmt.Errorf("could not remove archived alerts: %w", err)
+		}
+		moved += int(res.DeletedCount)
+
+		// every alert in the batch was replaced by a newer version, which
+		// would be found again
+		if res.DeletedCount == 0 {
+			return moved, nil
+		}
+	}
+}
+
+// ApplyRetention makes the archive expire features of each type the given
+// time after they were archived, with a TTL index per type. Types that are
+// no longer in windows lose their index, and are kept forever
+func (c *MongoClient) ApplyRetention(ctx context.Context, windows map[string]time.Duration) error {
+	indexes := c.cli.Collection(ArchiveCollectionName).Indexes()
+
+	cursor, err := indexes.List(ctx)
+	if err != nil {
+		return fmt.Errorf("could not list archive indexes: %w", err)
+	}
+
+	var existing []struct {
+		Name               string `json:"name"`
+		ExpireAfterSeconds *int32 `json:"expireAfterSeconds"`
+	}
+	if err = cursor.All(ctx, &existing); err != nil {
+		return fmt.Errorf("could not decode archive indexes: %w", err)
+	}
+
+	current := map[string]int32{}
+	for _, idx := range existing {
+		if strings.HasPrefix(idx.Name, retentionIndexPrefix) && idx.ExpireAfterSeconds != nil {
+			current[idx.Name] = *idx.ExpireAfterSeconds
+		}
+	}
+
+	for featureType, window := range windows {
+		if _, ok := features.Types.Lookup(featureType); !ok {
+			return fmt.Errorf("retention window for %w %q", features.ErrUnknownType, featureType)
+		}
+
+		if window <= 0 {
+			return fmt.Errorf("retention window for %s must be positive", featureType)
+		}
+
+		name := retentionIndexPrefix + featureType
+		seconds := int32(window.Seconds())
+		if expiry, ok := current[name]; ok {
+			delete(current, name)
+			if expiry == seconds {
+				continue
+			}
+
+			if _, err = indexes.DropOne(ctx, name); err != nil {
+				return fmt.Errorf("could not drop retention index for %s: %w", featureType, err)
+			}
+		}
+
+		_, err = indexes.CreateOne(ctx, mongo.IndexModel{
+			Keys: bson.D{{Key: "properties." + store.ArchivedAtField, Value: 1}},
+			Options: options.Index().
+				SetName(name).
+				SetExpireAfterSeconds(seconds).
+				SetPartialFilterExpression(bson.D{{Key: "properties.@type", Value: featureType}}),
+		})
+		if err != nil {
+			return fmt.Errorf("could not create retention index for %s: %w", featureType, err)
+		}
+	}
+
+	errs := []error{}
+	for name := range current {
+		if _, err = indexes.DropOne(ctx, name); err != nil {
+			errs = append(errs, fmt.Errorf("could not drop retention index %s: %w", name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -2,43 +2,71 @@ package mongo
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
 
-	"github.com/jghiloni/watchedsky-social/backend/appcontext"
 	"github.com/jghiloni/watchedsky-social/backend/config"
+	"github.com/jghiloni/watchedsky-social/backend/store"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-type contextKey struct{}
-
-var clientContextKey contextKey
+// BackendName selects the Mongo store in config
+const BackendName = "mongo"
 
 type MongoClient struct {
 	cli *mongo.Database
 }
 
-func loadClientToContext(ctx context.Context, cfg config.AppConfig) (context.Context, error) {
-	if cfg.MongoDB.Username == "" {
-		return ctx, nil
+var _ store.FeatureStore = (*MongoClient)(nil)
+
+// openStore connects to Mongo and applies pending migrations. Mongo isn't
+// configured without a URI, host or username
+func openStore(ctx context.Context, cfg config.AppConfig) (store.FeatureStore, error) {
+	if !cfg.MongoDB.Configured() {
+		return nil, nil
 	}
 
-	dbConfig := mergeConfigs(cfg)
+	mongoClient, err := Connect(ctx, cfg.MongoDB)
+	if err != nil {
+		return nil, err
+	}
 
-	dsn := fmt.Sprintf("mongodb://%s:%s@%s/%s?authSource=%s",
-		dbConfig.Username, dbConfig.Password, dbConfig.Host,
-		dbConfig.Name, dbConfig.AuthenticationDatabase,
-	)
+	if _, err = mongoClient.Migrate(ctx); err != nil {
+		return nil, err
+	}
+
+	return mongoClient, nil
+}
+
+// Connect connects to the database described by cfg, with defaults filled
+// in for anything left unset. It doesn't run migrations
+func Connect(ctx context.Context, cfg config.DatabaseConfig) (*MongoClient, error) {
+	dsn, err := connectionURL(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	serverAPI := options.ServerAPI(options.ServerAPIVersion1)
-	opts := options.Client().ApplyURI(dsn).SetServerAPIOptions(serverAPI)
+	opts := options.Client().
+		ApplyURI(dsn.String()).
+		SetServerAPIOptions(serverAPI).
+		SetPoolMonitor(poolMonitor())
 
 	client, err := mongo.Connect(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	dbClient := client.Database(dbConfig.Name, options.Database().SetBSONOptions(&options.BSONOptions{
+	name := strings.TrimPrefix(dsn.Path, "/")
+	if name == "" {
+		name = defaultDatabaseName
+	}
+
+	dbClient := client.Database(name, options.Database().SetBSONOptions(&options.BSONOptions{
 		UseJSONStructTags:       true,
 		ErrorOnInlineDuplicates: false,
 		NilMapAsEmpty:           true,
@@ -46,39 +74,142 @@ func loadClientToContext(ctx context.Context, cfg config.AppConfig) (context.Con
 		NilByteSliceAsEmpty:     true,
 	}))
 
-	ctx = context.WithValue(ctx, clientContextKey, &MongoClient{cli: dbClient})
-	return ctx, nil
+	return &MongoClient{cli: dbClient}, nil
+}
+
+const (
+	defaultHost                   = "localhost:27017"
+	defaultDatabaseName           = "watchedsky"
+	defaultAuthenticationDatabase = "admin"
+)
+
+// ConnectionURI is the connection string for cfg, which the feature store
+// and the HTTP cache both connect with. It is cfg's URI, or one for its
+// hosts if it hasn't got one, with the rest of cfg applied over it
+func ConnectionURI(cfg config.DatabaseConfig) (string, error) {
+	dsn, err := connectionURL(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	return dsn.String(), nil
 }
 
-func mergeConfigs(cfg config.AppConfig) config.DatabaseConfig {
-	retConfig := config.DatabaseConfig{
-		Host:                   "localhost:27017",
-		Name:                   "watchedsky",
-		AuthenticationDatabase: "admin",
+func connectionURL(cfg config.DatabaseConfig) (*url.URL, error) {
+	dsn := &url.URL{Scheme: "mongodb", Host: defaultHost, Path: "/" + defaultDatabaseName}
+	query := url.Values{"authSource": {defaultAuthenticationDatabase}}
+
+	if cfg.URI != "" {
+		var err error
+		if dsn, err = parseURI(cfg.URI); err != nil {
+			return nil, err
+		}
+		query = dsn.Query()
 	}
 
-	mongoCfg := cfg.MongoDB
+	if cfg.Host != "" {
+		dsn.Host = cfg.Host
+	}
 
-	if mongoCfg.Host != "" {
-		retConfig.Host = cfg.MongoDB.Host
+	if cfg.Username != "" {
+		dsn.User = url.UserPassword(cfg.Username, cfg.Password)
 	}
 
-	if mongoCfg.Username != "" {
-		retConfig.Username = cfg.MongoDB.Username
+	if cfg.Name != "" {
+		dsn.Path = "/" + cfg.Name
+	}
+
+	settings := map[string]string{
+		"authSource":     cfg.AuthenticationDatabase,
+		"replicaSet":     cfg.ReplicaSet,
+		"readPreference": cfg.ReadPreference,
+
+		"tlsCAFile":                     cfg.TLS.CAFile,
+		"tlsCertificateKeyFile":         cfg.TLS.CertificateKeyFile,
+		"tlsCertificateKeyFilePassword": cfg.TLS.CertificateKeyFilePassword,
+	}
+
+	if cfg.TLS.Enabled {
+		settings["tls"] = "true"
+	}
+
+	if cfg.TLS.Insecure {
+		settings["tlsInsecure"] = "true"
+	}
+
+	for key, n := range map[string]uint64{
+		"maxPoolSize":   cfg.Pool.MaxSize,
+		"minPoolSize":   cfg.Pool.MinSize,
+		"maxConnecting": cfg.Pool.MaxConnecting,
+		"maxIdleTimeMS": uint64(cfg.Pool.MaxIdleTime.Milliseconds()),
+	} {
+		if n > 0 {
+			settings[key] = strconv.FormatUint(n, 10)
+		}
+	}
+
+	for key, value := range settings {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+
+	dsn.RawQuery = query.Encode()
+	return dsn, nil
+}
+
+// parseURI parses a Mongo connection string. url.Parse can't be used on the
+// whole of it, since it rejects lists of hosts with ports, and its errors
+// would include the password
+func parseURI(uri string) (*url.URL, error) {
+	scheme, rest, ok := strings.Cut(uri, "://")
+	if !ok || (scheme != "mongodb" && scheme != "mongodb+srv") {
+		return nil, errors.New("database URI must start with mongodb:// or mongodb+srv://")
+	}
+
+	authority, tail := rest, ""
+	if i := strings.IndexAny(rest, "/?"); i >= 0 {
+		authority, tail = rest[:i], rest[i:]
+	}
+
+	dsn, err := url.Parse(scheme + "://" + defaultHost + tail)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database URI: %w", errors.Unwrap(err))
+	}
+
+	dsn.Host = authority
+	if i := strings.LastIndex(authority, "@"); i >= 0 {
+		dsn.Host = authority[i+1:]
+
+		username, password, hasPassword := strings.Cut(authority[:i], ":")
+		if username, err = url.PathUnescape(username); err != nil {
+			return nil, errors.New("invalid database URI: bad escape in username")
+		}
+
+		dsn.User = url.User(username)
+		if hasPassword {
+			if password, err = url.PathUnescape(password); err != nil {
+				return nil, errors.New("invalid database URI: bad escape in password")
+			}
+
+			dsn.User = url.UserPassword(username, password)
+		}
 	}
 
-	if mongoCfg.Password != "" {
-		retConfig.Password = cfg.MongoDB.Password
+	if dsn.Host == "" {
+		return nil, errors.New("database URI has no hosts")
 	}
 
-	return retConfig
+	return dsn, nil
 }
 
 func init() {
-	appcontext.Registry.RegisterClient(loadClientToContext)
+	store.RegisterBackend(BackendName, openStore)
 }
 
+// GetClient returns the store in ctx if it is Mongo, for the operations only
+// Mongo has, like migrations
 func GetClient(ctx context.Context) *MongoClient {
-	cli, _ := ctx.Value(clientContextKey).(*MongoClient)
+	cli, _ := store.GetStore(ctx).(*MongoClient)
 	return cli
 }
@@ -0,0 +1,55 @@
+package mongo
+
+import (
+	"context"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Database is the database a client uses, for specs that set up documents
+// the client wouldn't write itself
+func (c *MongoClient) Database() *mongo.Database {
+	return c.cli
+}
+
+// ZoneGeometriesField is the property that lists the zones a stored alert's
+// geometry refers to
+const ZoneGeometriesField = zoneGeometriesField
+
+// WithZoneReferences takes the geometries of the zones an alert affects out of
+// its geometry collection
+var WithZoneReferences = withZoneReferences
+
+// SendDifferences compares two polls of stored features the way a watcher
+// on a standalone server does, and returns the events it sends. The
+// features mustn't need resolving, since there is no database behind it
+func SendDifferences(ctx context.Context, before features.Features, after features.Features) ([]store.FeatureEvent, error) {
+	snapshot := func(feats features.Features) map[string]snapshotEntry {
+		entries := map[string]snapshotEntry{}
+		for _, f := range feats {
+			raw, err := bson.Marshal(f)
+			if err != nil {
+				panic(err)
+			}
+			entries[f.ID] = snapshotEntry{raw: raw, feature: f}
+		}
+		return entries
+	}
+
+	w := &watcher{client: &MongoClient{}, events: make(chan store.FeatureEvent)}
+	done := make(chan error, 1)
+	go func() {
+		done <- w.sendDifferences(ctx, snapshot(before), snapshot(after))
+		close(w.events)
+	}()
+
+	events := []store.FeatureEvent{}
+	for event := range w.events {
+		events = append(events, event)
+	}
+
+	return events, <-done
+}
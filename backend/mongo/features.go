@@ -3,71 +3,157 @@ package mongo
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/jghiloni/watchedsky-social/backend/cql2"
 	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/geojson"
+	"github.com/jghiloni/watchedsky-social/backend/store"
 	"github.com/jghiloni/watchedsky-social/backend/utils"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-type PageOptions struct {
-	Page     uint `json:"page"`
-	PageSize uint `json:"pageSize"`
-}
+// collectionFor is the name of the collection a filter searches
+func collectionFor(f store.FeatureFilter) string {
+	if f.Archived {
+		return ArchiveCollectionName
+	}
 
-type FeaturePage struct {
-	PageInfo PageOptions       `json:",inline"`
-	Features features.Features `json:"features"`
+	return features.CollectionName
 }
 
-func (c *MongoClient) ListFeaturesByType(ctx context.Context, featureType string, pageInfo PageOptions) (FeaturePage, error) {
-	coll := c.cli.Collection("features")
+// filterQuery compiles a filter to a Mongo query. As in FindIntersecting,
+// spatial predicates on geometry also match the alerts that refer to zones
+// that match them. recheck is the filter's expression if it has any, which
+// features that were matched on an approximate geometry have to be checked
+// against once they are resolved, and nil otherwise
+func (c *MongoClient) filterQuery(ctx context.Context, f store.FeatureFilter) (query bson.D, recheck cql2.Expr, err error) {
+	query = bson.D{}
+	if f.Type != "" {
+		query = append(query, bson.E{Key: "properties.@type", Value: f.Type})
+	}
+
+	if len(f.EventCodes) > 0 {
+		codes := utils.Map(f.EventCodes, strings.ToUpper)
+		query = append(query, bson.E{Key: "properties.eventCode.SAME", Value: bson.D{{Key: "$in", Value: codes}}})
+	}
+
+	if len(f.HazardTags) > 0 {
+		query = append(query, bson.E{Key: "properties.hazards.tags", Value: bson.D{{Key: "$in", Value: f.HazardTags}}})
+	}
 
-	if pageInfo.PageSize < 1 {
-		pageInfo.PageSize = 1
+	if f.MinWindGustMPH > 0 {
+		query = append(query, bson.E{Key: "properties.hazards.maxWindGustMph", Value: bson.D{{Key: "$gte", Value: f.MinWindGustMPH}}})
 	}
 
-	if pageInfo.PageSize > 500 {
-		pageInfo.PageSize = 500
+	if f.MinHailSizeInches > 0 {
+		query = append(query, bson.E{Key: "properties.hazards.maxHailSizeIn", Value: bson.D{{Key: "$gte", Value: f.MinHailSizeInches}}})
 	}
 
-	query := bson.D{}
-	if featureType != "" {
-		query = bson.D{{"properties.@type", featureType}}
+	if f.Posted {
+		query = append(query, bson.E{Key: "properties.postUri", Value: bson.D{{Key: "$exists", Value: true}}})
 	}
 
-	cursor, err := coll.Find(ctx, query, &options.FindOptions{
-		Limit: utils.Ptr(int64(pageInfo.PageSize)),
-		Skip:  utils.Ptr(int64(pageInfo.PageSize * pageInfo.Page)),
-	})
+	patterns, err := f.SAMEPatterns()
 	if err != nil {
-		return FeaturePage{}, err
+		return nil, nil, err
 	}
-	defer cursor.Close(ctx)
 
-	feats := make([]features.Feature, 0, pageInfo.PageSize)
-	for cursor.Next(ctx) {
-		var f features.Feature
-		if err = cursor.Decode(&f); err != nil {
-			return FeaturePage{}, fmt.Errorf("could not decode feature: %w", err)
+	conditions := bson.A{}
+	for _, p := range patterns {
+		conditions = append(conditions, bson.D{{Key: "properties.geocode.SAME", Value: primitive.Regex{Pattern: p}}})
+	}
+
+	if f.Expression != nil {
+		types := []string{}
+		if f.Type != "" {
+			types = append(types, f.Type)
 		}
 
-		feats = append(feats, f)
+		spatial := false
+		var zonesErr error
+		expr := cql2.MongoWith(f.Expression, func(g geojson.Geometry, query bson.D) bson.D {
+			spatial = true
+			if !wantsAlerts(types) || zonesErr != nil {
+				return query
+			}
+
+			var widened bson.D
+			widened, zonesErr = c.orReferringTo(ctx, query)
+			return widened
+		})
+
+		if zonesErr != nil {
+			return nil, nil, zonesErr
+		}
+
+		conditions = append(conditions, expr)
+		if spatial {
+			recheck = f.Expression
+		}
 	}
 
-	return FeaturePage{
-		PageInfo: PageOptions{
-			Page:     pageInfo.Page,
-			PageSize: utils.WNMin(pageInfo.PageSize, uint(len(feats))),
-		},
-		Features: feats,
-	}, nil
+	if !f.ActiveAt.IsZero() {
+		conditions = append(conditions, activeAt(f.ActiveAt)...)
+	}
+
+	if len(conditions) > 0 {
+		query = append(query, bson.E{Key: "$and", Value: conditions})
+	}
+
+	return query, recheck, nil
+}
+
+// parsedDate parses a timestamp in an aggregation expression. Timestamps are
+// stored as strings with the issuing office's offset, so they can't be
+// compared as strings. Missing and invalid timestamps become null
+func parsedDate(expr any) bson.D {
+	return bson.D{{Key: "$dateFromString", Value: bson.D{
+		{Key: "dateString", Value: expr},
+		{Key: "onError", Value: nil},
+		{Key: "onNull", Value: nil},
+	}}}
+}
+
+// activeAt is the query equivalent of features.Feature.IsActiveAt
+func activeAt(t time.Time) bson.A {
+	starts := parsedDate(bson.D{{Key: "$ifNull", Value: bson.A{"$properties.effective", "$properties.sent"}}})
+	ends := parsedDate(bson.D{{Key: "$ifNull", Value: bson.A{"$properties.ends", "$properties.expires"}}})
+	replacedAt := parsedDate("$properties.replacedAt")
+
+	return bson.A{
+		bson.D{{Key: "properties.@type", Value: features.Alert}},
+		bson.D{{Key: "properties.messageType", Value: bson.D{{Key: "$ne", Value: "Cancel"}}}},
+		bson.D{{Key: "$expr", Value: bson.D{{Key: "$and", Value: bson.A{
+			bson.D{{Key: "$ne", Value: bson.A{starts, nil}}},
+			bson.D{{Key: "$lte", Value: bson.A{starts, t}}},
+			bson.D{{Key: "$or", Value: bson.A{
+				bson.D{{Key: "$eq", Value: bson.A{ends, nil}}},
+				bson.D{{Key: "$gt", Value: bson.A{ends, t}}},
+			}}},
+			bson.D{{Key: "$or", Value: bson.A{
+				bson.D{{Key: "$eq", Value: bson.A{replacedAt, nil}}},
+				bson.D{{Key: "$gt", Value: bson.A{replacedAt, t}}},
+			}}},
+		}}}}},
+	}
+}
+
+func (c *MongoClient) ListFeaturesByType(ctx context.Context, featureType string, pageInfo store.PageOptions) (store.FeaturePage, error) {
+	return c.ListFeatures(ctx, store.FeatureFilter{Type: featureType}, pageInfo)
+}
+
+// ListActiveAlerts returns the alerts in force at the given time
+func (c *MongoClient) ListActiveAlerts(ctx context.Context, at time.Time, pageInfo store.PageOptions) (store.FeaturePage, error) {
+	return c.ListFeatures(ctx, store.FeatureFilter{Type: features.Alert, ActiveAt: at}, pageInfo)
 }
 
 func (c *MongoClient) GetFeaturesByID(ctx context.Context, ids ...string) (features.FeatureCollection, error) {
 	coll := c.cli.Collection("features")
 
-	query := bson.D{{"_id", bson.D{{"$in", bson.A(utils.AnySlice(ids))}}}}
+	query := bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: bson.A(utils.AnySlice(ids))}}}}
 	cursor, err := coll.Find(ctx, query)
 	if err != nil {
 		return features.FeatureCollection{}, err
@@ -84,16 +170,30 @@ func (c *MongoClient) GetFeaturesByID(ctx context.Context, ids ...string) (featu
 		feats = append(feats, f)
 	}
 
+	if err = c.resolve(ctx, feats); err != nil {
+		return features.FeatureCollection{}, err
+	}
+
 	return features.FeatureCollection{
 		Features: feats,
 	}, nil
 }
 
-func (c *MongoClient) AddFeatures(ctx context.Context, feats ...features.Feature) error {
+// GetAffectedZones returns the zones an alert affects
+func (c *MongoClient) GetAffectedZones(ctx context.Context, alert features.Feature) (features.Features, error) {
+	zones := alert.AffectedZones()
+	if len(zones) == 0 {
+		return features.Features{}, nil
+	}
+
+	fc, err := c.GetFeaturesByID(ctx, zones...)
+	return fc.Features, err
+}
+
+// SetPostURI records the AT URI of the post that announced a feature
+func (c *MongoClient) SetPostURI(ctx context.Context, id string, uri string) error {
 	coll := c.cli.Collection("features")
-	_, err := coll.InsertMany(ctx, utils.AnySlice(feats), &options.InsertManyOptions{
-		Ordered: utils.Ptr(true),
-	})
+	_, err := coll.UpdateByID(ctx, id, bson.D{{Key: "$set", Value: bson.D{{Key: "properties.postUri", Value: uri}}}})
 
 	return err
 }
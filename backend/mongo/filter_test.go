@@ -0,0 +1,98 @@
+package mongo_test
+
+import (
+	"time"
+
+	"github.com/jghiloni/watchedsky-social/backend/cql2"
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/geojson"
+	"github.com/jghiloni/watchedsky-social/backend/store"
+	"github.com/jghiloni/watchedsky-social/backend/store/memory"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Filtering features", func() {
+	square := func(lon float64, lat float64) geojson.Polygon {
+		return geojson.Polygon{{
+			{Longitude: lon, Latitude: lat},
+			{Longitude: lon + 1, Latitude: lat},
+			{Longitude: lon + 1, Latitude: lat + 1},
+			{Longitude: lon, Latitude: lat + 1},
+			{Longitude: lon, Latitude: lat},
+		}}
+	}
+
+	ids := func(feats features.Features) []string {
+		out := []string{}
+		for _, f := range feats {
+			out = append(out, f.ID)
+		}
+		return out
+	}
+
+	It("Matches spatial filters on the zones alerts refer to, as the memory store does", func(ctx SpecContext) {
+		client := liveClient(ctx)
+		mem := memory.New()
+
+		zone := features.Feature{
+			ID:         "https://api.weather.gov/zones/county/ILC031",
+			Geometry:   square(-88, 41),
+			Properties: features.JSONObject{"@type": features.Zone, "id": "ILC031", "type": "county"},
+		}
+
+		// the alert's only geometry is its zone's, so Mongo stores none of
+		// it in the alert
+		alert := features.Feature{
+			ID: "zone-only",
+			Geometry: geojson.GeometryCollection{
+				GT:         geojson.GeometryCollectionType,
+				Geometries: []geojson.Geometry{square(-88, 41)},
+			},
+			Properties: features.JSONObject{
+				"@type":         features.Alert,
+				"id":            "zone-only",
+				"messageType":   "Alert",
+				"event":         "Tornado Warning",
+				"description":   "A tornado was sighted",
+				"sent":          "2024-06-05T21:00:00Z",
+				"expires":       "2099-01-01T00:00:00Z",
+				"affectedZones": []any{zone.ID},
+			},
+		}
+
+		for _, s := range []store.FeatureStore{client, mem} {
+			_, err := s.AddFeatures(ctx, zone)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = s.AddFeatures(ctx, alert)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		for filter, matched := range map[string][]string{
+			`S_INTERSECTS(geometry, POINT(-87.5 41.5))`:     {"zone-only"},
+			`S_INTERSECTS(geometry, POINT(-80 30))`:         {},
+			`NOT S_INTERSECTS(geometry, POINT(-87.5 41.5))`: {},
+		} {
+			expr, err := cql2.Parse(filter)
+			Expect(err).NotTo(HaveOccurred())
+
+			f := store.FeatureFilter{Type: features.Alert, Expression: expr}
+
+			want, err := mem.ListFeatures(ctx, f, store.PageOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ids(want.Features)).To(Equal(matched), filter)
+
+			got, err := client.ListFeatures(ctx, f, store.PageOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ids(got.Features)).To(Equal(ids(want.Features)), filter)
+
+			wantHits, err := mem.SearchFeatures(ctx, "tornado", f, store.PageOptions{})
+			Expect(err).NotTo(HaveOccurred())
+
+			gotHits, err := client.SearchFeatures(ctx, "tornado", f, store.PageOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ids(gotHits.Features())).To(Equal(ids(wantHits.Features())), filter)
+		}
+	}, SpecTimeout(30*time.Second))
+})
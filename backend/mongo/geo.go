@@ -0,0 +1,421 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/jghiloni/watchedsky-social/backend/cql2"
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/geojson"
+	"github.com/jghiloni/watchedsky-social/backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// geoKeysErrorCode is the error Mongo returns when a geometry can't be
+// indexed, e.g. because a polygon intersects itself
+const geoKeysErrorCode = 16755
+
+func isGeoKeysError(err error) bool {
+	var serverErr mongo.ServerError
+	return errors.As(err, &serverErr) && serverErr.HasErrorCode(geoKeysErrorCode)
+}
+
+// wantsAlerts reports whether a geo query for the given types can return
+// alerts, which are the only features that refer to zone geometries
+func wantsAlerts(types []string) bool {
+	if len(types) == 0 {
+		return true
+	}
+
+	for _, t := range types {
+		if t == features.Alert {
+			return true
+		}
+	}
+
+	return false
+}
+
+// zonesMatching returns the IDs of the features other than alerts that match
+// a geo query, which alerts may refer to for their geometry
+func (c *MongoClient) zonesMatching(ctx context.Context, query bson.D) (bson.A, error) {
+	ids, err := c.cli.Collection(features.CollectionName).Distinct(ctx, "_id", bson.D{{Key: "$and", Value: bson.A{
+		query,
+		bson.D{{Key: "properties.@type", Value: bson.D{{Key: "$ne", Value: features.Alert}}}},
+	}}})
+	if err != nil {
+		return nil, fmt.Errorf("could not find zones: %w", err)
+	}
+
+	return bson.A(ids), nil
+}
+
+// orReferringTo widens a geo query to also match the alerts that refer to
+// zones that match it
+func (c *MongoClient) orReferringTo(ctx context.Context, query bson.D) (bson.D, error) {
+	zones, err := c.zonesMatching(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(zones) == 0 {
+		return query, nil
+	}
+
+	return bson.D{{Key: "$or", Value: bson.A{
+		query,
+		bson.D{{Key: "properties." + zoneGeometriesField, Value: bson.D{{Key: "$in", Value: zones}}}},
+	}}}, nil
+}
+
+// approximate reports whether a stored feature's geometry isn't all there:
+// it was moved to GridFS and replaced with its bounding box, or it refers to
+// zones for part of it. Geo queries that match such features have to be
+// checked again once they are resolved
+func approximate(f features.Feature) bool {
+	_, spilled := f.Properties[geometryFileField]
+	return spilled || len(zoneReferences(f)) > 0
+}
+
+// matchesExactly checks a feature that a filter's query matched against
+// recheck, the filter's expression, if it was matched on an approximate
+// geometry. Such features are resolved in place
+func (c *MongoClient) matchesExactly(ctx context.Context, f *features.Feature, recheck cql2.Expr) (bool, error) {
+	if recheck == nil || !approximate(*f) {
+		return true, nil
+	}
+
+	if err := c.resolveOne(ctx, f); err != nil {
+		return false, err
+	}
+
+	return recheck.Match(*f), nil
+}
+
+// FindIntersecting returns the features whose geometry intersects g. If
+// types are given, only features of those types are returned
+func (c *MongoClient) FindIntersecting(ctx context.Context, g geojson.Geometry, types ...string) (features.FeatureCollection, error) {
+	if err := geojson.Validate(g); err != nil {
+		return features.FeatureCollection{}, fmt.Errorf("%w: %w", store.ErrInvalidFilter, err)
+	}
+
+	query := geoIntersects(g)
+	if wantsAlerts(types) {
+		var err error
+		if query, err = c.orReferringTo(ctx, query); err != nil {
+			return features.FeatureCollection{}, err
+		}
+	}
+
+	return c.findGeo(ctx, query, types, func(f features.Feature) bool {
+		return geojson.Intersects(f.Geometry, g)
+	})
+}
+
+// geoIntersects builds a $geoIntersects query. Mongo doesn't accept
+// geometry collections there, so each member is queried separately
+func geoIntersects(g geojson.Geometry) bson.D {
+	if gc, ok := g.(geojson.GeometryCollection); ok {
+		terms := bson.A{}
+		for _, child := range gc.Geometries {
+			terms = append(terms, geoIntersects(child))
+		}
+
+		return bson.D{{Key: "$or", Value: terms}}
+	}
+
+	return bson.D{{Key: "geometry", Value: bson.D{{Key: "$geoIntersects", Value: bson.D{
+		{Key: "$geometry", Value: g},
+	}}}}}
+}
+
+// geoDistanceField is where $geoNear puts each feature's distance
+const geoDistanceField = "geoDistance"
+
+// nearest returns the features that match query within maxDistanceKm of
+// point, with their distances in meters, nearest first. Any stages are run
+// on them afterwards
+func (c *MongoClient) nearest(ctx context.Context, point geojson.Point, maxDistanceKm float64, query bson.D, stages ...bson.D) (features.Features, map[string]float64, error) {
+	near := bson.D{
+		{Key: "near", Value: point},
+		{Key: "key", Value: "geometry"},
+		{Key: "distanceField", Value: geoDistanceField},
+		{Key: "spherical", Value: true},
+		{Key: "query", Value: query},
+	}
+	if maxDistanceKm > 0 {
+		near = append(near, bson.E{Key: "maxDistance", Value: maxDistanceKm * 1000})
+	}
+
+	pipeline := bson.A{bson.D{{Key: "$geoNear", Value: near}}}
+	for _, stage := range stages {
+		pipeline = append(pipeline, stage)
+	}
+
+	cursor, err := c.cli.Collection(features.CollectionName).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cursor.Close(ctx)
+
+	feats := features.Features{}
+	distances := map[string]float64{}
+	for cursor.Next(ctx) {
+		var f features.Feature
+		if err = cursor.Decode(&f); err != nil {
+			return nil, nil, fmt.Errorf("could not decode feature: %w", err)
+		}
+
+		var measured struct {
+			Distance float64 `json:"geoDistance"`
+		}
+		if err = cursor.Decode(&measured); err != nil {
+			return nil, nil, fmt.Errorf("could not decode distance: %w", err)
+		}
+
+		feats = append(feats, f)
+		distances[f.ID] = measured.Distance
+	}
+
+	return feats, distances, cursor.Err()
+}
+
+// FindNear returns the features within maxDistanceKm of point, nearest
+// first. A maxDistanceKm of 0 or less means no limit. Alerts are as near as
+// the nearest of their own geometry and the zones they refer to, and
+// geometries kept in GridFS are measured to their bounding boxes. If types
+// are given, only features of those types are returned
+func (c *MongoClient) FindNear(ctx context.Context, point geojson.Point, maxDistanceKm float64, types ...string) (features.FeatureCollection, error) {
+	if err := geojson.Validate(point); err != nil {
+		return features.FeatureCollection{}, fmt.Errorf("%w: %w", store.ErrInvalidFilter, err)
+	}
+
+	ofTypes := bson.D{}
+	if len(types) > 0 {
+		ofTypes = bson.D{{Key: "properties.@type", Value: bson.D{{Key: "$in", Value: types}}}}
+	}
+
+	feats, distances, err := c.nearest(ctx, point, maxDistanceKm, ofTypes)
+	if err != nil {
+		return features.FeatureCollection{}, err
+	}
+
+	if wantsAlerts(types) {
+		// only the zones' IDs are needed
+		zones, zoneDistances, err := c.nearest(ctx, point, maxDistanceKm, bson.D{
+			{Key: "properties.@type", Value: bson.D{{Key: "$ne", Value: features.Alert}}},
+		}, bson.D{{Key: "$project", Value: bson.D{{Key: geoDistanceField, Value: 1}}}})
+		if err != nil {
+			return features.FeatureCollection{}, err
+		}
+
+		if len(zones) > 0 {
+			ids := make(bson.A, len(zones))
+			for i, z := range zones {
+				ids[i] = z.ID
+			}
+
+			query := append(bson.D{{Key: "properties." + zoneGeometriesField, Value: bson.D{{Key: "$in", Value: ids}}}}, ofTypes...)
+			referring, err := c.find(ctx, query)
+			if err != nil {
+				return features.FeatureCollection{}, err
+			}
+
+			for _, f := range referring {
+				d, found := distances[f.ID]
+				for _, id := range zoneReferences(f) {
+					if zd, ok := zoneDistances[id]; ok && (!found || zd < d) {
+						d, found = zd, true
+					}
+				}
+
+				if _, ok := distances[f.ID]; !ok {
+					feats = append(feats, f)
+				}
+				distances[f.ID] = d
+			}
+		}
+	}
+
+	sort.SliceStable(feats, func(i, j int) bool {
+		return distances[feats[i].ID] < distances[feats[j].ID]
+	})
+
+	if err = c.resolve(ctx, feats); err != nil {
+		return features.FeatureCollection{}, err
+	}
+
+	return features.FeatureCollection{Features: feats}, nil
+}
+
+// FindWithinBBox returns the features whose geometry lies entirely within
+// bbox. Mongo treats the edges of the box as great circles, so the north and
+// south edges of a wide box bulge toward the pole. If types are given, only
+// features of those types are returned
+func (c *MongoClient) FindWithinBBox(ctx context.Context, bbox geojson.BoundingBox, types ...string) (features.FeatureCollection, error) {
+	if err := store.ValidBBox(bbox); err != nil {
+		return features.FeatureCollection{}, err
+	}
+
+	poly := bbox.Polygon()
+
+	query := bson.D{{Key: "geometry", Value: bson.D{{Key: "$geoWithin", Value: bson.D{
+		{Key: "$geometry", Value: poly},
+	}}}}}
+
+	if wantsAlerts(types) {
+		zones, err := c.zonesMatching(ctx, query)
+		if err != nil {
+			return features.FeatureCollection{}, err
+		}
+
+		// an alert that refers to zones is within the box if its own
+		// geometry is, or it has none, and every zone is
+		refs := "properties." + zoneGeometriesField
+		query = bson.D{
+			{Key: "$or", Value: bson.A{
+				query,
+				bson.D{{Key: "geometry", Value: nil}, {Key: refs + ".0", Value: bson.D{{Key: "$exists", Value: true}}}},
+			}},
+			{Key: refs, Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$elemMatch", Value: bson.D{{Key: "$nin", Value: zones}}}}}}},
+		}
+	}
+
+	return c.findGeo(ctx, query, types, func(f features.Feature) bool {
+		return store.WithinBBox(f.Geometry, bbox)
+	})
+}
+
+// find returns the current features that match query, as they are stored
+func (c *MongoClient) find(ctx context.Context, query bson.D) (features.Features, error) {
+	cursor, err := c.cli.Collection(features.CollectionName).Find(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	feats := features.Features{}
+	for cursor.Next(ctx) {
+		var f features.Feature
+		if err = cursor.Decode(&f); err != nil {
+			return nil, fmt.Errorf("could not decode feature: %w", err)
+		}
+
+		feats = append(feats, f)
+	}
+
+	return feats, cursor.Err()
+}
+
+// findGeo runs a geo query and resolves what it finds. Features that were
+// matched on a bounding box kept in place of their geometry, or on the
+// zones they refer to, are then checked again against their whole geometry
+// with exact
+func (c *MongoClient) findGeo(ctx context.Context, query bson.D, types []string, exact func(features.Feature) bool) (features.FeatureCollection, error) {
+	if len(types) > 0 {
+		query = append(query, bson.E{Key: "properties.@type", Value: bson.D{{Key: "$in", Value: types}}})
+	}
+
+	feats, err := c.find(ctx, query)
+	if err != nil {
+		return features.FeatureCollection{}, err
+	}
+
+	recheck := make([]bool, len(feats))
+	for i, f := range feats {
+		recheck[i] = approximate(f)
+	}
+
+	if err = c.resolve(ctx, feats); err != nil {
+		return features.FeatureCollection{}, err
+	}
+
+	matched := features.Features{}
+	for i, f := range feats {
+		if !recheck[i] || exact(f) {
+			matched = append(matched, f)
+		}
+	}
+
+	return features.FeatureCollection{Features: matched}, nil
+}
+
+// storable returns a copy of f with a geometry that Mongo will index. An
+// invalid geometry is repaired with geojson.Repair, and the original is kept
+// in the originalGeometry property
+func storable(f features.Feature) features.Feature {
+	if f.Geometry == nil || geojson.Validate(f.Geometry) == nil {
+		return f
+	}
+
+	repaired, _ := geojson.Repair(f.Geometry)
+	return withGeometry(f, repaired)
+}
+
+// degrade replaces a geometry that Mongo rejected even though it passed
+// geojson.Validate with its bounding box, or if that was rejected too, with
+// nothing. ok is false if there is nothing left to take away
+func degrade(f features.Feature) (degraded features.Feature, ok bool) {
+	if f.Geometry == nil {
+		return f, false
+	}
+
+	if box, found := geojson.Bounds(f.Geometry); found && !box.Empty() {
+		if poly := box.Polygon(); !reflect.DeepEqual(f.Geometry, poly) {
+			return withGeometry(f, poly), true
+		}
+	}
+
+	return withGeometry(f, nil), true
+}
+
+func withGeometry(f features.Feature, g geojson.Geometry) features.Feature {
+	props := make(features.JSONObject, len(f.Properties)+1)
+	for k, v := range f.Properties {
+		props[k] = v
+	}
+
+	if _, ok := props["originalGeometry"]; !ok {
+		props["originalGeometry"] = f.Geometry
+	}
+
+	f.Properties = props
+	f.Geometry = g
+	return f
+}
+
+// RepairGeometries repairs the stored features whose geometries fail
+// geojson.Validate, returning how many were changed. The 2dsphere index
+// can't be built while any are left
+func (c *MongoClient) RepairGeometries(ctx context.Context) (int, error) {
+	coll := c.cli.Collection(features.CollectionName)
+	cursor, err := coll.Find(ctx, bson.D{{Key: "geometry", Value: bson.D{{Key: "$ne", Value: nil}}}})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	repaired := 0
+	for cursor.Next(ctx) {
+		var f features.Feature
+		if err = cursor.Decode(&f); err != nil {
+			return repaired, fmt.Errorf("could not decode feature: %w", err)
+		}
+
+		if geojson.Validate(f.Geometry) == nil {
+			continue
+		}
+
+		fixed := storable(f)
+		if _, err = coll.ReplaceOne(ctx, bson.D{{Key: "_id", Value: f.ID}}, fixed); err != nil {
+			return repaired, fmt.Errorf("could not repair geometry of %s: %w", f.ID, err)
+		}
+		repaired++
+	}
+
+	return repaired, cursor.Err()
+}
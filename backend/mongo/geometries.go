@@ -0,0 +1,247 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/geojson"
+	"github.com/jghiloni/watchedsky-social/backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// zoneGeometriesField lists the zones whose geometries were taken out of a
+// stored alert's geometry collection, in the order they were in it. Hydrated
+// alerts carry the geometry of every zone they affect, which is stored once
+// in the zone instead
+const zoneGeometriesField = "zoneGeometries"
+
+// zoneReferences returns the zones whose geometries f refers to
+func zoneReferences(f features.Feature) []string {
+	refs, ok := utils.NormalizeSlice(f.Properties[zoneGeometriesField])
+	if !ok {
+		return nil
+	}
+
+	ids := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		if id, ok := ref.(string); ok {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// zoneGeometries returns the geometries of the zones with the given IDs, by
+// ID. Zones that aren't stored, or have no geometry, are left out
+func (c *MongoClient) zoneGeometries(ctx context.Context, ids []string) (map[string]geojson.Geometry, error) {
+	geometries := map[string]geojson.Geometry{}
+	if len(ids) == 0 {
+		return geometries, nil
+	}
+
+	zones, err := c.GetFeaturesByID(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, z := range zones.Features {
+		if z.Geometry != nil {
+			geometries[z.ID] = z.Geometry
+		}
+	}
+
+	return geometries, nil
+}
+
+// referenceZones replaces the members of each feature's geometry collection
+// that are the geometries of zones it affects with references to those
+// zones, so that the geometries are only stored once
+func (c *MongoClient) referenceZones(ctx context.Context, feats features.Features) (features.Features, error) {
+	ids := []string{}
+	for _, f := range feats {
+		if _, ok := f.Geometry.(geojson.GeometryCollection); ok {
+			ids = append(ids, f.AffectedZones()...)
+		}
+	}
+
+	zones, err := c.zoneGeometries(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	referenced := make(features.Features, len(feats))
+	for i, f := range feats {
+		referenced[i] = withZoneReferences(f, zones)
+	}
+
+	return referenced, nil
+}
+
+// withZoneReferences returns f with the members of its geometry collection
+// that are equal to the geometry of a zone it affects taken out and listed
+// in the zoneGeometries property. Empty members are dropped along with them.
+// f is returned as is if none of its members are zones
+func withZoneReferences(f features.Feature, zones map[string]geojson.Geometry) features.Feature {
+	gc, ok := f.Geometry.(geojson.GeometryCollection)
+	if !ok {
+		return f
+	}
+
+	affected := f.AffectedZones()
+	used := map[string]bool{}
+	refs := []string{}
+	remaining := []geojson.Geometry{}
+
+members:
+	for _, member := range gc.Geometries {
+		if member == nil {
+			continue
+		}
+
+		for _, id := range affected {
+			if zone, ok := zones[id]; ok && !used[id] && reflect.DeepEqual(member, zone) {
+				used[id] = true
+				refs = append(refs, id)
+				continue members
+			}
+		}
+
+		remaining = append(remaining, member)
+	}
+
+	if len(refs) == 0 {
+		return f
+	}
+
+	props := make(features.JSONObject, len(f.Properties)+1)
+	for k, v := range f.Properties {
+		props[k] = v
+	}
+	props[zoneGeometriesField] = refs
+	f.Properties = props
+
+	f.Geometry = nil
+	if len(remaining) > 0 {
+		f.Geometry = geojson.GeometryCollection{GT: geojson.GeometryCollectionType, Geometries: remaining}
+	}
+
+	return f
+}
+
+// resolve puts back the geometry that was taken out of stored features: the
+// geometries kept in GridFS, and then the geometries of the zones they
+// refer to, which are added to the end of their geometry collections. The
+// properties that record what was taken out are removed
+func (c *MongoClient) resolve(ctx context.Context, feats features.Features) error {
+	ids := []string{}
+	for i, f := range feats {
+		if file := f.Properties.StringValue(geometryFileField); file != "" {
+			g, err := c.loadGeometry(ctx, file)
+			if err != nil {
+				return err
+			}
+
+			feats[i].Geometry = g
+		}
+
+		ids = append(ids, zoneReferences(f)...)
+	}
+
+	zones, err := c.zoneGeometries(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for i, f := range feats {
+		_, spilled := f.Properties[geometryFileField]
+		_, referenced := f.Properties[zoneGeometriesField]
+		if !spilled && !referenced {
+			continue
+		}
+
+		if refs := zoneReferences(f); len(refs) > 0 {
+			members := []geojson.Geometry{}
+			if gc, ok := f.Geometry.(geojson.GeometryCollection); ok {
+				members = append(members, gc.Geometries...)
+			} else if f.Geometry != nil {
+				members = append(members, f.Geometry)
+			}
+
+			for _, id := range refs {
+				if zone, ok := zones[id]; ok {
+					members = append(members, zone)
+				}
+			}
+
+			feats[i].Geometry = geojson.GeometryCollection{GT: geojson.GeometryCollectionType, Geometries: members}
+		}
+
+		props := make(features.JSONObject, len(f.Properties))
+		for k, v := range f.Properties {
+			if k != geometryFileField && k != zoneGeometriesField {
+				props[k] = v
+			}
+		}
+		feats[i].Properties = props
+	}
+
+	return nil
+}
+
+// resolveOne is resolve for a single feature
+func (c *MongoClient) resolveOne(ctx context.Context, f *features.Feature) error {
+	feats := features.Features{*f}
+	if err := c.resolve(ctx, feats); err != nil {
+		return err
+	}
+
+	*f = feats[0]
+	return nil
+}
+
+// referenceStoredZones rewrites the alerts in a collection that were stored
+// with the geometries of their zones in them to refer to the zones instead
+func (c *MongoClient) referenceStoredZones(ctx context.Context, collection string) error {
+	coll := c.cli.Collection(collection)
+	cursor, err := coll.Find(ctx, bson.D{
+		{Key: "properties.@type", Value: features.Alert},
+		{Key: "geometry.type", Value: geojson.GeometryCollectionType},
+		{Key: "properties." + zoneGeometriesField, Value: bson.D{{Key: "$exists", Value: false}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var f features.Feature
+		if err = cursor.Decode(&f); err != nil {
+			return fmt.Errorf("could not decode feature: %w", err)
+		}
+
+		referenced, err := c.referenceZones(ctx, features.Features{f})
+		if err != nil {
+			return fmt.Errorf("could not look up zone geometries of %s: %w", f.ID, err)
+		}
+
+		if len(zoneReferences(referenced[0])) == 0 {
+			continue
+		}
+
+		doc, err := c.spill(ctx, referenced[0])
+		if err != nil {
+			return err
+		}
+
+		// a newer version stored in the meantime is left alone
+		filter := bson.D{{Key: "_id", Value: f.ID}, {Key: "properties.sent", Value: f.Properties["sent"]}}
+		if _, err = coll.ReplaceOne(ctx, filter, storable(doc)); err != nil {
+			return fmt.Errorf("could not store zone references of %s: %w", f.ID, err)
+		}
+	}
+
+	return cursor.Err()
+}
@@ -0,0 +1,81 @@
+package mongo_test
+
+import (
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/geojson"
+	"github.com/jghiloni/watchedsky-social/backend/mongo"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Zone references", func() {
+	square := func(lon float64, lat float64) geojson.Geometry {
+		return geojson.Polygon{{
+			{Longitude: lon, Latitude: lat},
+			{Longitude: lon + 1, Latitude: lat},
+			{Longitude: lon + 1, Latitude: lat + 1},
+			{Longitude: lon, Latitude: lat + 1},
+			{Longitude: lon, Latitude: lat},
+		}}
+	}
+
+	collection := func(members ...geojson.Geometry) geojson.Geometry {
+		return geojson.GeometryCollection{GT: geojson.GeometryCollectionType, Geometries: members}
+	}
+
+	alert := func(geometry geojson.Geometry, zones ...string) features.Feature {
+		return features.Feature{ID: "alert", Geometry: geometry, Properties: features.JSONObject{
+			"@type":         features.Alert,
+			"affectedZones": zones,
+		}}
+	}
+
+	zones := map[string]geojson.Geometry{
+		"zone/a": square(-90, 35),
+		"zone/b": square(-91, 35),
+	}
+
+	It("Replaces the members that are zone geometries with references", func() {
+		original := alert(collection(square(-91, 35), geojson.Point{Longitude: -90.5, Latitude: 35.5}, square(-90, 35)), "zone/a", "zone/b")
+
+		referenced := mongo.WithZoneReferences(original, zones)
+		Expect(referenced.Properties[mongo.ZoneGeometriesField]).To(Equal([]string{"zone/b", "zone/a"}))
+		Expect(referenced.Geometry).To(Equal(collection(geojson.Point{Longitude: -90.5, Latitude: 35.5})))
+
+		By("leaving the original alone")
+		Expect(original.Properties).NotTo(HaveKey(mongo.ZoneGeometriesField))
+		Expect(original.Geometry.(geojson.GeometryCollection).Geometries).To(HaveLen(3))
+	})
+
+	It("Only references the zones the alert affects", func() {
+		original := alert(collection(square(-90, 35), square(-91, 35)), "zone/a")
+
+		referenced := mongo.WithZoneReferences(original, zones)
+		Expect(referenced.Properties[mongo.ZoneGeometriesField]).To(Equal([]string{"zone/a"}))
+		Expect(referenced.Geometry).To(Equal(collection(square(-91, 35))))
+	})
+
+	It("Uses each zone once", func() {
+		original := alert(collection(square(-90, 35), square(-90, 35)), "zone/a")
+
+		referenced := mongo.WithZoneReferences(original, zones)
+		Expect(referenced.Properties[mongo.ZoneGeometriesField]).To(Equal([]string{"zone/a"}))
+		Expect(referenced.Geometry).To(Equal(collection(square(-90, 35))))
+	})
+
+	It("Drops the geometry when every member is a zone", func() {
+		original := alert(collection(square(-90, 35), nil, square(-91, 35)), "zone/a", "zone/b")
+
+		referenced := mongo.WithZoneReferences(original, zones)
+		Expect(referenced.Properties[mongo.ZoneGeometriesField]).To(Equal([]string{"zone/a", "zone/b"}))
+		Expect(referenced.Geometry).To(BeNil())
+	})
+
+	It("Leaves alerts without zone geometries as they are", func() {
+		unmatched := alert(collection(square(-80, 35)), "zone/a")
+		Expect(mongo.WithZoneReferences(unmatched, zones)).To(Equal(unmatched))
+
+		polygon := alert(square(-90, 35), "zone/a")
+		Expect(mongo.WithZoneReferences(polygon, zones)).To(Equal(polygon))
+	})
+})
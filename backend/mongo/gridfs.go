@@ -0,0 +1,204 @@
+package mongo
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/geojson"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GeometriesBucketName is the GridFS bucket that geometries too big to keep
+// in their features are stored in
+const GeometriesBucketName = "geometries"
+
+const (
+	// geometryFileField holds the GridFS id of a stored feature's geometry.
+	// The feature's own geometry is only its bounding box, so that geo
+	// queries still find it
+	geometryFileField = "geometryFile"
+
+	// maxEmbeddedVertices is the most vertices a geometry can have and still
+	// be stored in its feature. At around 30 bytes of BSON a vertex, bigger
+	// ones take up a large part of Mongo's 16 MB document limit
+	maxEmbeddedVertices = 1 << 15
+
+	// geometryFileGrace is how long a geometry file nothing refers to is
+	// kept, so that files uploaded for writes that haven't finished aren't
+	// removed
+	geometryFileGrace = time.Hour
+)
+
+// geometryBucket opens the GridFS bucket for geometries. GridFS doesn't take
+// contexts for uploads and downloads, so ctx's deadline is used instead
+func (c *MongoClient) geometryBucket(ctx context.Context) (*gridfs.Bucket, error) {
+	bucket, err := gridfs.NewBucket(c.cli, options.GridFSBucket().SetName(GeometriesBucketName))
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = bucket.SetReadDeadline(deadline)
+		_ = bucket.SetWriteDeadline(deadline)
+	}
+
+	return bucket, nil
+}
+
+// spill moves the geometry of a feature with more than maxEmbeddedVertices
+// vertices to GridFS, and replaces it with its bounding box. Other features
+// are returned as they are
+func (c *MongoClient) spill(ctx context.Context, f features.Feature) (features.Feature, error) {
+	if f.Geometry == nil || len(geojson.Vertices(f.Geometry)) <= maxEmbeddedVertices {
+		return f, nil
+	}
+
+	file, err := c.saveGeometry(ctx, f.ID, f.Geometry)
+	if err != nil {
+		return f, err
+	}
+
+	props := make(features.JSONObject, len(f.Properties)+1)
+	for k, v := range f.Properties {
+		props[k] = v
+	}
+	props[geometryFileField] = file
+	f.Properties = props
+
+	box, ok := geojson.Bounds(f.Geometry)
+	f.Geometry = nil
+	if ok && !box.Empty() {
+		f.Geometry = box.Polygon()
+	}
+
+	return f, nil
+}
+
+// saveGeometry stores g in GridFS and returns its id, which is the hash of
+// its contents, so the same geometry is only stored once however many
+// features have it
+func (c *MongoClient) saveGeometry(ctx context.Context, name string, g geojson.Geometry) (string, error) {
+	data, err := json.Marshal(features.Feature{ID: name, Geometry: g})
+	if err != nil {
+		return "", fmt.Errorf("could not encode geometry of %s: %w", name, err)
+	}
+
+	sum := sha256.Sum256(data)
+	id := hex.EncodeToString(sum[:])
+
+	bucket, err := c.geometryBucket(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// a stored copy is touched, so that it isn't pruned before the feature
+	// that refers to it is written
+	touched, err := bucket.GetFilesCollection().UpdateByID(ctx, id, bson.D{{Key: "$set", Value: bson.D{
+		{Key: "uploadDate", Value: time.Now().UTC()},
+	}}})
+	if err != nil {
+		return "", fmt.Errorf("could not look up geometry of %s: %w", name, err)
+	}
+
+	if touched.MatchedCount > 0 {
+		return id, nil
+	}
+
+	// a duplicate key means the same geometry was uploaded at the same time
+	err = bucket.UploadFromStreamWithID(id, name, bytes.NewReader(data))
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return "", fmt.Errorf("could not store geometry of %s: %w", name, err)
+	}
+
+	return id, nil
+}
+
+// loadGeometry reads a geometry stored by saveGeometry
+func (c *MongoClient) loadGeometry(ctx context.Context, id string) (geojson.Geometry, error) {
+	bucket, err := c.geometryBucket(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	if _, err = bucket.DownloadToStream(id, buf); err != nil {
+		return nil, fmt.Errorf("could not load geometry %s: %w", id, err)
+	}
+
+	var f features.Feature
+	if err = json.Unmarshal(buf.Bytes(), &f); err != nil {
+		return nil, fmt.Errorf("could not decode geometry %s: %w", id, err)
+	}
+
+	return f.Geometry, nil
+}
+
+// pruneGeometryFiles removes the geometry files that no current or archived
+// feature refers to any more, once they are older than geometryFileGrace
+func (c *MongoClient) pruneGeometryFiles(ctx context.Context) error {
+	bucket, err := c.geometryBucket(ctx)
+	if err != nil {
+		return err
+	}
+
+	cursor, err := bucket.FindContext(ctx, bson.D{{Key: "uploadDate", Value: bson.D{
+		{Key: "$lt", Value: time.Now().Add(-geometryFileGrace)},
+	}}})
+	if err != nil {
+		return fmt.Errorf("could not list geometry files: %w", err)
+	}
+
+	var files []struct {
+		ID string `json:"_id"`
+	}
+	if err = cursor.All(ctx, &files); err != nil {
+		return fmt.Errorf("could not decode geometry files: %w", err)
+	}
+
+	if len(files) == 0 {
+		return nil
+	}
+
+	candidates := make(bson.A, len(files))
+	for i, file := range files {
+		candidates[i] = file.ID
+	}
+
+	referenced := map[string]bool{}
+	for _, collection := range []string{features.CollectionName, ArchiveCollectionName} {
+		ids, err := c.cli.Collection(collection).Distinct(ctx, "properties."+geometryFileField, bson.D{
+			{Key: "properties." + geometryFileField, Value: bson.D{{Key: "$in", Value: candidates}}},
+		})
+		if err != nil {
+			return fmt.Errorf("could not find referenced geometry files: %w", err)
+		}
+
+		for _, id := range ids {
+			if s, ok := id.(string); ok {
+				referenced[s] = true
+			}
+		}
+	}
+
+	for _, file := range files {
+		if referenced[file.ID] {
+			continue
+		}
+
+		if err = bucket.DeleteContext(ctx, file.ID); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+			return fmt.Errorf("could not remove geometry file %s: %w", file.ID, err)
+		}
+	}
+
+	return nil
+}
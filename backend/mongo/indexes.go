@@ -0,0 +1,65 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EnsureIndexes creates the indexes that every registered feature type
+// needs. Types can share indexes, so they are deduplicated by name. It is run
+// by migrations, so changes to the registered indexes need a new migration
+func (c *MongoClient) EnsureIndexes(ctx context.Context) error {
+	return c.ensureTypeIndexes(ctx, features.CollectionName, features.Types.All()...)
+}
+
+// ensureTypeIndexes creates the indexes that the given feature types need on
+// a collection
+func (c *MongoClient) ensureTypeIndexes(ctx context.Context, collection string, defs ...features.TypeDefinition) error {
+	seen := map[string]bool{}
+	models := []mongo.IndexModel{}
+	for _, def := range defs {
+		for _, idx := range def.Indexes {
+			if seen[idx.Name] {
+				continue
+			}
+			seen[idx.Name] = true
+
+			opts := options.Index().SetName(idx.Name)
+			if idx.Unique {
+				opts.SetUnique(true)
+			}
+
+			if idx.Sparse {
+				opts.SetSparse(true)
+			}
+
+			models = append(models, mongo.IndexModel{Keys: idx.Keys, Options: opts})
+		}
+	}
+
+	if len(models) == 0 {
+		return nil
+	}
+
+	indexes := c.cli.Collection(collection).Indexes()
+	_, err := indexes.CreateMany(ctx, models)
+	if isGeoKeysError(err) && collection == features.CollectionName {
+		// features stored before geometries were checked can keep the
+		// 2dsphere index from being built
+		if _, err = c.RepairGeometries(ctx); err != nil {
+			return fmt.Errorf("could not repair feature geometries: %w", err)
+		}
+
+		_, err = indexes.CreateMany(ctx, models)
+	}
+
+	if err != nil {
+		return fmt.Errorf("could not create %s indexes: %w", collection, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// maxAlertVersions bounds how many versions of an alert are collected, in case
+// of reference cycles in bad data
+const maxAlertVersions = 500
+
+// GetAlertVersions returns every stored version of the alert with the given
+// ID, found by following references and replacedBy links in both directions.
+// Archived versions are included
+func (c *MongoClient) GetAlertVersions(ctx context.Context, id string) (features.Features, error) {
+	collections := []*mongo.Collection{
+		c.cli.Collection(features.CollectionName),
+		c.cli.Collection(ArchiveCollectionName),
+	}
+
+	found := map[string]features.Feature{}
+	queried := map[string]bool{}
+	frontier := []string{features.AlertIdentifier(id)}
+
+	for len(frontier) > 0 && len(found) < maxAlertVersions {
+		for _, id := range frontier {
+			queried[id] = true
+		}
+
+		query := bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: frontier}}}},
+			bson.D{{Key: "properties.id", Value: bson.D{{Key: "$in", Value: frontier}}}},
+			bson.D{{Key: "properties.references.identifier", Value: bson.D{{Key: "$in", Value: frontier}}}},
+		}}}
+
+		next := []string{}
+		enqueue := func(id string) {
+			if id != "" && !queried[id] {
+				queried[id] = true
+				next = append(next, id)
+			}
+		}
+
+		for _, coll := range collections {
+			cursor, err := coll.Find(ctx, query)
+			if err != nil {
+				return nil, err
+			}
+
+			for cursor.Next(ctx) {
+				var f features.Feature
+				if err = cursor.Decode(&f); err != nil {
+					cursor.Close(ctx)
+					return nil, fmt.Errorf("could not decode feature: %w", err)
+				}
+
+				if _, ok := found[f.AlertID()]; ok {
+					continue
+				}
+
+				found[f.AlertID()] = f
+				enqueue(f.AlertID())
+				enqueue(f.ReplacedBy())
+				for _, ref := range f.References() {
+					enqueue(ref)
+				}
+			}
+			cursor.Close(ctx)
+		}
+
+		frontier = next
+	}
+
+	versions := make(features.Features, 0, len(found))
+	for _, f := range found {
+		versions = append(versions, f)
+	}
+
+	if err := c.resolve(ctx, versions); err != nil {
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+// GetPreviousVersion returns the most recent stored alert that f updates or
+// cancels, current or archived. The second return value is false if there
+// isn't one
+func (c *MongoClient) GetPreviousVersion(ctx context.Context, f features.Feature) (features.Feature, bool, error) {
+	refs := f.References()
+	if len(refs) == 0 {
+		return features.Feature{}, false, nil
+	}
+
+	query := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: refs}}}},
+		bson.D{{Key: "properties.id", Value: bson.D{{Key: "$in", Value: refs}}}},
+	}}}
+
+	candidates := features.Features{}
+	for _, collection := range []string{features.CollectionName, ArchiveCollectionName} {
+		cursor, err := c.cli.Collection(collection).Find(ctx, query)
+		if err != nil {
+			return features.Feature{}, false, fmt.Errorf("could not get previous version of %s: %w", f.AlertID(), err)
+		}
+
+		var found features.Features
+		if err = cursor.All(ctx, &found); err != nil {
+			return features.Feature{}, false, fmt.Errorf("could not decode previous version of %s: %w", f.AlertID(), err)
+		}
+
+		candidates = append(candidates, found...)
+	}
+
+	previous, found := store.PreviousVersion(f, candidates)
+	if !found {
+		return features.Feature{}, false, nil
+	}
+
+	if err := c.resolveOne(ctx, &previous); err != nil {
+		return features.Feature{}, false, err
+	}
+
+	return previous, true, nil
+}
@@ -0,0 +1,290 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jghiloni/watchedsky-social/backend/config"
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MigrationsCollectionName is where applied migrations are recorded
+const MigrationsCollectionName = "migrations"
+
+// quarantineTTL is how long quarantined features are kept
+const quarantineTTL = 30 * 24 * time.Hour
+
+// Migration is one step in the evolution of the database. Steps must be
+// idempotent, since a step that fails part way through is run again in full
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, c *MongoClient) error
+}
+
+// MigrationRecord is stored for each applied migration
+type MigrationRecord struct {
+	Version   int       `json:"_id"`
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"appliedAt"`
+}
+
+// MigrationStatus is a migration along with when it was applied, if it has
+// been
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+}
+
+// Migrations are applied in the order listed, which must be by version. Never change or remove a
+// migration that has shipped; add a new one instead. Indexes added to
+// feature types need a migration that calls EnsureIndexes
+var Migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "feature type indexes",
+		Up: func(ctx context.Context, c *MongoClient) error {
+			return c.EnsureIndexes(ctx)
+		},
+	},
+	{
+		Version: 2,
+		Name:    "alert text index",
+		Up: func(ctx context.Context, c *MongoClient) error {
+			_, err := c.cli.Collection(features.CollectionName).Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys: bson.D{
+					{Key: "properties.event", Value: "text"},
+					{Key: "properties.headline", Value: "text"},
+					{Key: "properties.areaDesc", Value: "text"},
+					{Key: "properties.description", Value: "text"},
+				},
+				Options: options.Index().
+					SetName("alert_text").
+					SetDefaultLanguage("english").
+					// properties.language holds tags like en-US, which Mongo
+					// rejects, so the override points at a field nothing sets
+					SetLanguageOverride("textLanguage").
+					SetWeights(bson.D{
+						{Key: "properties.event", Value: 10},
+						{Key: "properties.headline", Value: 5},
+						{Key: "properties.areaDesc", Value: 5},
+						{Key: "properties.description", Value: 1},
+					}),
+			})
+			return err
+		},
+	},
+	{
+		Version: 3,
+		Name:    "quarantine expiry",
+		Up: func(ctx context.Context, c *MongoClient) error {
+			_, err := c.cli.Collection(QuarantineCollectionName).Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "quarantinedAt", Value: 1}},
+				Options: options.Index().SetName("quarantine_ttl").SetExpireAfterSeconds(int32(quarantineTTL.Seconds())),
+			})
+			return err
+		},
+	},
+	{
+		Version: 4,
+		Name:    "alert priority backfill",
+		Up: func(ctx context.Context, c *MongoClient) error {
+			return c.backfillPriority(ctx)
+		},
+	},
+	{
+		Version: 5,
+		Name:    "alert archive indexes",
+		Up: func(ctx context.Context, c *MongoClient) error {
+			alert, ok := features.Types.Lookup(features.Alert)
+			if !ok {
+				return fmt.Errorf("%s is not a registered feature type", features.Alert)
+			}
+
+			return c.ensureTypeIndexes(ctx, ArchiveCollectionName, alert)
+		},
+	},
+	{
+		Version: 6,
+		Name:    "alert text index with instructions and senders",
+		Up: func(ctx context.Context, c *MongoClient) error {
+			// a collection can only have one text index, so the one from
+			// migration 2 has to go
+			_, err := c.cli.Collection(features.CollectionName).Indexes().DropOne(ctx, "alert_text")
+			var serverErr mongo.ServerError
+			if err != nil && !(errors.As(err, &serverErr) && serverErr.HasErrorCode(indexNotFoundErrorCode)) {
+				return err
+			}
+
+			for _, collection := range []string{features.CollectionName, ArchiveCollectionName} {
+				if _, err = c.cli.Collection(collection).Indexes().CreateOne(ctx, alertTextIndex()); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	},
+	{
+		Version: 7,
+		Name:    "zone geometry references",
+		Up: func(ctx context.Context, c *MongoClient) error {
+			refs := mongo.IndexModel{
+				Keys:    bson.D{{Key: "properties." + zoneGeometriesField, Value: 1}},
+				Options: options.Index().SetName("zone_geometries").SetSparse(true),
+			}
+			files := mongo.IndexModel{
+				Keys:    bson.D{{Key: "properties." + geometryFileField, Value: 1}},
+				Options: options.Index().SetName("geometry_file").SetSparse(true),
+			}
+
+			if _, err := c.cli.Collection(features.CollectionName).Indexes().CreateMany(ctx, []mongo.IndexModel{refs, files}); err != nil {
+				return err
+			}
+
+			if _, err := c.cli.Collection(ArchiveCollectionName).Indexes().CreateOne(ctx, files); err != nil {
+				return err
+			}
+
+			for _, collection := range []string{features.CollectionName, ArchiveCollectionName} {
+				if err := c.referenceStoredZones(ctx, collection); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	},
+}
+
+// indexNotFoundErrorCode is returned when dropping an index that doesn't
+// exist
+const indexNotFoundErrorCode = 27
+
+// alertTextIndex covers the store.SearchFields, with the store.SearchWeights
+func alertTextIndex() mongo.IndexModel {
+	keys := bson.D{}
+	weighted := bson.D{}
+	for _, field := range store.SearchFields {
+		keys = append(keys, bson.E{Key: "properties." + field, Value: "text"})
+		weighted = append(weighted, bson.E{Key: "properties." + field, Value: store.SearchWeights[field]})
+	}
+
+	return mongo.IndexModel{
+		Keys: keys,
+		Options: options.Index().
+			SetName("alert_search").
+			SetDefaultLanguage("english").
+			SetLanguageOverride("textLanguage").
+			SetWeights(weighted),
+	}
+}
+
+// backfillPriority scores the alerts that were stored before priorities were
+func (c *MongoClient) backfillPriority(ctx context.Context) error {
+	model := features.DefaultPriorityModel.Merge(config.GetConfig(ctx).Priority)
+	coll := c.cli.Collection(features.CollectionName)
+
+	cursor, err := coll.Find(ctx, bson.D{
+		{Key: "properties.@type", Value: features.Alert},
+		{Key: "properties.priority", Value: bson.D{{Key: "$exists", Value: false}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var f features.Feature
+		if err = cursor.Decode(&f); err != nil {
+			return fmt.Errorf("could not decode feature: %w", err)
+		}
+
+		update := bson.D{{Key: "$set", Value: bson.D{{Key: "properties.priority", Value: model.Score(f)}}}}
+		if _, err = coll.UpdateByID(ctx, f.ID, update); err != nil {
+			return fmt.Errorf("could not set priority of %s: %w", f.ID, err)
+		}
+	}
+
+	return cursor.Err()
+}
+
+// Migrate applies the migrations that haven't been applied yet, in order,
+// and returns the ones it applied. It stops at the first failure
+func (c *MongoClient) Migrate(ctx context.Context) ([]MigrationRecord, error) {
+	applied, err := c.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	coll := c.cli.Collection(MigrationsCollectionName)
+	ran := []MigrationRecord{}
+	for _, m := range Migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+
+		if err = m.Up(ctx, c); err != nil {
+			return ran, fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+
+		record := MigrationRecord{Version: m.Version, Name: m.Name, AppliedAt: time.Now().UTC()}
+		// another instance may have applied it at the same time, which is
+		// fine, since migrations are idempotent
+		_, err = coll.ReplaceOne(ctx, bson.D{{Key: "_id", Value: m.Version}}, record, options.Replace().SetUpsert(true))
+		if err != nil {
+			return ran, fmt.Errorf("could not record migration %d: %w", m.Version, err)
+		}
+
+		ran = append(ran, record)
+	}
+
+	return ran, nil
+}
+
+// GetMigrationStatus lists every migration and when it was applied
+func (c *MongoClient) GetMigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := c.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(Migrations))
+	for _, m := range Migrations {
+		status := MigrationStatus{Version: m.Version, Name: m.Name}
+		if record, ok := applied[m.Version]; ok {
+			status.AppliedAt = &record.AppliedAt
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+func (c *MongoClient) appliedMigrations(ctx context.Context) (map[int]MigrationRecord, error) {
+	cursor, err := c.cli.Collection(MigrationsCollectionName).Find(ctx, bson.D{})
+	if err != nil {
+		return nil, fmt.Errorf("could not read migrations: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	applied := map[int]MigrationRecord{}
+	for cursor.Next(ctx) {
+		var record MigrationRecord
+		if err = cursor.Decode(&record); err != nil {
+			return nil, fmt.Errorf("could not decode migration: %w", err)
+		}
+
+		applied[record.Version] = record
+	}
+
+	return applied, cursor.Err()
+}
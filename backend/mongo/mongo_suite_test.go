@@ -0,0 +1,44 @@
+package mongo_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jghiloni/watchedsky-social/backend/config"
+	"github.com/jghiloni/watchedsky-social/backend/mongo"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestMongo(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Mongo Suite")
+}
+
+// testURIVariable names a Mongo server the specs that need one can use.
+// They are skipped without it. Each gets a database of its own, which is
+// dropped afterwards
+const testURIVariable = "WATCHEDSKY_TEST_MONGO_URI"
+
+func liveClient(ctx context.Context) *mongo.MongoClient {
+	uri := os.Getenv(testURIVariable)
+	if uri == "" {
+		Skip(testURIVariable + " is not set")
+	}
+
+	name := fmt.Sprintf("watchedsky_test_%d", time.Now().UnixNano())
+	client, err := mongo.Connect(ctx, config.DatabaseConfig{URI: uri, Name: name})
+	Expect(err).NotTo(HaveOccurred())
+
+	_, err = client.Migrate(ctx)
+	Expect(err).NotTo(HaveOccurred())
+
+	DeferCleanup(func(ctx context.Context) {
+		Expect(client.Database().Drop(ctx)).To(Succeed())
+	})
+
+	return client
+}
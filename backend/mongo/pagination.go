@@ -0,0 +1,146 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// sortBy is the Mongo sort for an order, reversed for backward cursors
+func sortBy(o store.SortOrder, backward bool) bson.D {
+	sort := bson.D{}
+	for _, k := range o.Keys() {
+		dir := 1
+		if k.Descending != backward {
+			dir = -1
+		}
+		sort = append(sort, bson.E{Key: k.Field, Value: dir})
+	}
+
+	return sort
+}
+
+// after matches the features that come after the cursor in its direction:
+// those past it on the first key, or tied on the first key and past it on
+// the next, and so on. Missing values sort before everything else, as they
+// do in Mongo
+func after(p store.Cursor) bson.D {
+	keys := p.Order.Keys()
+	branches := bson.A{}
+	for i, k := range keys {
+		branch := bson.D{}
+		for j := 0; j < i; j++ {
+			branch = append(branch, bson.E{Key: keys[j].Field, Value: p.Values[j]})
+		}
+
+		beyond, ok := beyond(k.Field, p.Values[i], k.Descending != p.Backward)
+		if !ok {
+			continue
+		}
+
+		branches = append(branches, append(branch, beyond))
+	}
+
+	return bson.D{{Key: "$or", Value: branches}}
+}
+
+// beyond matches values after v in a sort on field. ok is false if nothing
+// can come after v
+func beyond(field string, v any, descending bool) (cond bson.E, ok bool) {
+	switch {
+	case descending && v == nil:
+		return bson.E{}, false
+	case descending:
+		// comparisons only match values of the same type, so missing values
+		// have to be asked for
+		return bson.E{Key: "$or", Value: bson.A{
+			bson.D{{Key: field, Value: bson.D{{Key: "$lt", Value: v}}}},
+			bson.D{{Key: field, Value: nil}},
+		}}, true
+	case v == nil:
+		return bson.E{Key: field, Value: bson.D{{Key: "$ne", Value: nil}}}, true
+	}
+
+	return bson.E{Key: field, Value: bson.D{{Key: "$gt", Value: v}}}, true
+}
+
+// ListFeatures returns a page of the features that match filter. Features
+// matched by a spatial filter on an approximate geometry are checked again,
+// so page numbers and totals count some that may turn out not to match
+func (c *MongoClient) ListFeatures(ctx context.Context, filter store.FeatureFilter, pageInfo store.PageOptions) (store.FeaturePage, error) {
+	coll := c.cli.Collection(collectionFor(filter))
+	pageInfo = pageInfo.Normalized()
+
+	query, recheck, err := c.filterQuery(ctx, filter)
+	if err != nil {
+		return store.FeaturePage{}, err
+	}
+
+	// one extra feature is fetched to find out if there is another page.
+	// Features that are checked again are read until there are enough
+	opts := options.Find()
+	if recheck == nil {
+		opts.SetLimit(int64(pageInfo.PageSize) + 1)
+	}
+
+	var position *store.Cursor
+	pageQuery := query
+	if pageInfo.Cursor != "" {
+		p, err := store.DecodeCursor(pageInfo.Cursor, pageInfo.Order)
+		if err != nil {
+			return store.FeaturePage{}, err
+		}
+		position = &p
+
+		opts.SetSort(sortBy(pageInfo.Order, p.Backward))
+		pageQuery = bson.D{{Key: "$and", Value: bson.A{query, after(p)}}}
+	} else {
+		opts.SetSort(sortBy(pageInfo.Order, false)).SetSkip(int64(pageInfo.PageSize * pageInfo.Page))
+	}
+
+	cursor, err := coll.Find(ctx, pageQuery, opts)
+	if err != nil {
+		return store.FeaturePage{}, err
+	}
+	defer cursor.Close(ctx)
+
+	feats := make(features.Features, 0, pageInfo.PageSize+1)
+	for len(feats) <= int(pageInfo.PageSize) && cursor.Next(ctx) {
+		var f features.Feature
+		if err = cursor.Decode(&f); err != nil {
+			return store.FeaturePage{}, fmt.Errorf("could not decode feature: %w", err)
+		}
+
+		matched, err := c.matchesExactly(ctx, &f, recheck)
+		if err != nil {
+			return store.FeaturePage{}, err
+		}
+
+		if matched {
+			feats = append(feats, f)
+		}
+	}
+
+	if err = cursor.Err(); err != nil {
+		return store.FeaturePage{}, err
+	}
+
+	if err = c.resolve(ctx, feats); err != nil {
+		return store.FeaturePage{}, err
+	}
+
+	page := store.BuildPage(feats, pageInfo, position)
+	if pageInfo.WithTotal {
+		total, err := coll.CountDocuments(ctx, query)
+		if err != nil {
+			return store.FeaturePage{}, err
+		}
+		page.Total = &total
+	}
+
+	return page, nil
+}
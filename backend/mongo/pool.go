@@ -0,0 +1,73 @@
+package mongo
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// Connection pool metrics, by server address. They are registered with the
+// default Prometheus registry, which the metrics daemon serves
+var (
+	poolConnections = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "watchedsky",
+		Subsystem: "mongo_pool",
+		Name:      "connections",
+		Help:      "Open connections to each Mongo server",
+	}, []string{"address"})
+
+	poolConnectionsInUse = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "watchedsky",
+		Subsystem: "mongo_pool",
+		Name:      "connections_in_use",
+		Help:      "Connections to each Mongo server that are checked out of the pool",
+	}, []string{"address"})
+
+	poolCheckoutSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "watchedsky",
+		Subsystem: "mongo_pool",
+		Name:      "checkout_seconds",
+		Help:      "How long it took to check a connection out of the pool",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 4, 8),
+	}, []string{"address"})
+
+	poolCheckoutFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "watchedsky",
+		Subsystem: "mongo_pool",
+		Name:      "checkout_failures_total",
+		Help:      "Connections that couldn't be checked out of the pool, by reason",
+	}, []string{"address", "reason"})
+
+	poolClears = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "watchedsky",
+		Subsystem: "mongo_pool",
+		Name:      "cleared_total",
+		Help:      "Times the pool for each Mongo server was cleared after an error",
+	}, []string{"address"})
+)
+
+func init() {
+	prometheus.MustRegister(poolConnections, poolConnectionsInUse, poolCheckoutSeconds, poolCheckoutFailures, poolClears)
+}
+
+// poolMonitor records connection pool events in the pool metrics
+func poolMonitor() *event.PoolMonitor {
+	return &event.PoolMonitor{
+		Event: func(e *event.PoolEvent) {
+			switch e.Type {
+			case event.ConnectionCreated:
+				poolConnections.WithLabelValues(e.Address).Inc()
+			case event.ConnectionClosed:
+				poolConnections.WithLabelValues(e.Address).Dec()
+			case event.GetSucceeded:
+				poolConnectionsInUse.WithLabelValues(e.Address).Inc()
+				poolCheckoutSeconds.WithLabelValues(e.Address).Observe(e.Duration.Seconds())
+			case event.ConnectionReturned:
+				poolConnectionsInUse.WithLabelValues(e.Address).Dec()
+			case event.GetFailed:
+				poolCheckoutFailures.WithLabelValues(e.Address, e.Reason).Inc()
+			case event.PoolCleared:
+				poolClears.WithLabelValues(e.Address).Inc()
+			}
+		},
+	}
+}
@@ -0,0 +1,18 @@
+package mongo
+
+import (
+	"context"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/store"
+)
+
+// QuarantineCollectionName is where features that fail validation are kept
+const QuarantineCollectionName = "quarantine"
+
+// QuarantineFeature stores a feature that failed validation. source says
+// where it came from, e.g. poller or firehose
+func (c *MongoClient) QuarantineFeature(ctx context.Context, f features.Feature, source string, reason error) error {
+	_, err := c.cli.Collection(QuarantineCollectionName).InsertOne(ctx, store.Quarantined(f, source, reason))
+	return err
+}
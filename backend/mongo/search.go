@@ -0,0 +1,121 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SearchFeatures finds the features that match both a text search and
+// filter. q uses Mongo's $text syntax: words match any form of the word,
+// "quoted phrases" must appear as written, and -words must not appear.
+// Results are ordered by relevance and then by newest, so only page numbers
+// and not cursors can be used to page through them. Features matched by a
+// spatial filter on an approximate geometry are checked again, so page
+// numbers and totals count some that may turn out not to match
+func (c *MongoClient) SearchFeatures(ctx context.Context, q string, filter store.FeatureFilter, pageInfo store.PageOptions) (store.SearchPage, error) {
+	q = strings.TrimSpace(q)
+	if q == "" {
+		return store.SearchPage{}, store.ErrEmptySearch
+	}
+
+	pageInfo = pageInfo.Normalized()
+
+	query, recheck, err := c.filterQuery(ctx, filter)
+	if err != nil {
+		return store.SearchPage{}, err
+	}
+
+	query = bson.D{
+		{Key: "$text", Value: bson.D{{Key: "$search", Value: q}}},
+		{Key: "$and", Value: bson.A{query}},
+	}
+
+	score := bson.D{{Key: "$meta", Value: "textScore"}}
+	opts := options.Find().
+		SetProjection(bson.D{{Key: "score", Value: score}}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "properties.sent", Value: -1}, {Key: "_id", Value: 1}}).
+		SetSkip(int64(pageInfo.PageSize * pageInfo.Page))
+
+	// features that are checked again are read until there are enough
+	if recheck == nil {
+		opts.SetLimit(int64(pageInfo.PageSize))
+	}
+
+	coll := c.cli.Collection(collectionFor(filter))
+	cursor, err := coll.Find(ctx, query, opts)
+	if err != nil {
+		return store.SearchPage{}, fmt.Errorf("could not search features: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	pattern := store.HighlightPattern(q)
+	page := store.SearchPage{
+		PageInfo: store.PageOptions{Page: pageInfo.Page, PageSize: pageInfo.PageSize},
+		Query:    q,
+		Hits:     []store.SearchHit{},
+	}
+
+	for len(page.Hits) < int(pageInfo.PageSize) && cursor.Next(ctx) {
+		var hit store.SearchHit
+		if err = cursor.Decode(&hit.Feature); err != nil {
+			return store.SearchPage{}, fmt.Errorf("could not decode feature: %w", err)
+		}
+
+		matched, err := c.matchesExactly(ctx, &hit.Feature, recheck)
+		if err != nil {
+			return store.SearchPage{}, err
+		}
+
+		if !matched {
+			continue
+		}
+
+		var scored struct {
+			Score float64 `json:"score"`
+		}
+		if err = cursor.Decode(&scored); err != nil {
+			return store.SearchPage{}, fmt.Errorf("could not decode score: %w", err)
+		}
+		hit.Score = scored.Score
+
+		if pattern != nil {
+			hit.Highlights = store.Highlights(hit.Feature, pattern)
+		}
+
+		page.Hits = append(page.Hits, hit)
+	}
+
+	if err = cursor.Err(); err != nil {
+		return store.SearchPage{}, err
+	}
+	page.PageInfo.PageSize = uint(len(page.Hits))
+
+	feats := make(features.Features, len(page.Hits))
+	for i, hit := range page.Hits {
+		feats[i] = hit.Feature
+	}
+
+	if err = c.resolve(ctx, feats); err != nil {
+		return store.SearchPage{}, err
+	}
+
+	for i := range page.Hits {
+		page.Hits[i].Feature = feats[i]
+	}
+
+	if pageInfo.WithTotal {
+		total, err := coll.CountDocuments(ctx, query)
+		if err != nil {
+			return store.SearchPage{}, err
+		}
+		page.Total = &total
+	}
+
+	return page, nil
+}
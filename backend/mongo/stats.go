@@ -0,0 +1,211 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// statsQuery is the query for the alerts a StatsQuery selects. Alerts
+// matched by a spatial filter aren't checked again, so those that refer to
+// a geometry kept in GridFS are counted if its bounding box matches
+func (c *MongoClient) statsQuery(ctx context.Context, q store.StatsQuery) (bson.D, error) {
+	filter := q.Filter
+	filter.Type = features.Alert
+
+	query, _, err := c.filterQuery(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	query = append(query, bson.E{Key: "properties.messageType", Value: "Alert"})
+
+	sent := parsedDate("$properties.sent")
+	bounds := bson.A{}
+	if !q.From.IsZero() {
+		bounds = append(bounds, bson.D{{Key: "$gte", Value: bson.A{sent, q.From}}})
+	}
+
+	if !q.To.IsZero() {
+		bounds = append(bounds, bson.D{{Key: "$lt", Value: bson.A{sent, q.To}}})
+	}
+
+	if len(bounds) > 0 {
+		// null sorts before every date, so alerts without a sent time fall
+		// outside a From bound but have to be excluded from a To bound
+		bounds = append(bounds, bson.D{{Key: "$ne", Value: bson.A{sent, nil}}})
+		query = append(query, bson.E{Key: "$expr", Value: bson.D{{Key: "$and", Value: bounds}}})
+	}
+
+	return query, nil
+}
+
+// countBy groups alerts by an expression and counts each group
+func countBy(expr any) bson.A {
+	return bson.A{
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: expr},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+	}
+}
+
+type facetCount struct {
+	ID    *string `json:"_id"`
+	Count int64   `json:"count"`
+}
+
+func counts(facet []facetCount, key func(string) string) map[string]int64 {
+	counted := map[string]int64{}
+	for _, c := range facet {
+		if c.ID != nil {
+			counted[key(*c.ID)] += c.Count
+		}
+	}
+
+	return counted
+}
+
+func unchanged(s string) string {
+	return s
+}
+
+func stateAbbreviation(fips string) string {
+	if state, ok := features.StateAbbreviation(fips); ok {
+		return state
+	}
+
+	return fips
+}
+
+// GetAlertStats gathers statistics about the current and archived alerts a
+// query selects, in a single aggregation over both collections
+func (c *MongoClient) GetAlertStats(ctx context.Context, q store.StatsQuery) (store.AlertStats, error) {
+	query, err := c.statsQuery(ctx, q)
+	if err != nil {
+		return store.AlertStats{}, err
+	}
+
+	starts := parsedDate(bson.D{{Key: "$ifNull", Value: bson.A{"$properties.effective", "$properties.sent"}}})
+	ends := parsedDate(bson.D{{Key: "$ifNull", Value: bson.A{"$properties.ends", "$properties.expires"}}})
+	sameCodes := bson.D{{Key: "$ifNull", Value: bson.A{"$properties.geocode.SAME", bson.A{}}}}
+
+	pipeline := bson.A{
+		bson.D{{Key: "$match", Value: query}},
+		bson.D{{Key: "$unionWith", Value: bson.D{
+			{Key: "coll", Value: ArchiveCollectionName},
+			{Key: "pipeline", Value: bson.A{bson.D{{Key: "$match", Value: query}}}},
+		}}},
+		bson.D{{Key: "$project", Value: bson.D{
+			{Key: "event", Value: "$properties.event"},
+			{Key: "severity", Value: "$properties.severity"},
+			{Key: "sender", Value: "$properties.senderName"},
+			{Key: "sent", Value: parsedDate("$properties.sent")},
+			{Key: "duration", Value: bson.D{{Key: "$subtract", Value: bson.A{ends, starts}}}},
+			{Key: "states", Value: bson.D{{Key: "$setUnion", Value: bson.A{bson.D{{Key: "$map", Value: bson.D{
+				{Key: "input", Value: sameCodes},
+				{Key: "in", Value: bson.D{{Key: "$substrBytes", Value: bson.A{"$$this", 1, 2}}}},
+			}}}}}}},
+		}}},
+		bson.D{{Key: "$facet", Value: bson.D{
+			{Key: "total", Value: bson.A{bson.D{{Key: "$count", Value: "n"}}}},
+			{Key: "byEvent", Value: countBy("$event")},
+			{Key: "bySeverity", Value: countBy("$severity")},
+			{Key: "bySender", Value: countBy("$sender")},
+			{Key: "byState", Value: append(bson.A{bson.D{{Key: "$unwind", Value: "$states"}}}, countBy("$states")...)},
+			{Key: "byDay", Value: append(bson.A{
+				bson.D{{Key: "$match", Value: bson.D{{Key: "sent", Value: bson.D{{Key: "$ne", Value: nil}}}}}},
+			}, countBy(bson.D{{Key: "$dateToString", Value: bson.D{
+				{Key: "format", Value: "%Y-%m-%d"},
+				{Key: "date", Value: "$sent"},
+			}}})...)},
+			{Key: "durations", Value: bson.A{
+				bson.D{{Key: "$match", Value: bson.D{{Key: "duration", Value: bson.D{{Key: "$gte", Value: 0}}}}}},
+				bson.D{{Key: "$group", Value: bson.D{
+					{Key: "_id", Value: nil},
+					{Key: "ms", Value: bson.D{{Key: "$push", Value: "$duration"}}},
+				}}},
+			}},
+			{Key: "span", Value: bson.A{
+				bson.D{{Key: "$group", Value: bson.D{
+					{Key: "_id", Value: nil},
+					{Key: "first", Value: bson.D{{Key: "$min", Value: "$sent"}}},
+					{Key: "last", Value: bson.D{{Key: "$max", Value: "$sent"}}},
+				}}},
+			}},
+		}}},
+	}
+
+	cursor, err := c.cli.Collection(features.CollectionName).Aggregate(ctx, pipeline)
+	if err != nil {
+		return store.AlertStats{}, fmt.Errorf("could not aggregate alert stats: %w", err)
+	}
+
+	var results []struct {
+		Total []struct {
+			N int64 `json:"n"`
+		} `json:"total"`
+		ByEvent    []facetCount `json:"byEvent"`
+		BySeverity []facetCount `json:"bySeverity"`
+		BySender   []facetCount `json:"bySender"`
+		ByState    []facetCount `json:"byState"`
+		ByDay      []facetCount `json:"byDay"`
+		Durations  []struct {
+			MS []int64 `json:"ms"`
+		} `json:"durations"`
+		Span []struct {
+			First *time.Time `json:"first"`
+			Last  *time.Time `json:"last"`
+		} `json:"span"`
+	}
+
+	if err = cursor.All(ctx, &results); err != nil {
+		return store.AlertStats{}, fmt.Errorf("could not decode alert stats: %w", err)
+	}
+
+	stats := store.AlertStats{
+		ByEvent:    []store.Count{},
+		BySeverity: []store.Count{},
+		BySender:   []store.Count{},
+		ByState:    []store.Count{},
+		ByDay:      []store.Count{},
+	}
+
+	if len(results) == 0 {
+		return stats, nil
+	}
+
+	r := results[0]
+	if len(r.Total) > 0 {
+		stats.Total = r.Total[0].N
+	}
+
+	stats.ByEvent = store.SortCounts(counts(r.ByEvent, unchanged))
+	stats.BySeverity = store.SortCounts(counts(r.BySeverity, unchanged))
+	stats.BySender = store.SortCounts(counts(r.BySender, unchanged))
+	stats.ByState = store.SortCounts(counts(r.ByState, stateAbbreviation))
+	stats.ByDay = store.SortDays(counts(r.ByDay, unchanged))
+
+	if len(r.Durations) > 0 {
+		durations := make([]time.Duration, len(r.Durations[0].MS))
+		for i, ms := range r.Durations[0].MS {
+			durations[i] = time.Duration(ms) * time.Millisecond
+		}
+
+		sort.Slice(durations, func(i, j int) bool {
+			return durations[i] < durations[j]
+		})
+		stats.MedianDurationSeconds = store.Median(durations)
+	}
+
+	if len(r.Span) > 0 {
+		stats.FirstSent, stats.LastSent = r.Span[0].First, r.Span[0].Last
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,386 @@
+package mongo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/logging"
+	"github.com/jghiloni/watchedsky-social/backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// WatchersCollectionName is where named watchers keep their resume tokens
+const WatchersCollectionName = "watchers"
+
+const (
+	// changeStreamsUnsupportedCode is returned by standalone servers, which
+	// don't have an oplog to stream from
+	changeStreamsUnsupportedCode = 40573
+
+	// changeStreamHistoryLostCode is returned when a resume token is older
+	// than the oplog
+	changeStreamHistoryLostCode = 286
+
+	defaultPollInterval = 5 * time.Second
+	watchRetryDelay     = 5 * time.Second
+
+	// maxEventAttempts is how many times the feature a change stream event
+	// is about is looked up before the event is skipped
+	maxEventAttempts = 3
+)
+
+// WatchFeatures streams changes to the features that match filter until ctx
+// is done, when the channel is closed. Inserts and updates are only sent if
+// the feature matches filter afterwards. Deletes can't be checked against
+// filter, so every delete is sent. Spatial filters match alerts by the zones
+// they refer to as of when the watch starts.
+//
+// Change streams need a replica set. On a standalone server, such as in
+// development, the matching features are polled and compared instead, which
+// gets expensive for large result sets and doesn't resume across restarts.
+// Either way, errors after the watch starts are retried rather than ending
+// it. Events that can't be decoded, or whose features can't be looked up
+// after a few tries, are logged and skipped
+func (c *MongoClient) WatchFeatures(ctx context.Context, filter store.FeatureFilter, opts store.WatchOptions) (<-chan store.FeatureEvent, error) {
+	query, _, err := c.filterQuery(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultPollInterval
+	}
+
+	w := &watcher{
+		client: c,
+		coll:   c.cli.Collection(features.CollectionName),
+		tokens: c.cli.Collection(WatchersCollectionName),
+		query:  query,
+		opts:   opts,
+		events: make(chan store.FeatureEvent),
+		logger: logging.GetLogger(ctx).With(slog.String("collection", features.CollectionName), slog.String("watcher", opts.Name)),
+	}
+
+	stream, err := w.open(ctx)
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) && serverErr.HasErrorCode(changeStreamsUnsupportedCode) {
+		go w.poll(ctx)
+		return w.events, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	go w.stream(ctx, stream)
+	return w.events, nil
+}
+
+type watcher struct {
+	client *MongoClient
+	coll   *mongo.Collection
+	tokens *mongo.Collection
+	query  bson.D
+	opts   store.WatchOptions
+	events chan store.FeatureEvent
+	token  bson.Raw
+	logger *slog.Logger
+}
+
+// watcherState is stored for each named watcher
+type watcherState struct {
+	Name        string    `json:"_id"`
+	ResumeToken bson.Raw  `json:"resumeToken"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// open starts a change stream, resuming from the last token seen in this
+// process, or the stored one. A token that has fallen off the oplog is
+// dropped, and the stream starts from now
+func (w *watcher) open(ctx context.Context) (*mongo.ChangeStream, error) {
+	if w.token == nil && w.opts.Name != "" {
+		var state watcherState
+		err := w.tokens.FindOne(ctx, bson.D{{Key: "_id", Value: w.opts.Name}}).Decode(&state)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("could not load resume token: %w", err)
+		}
+		w.token = state.ResumeToken
+	}
+
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.D{{Key: "operationType", Value: bson.D{
+		{Key: "$in", Value: bson.A{"insert", "update", "replace", "delete"}},
+	}}}}}}
+
+	opts := options.ChangeStream()
+	if w.token != nil {
+		opts.SetResumeAfter(w.token)
+	}
+
+	stream, err := w.coll.Watch(ctx, pipeline, opts)
+	var serverErr mongo.ServerError
+	if w.token != nil && errors.As(err, &serverErr) && serverErr.HasErrorCode(changeStreamHistoryLostCode) {
+		w.token = nil
+		return w.coll.Watch(ctx, pipeline)
+	}
+
+	return stream, err
+}
+
+func (w *watcher) stream(ctx context.Context, stream *mongo.ChangeStream) {
+	defer close(w.events)
+
+	for {
+		w.drain(ctx, stream)
+		stream.Close(context.Background())
+
+		// the stream broke, so reopen it from the last token until that
+		// works or the watch is over
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(watchRetryDelay):
+			}
+
+			var err error
+			if stream, err = w.open(ctx); err == nil {
+				break
+			}
+
+			w.logger.Warn("could not reopen change stream", slog.Any("err", err))
+		}
+	}
+}
+
+// drain sends events from a change stream until it fails or ctx is done.
+// Events that can't be handled are skipped, because reopening the stream
+// from before them would only fail on them again
+func (w *watcher) drain(ctx context.Context, stream *mongo.ChangeStream) {
+	for stream.Next(ctx) {
+		var change struct {
+			OperationType string `json:"operationType"`
+			DocumentKey   struct {
+				ID string `json:"_id"`
+			} `json:"documentKey"`
+		}
+
+		if err := stream.Decode(&change); err != nil {
+			w.logger.Error("skipping change stream event that could not be decoded", slog.Any("err", err))
+			w.saveToken(ctx, stream.ResumeToken())
+			continue
+		}
+
+		event, ok, err := w.lookup(ctx, change.OperationType, change.DocumentKey.ID)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			w.logger.Error("skipping change to feature that could not be looked up", slog.String("id", change.DocumentKey.ID), slog.Any("err", err))
+			w.saveToken(ctx, stream.ResumeToken())
+			continue
+		}
+
+		if ok && !w.send(ctx, event) {
+			return
+		}
+
+		w.saveToken(ctx, stream.ResumeToken())
+	}
+
+	if err := stream.Err(); err != nil && ctx.Err() == nil {
+		w.logger.Warn("change stream failed", slog.Any("err", err))
+	}
+}
+
+// lookup is event, tried up to maxEventAttempts times with a growing delay
+// between tries
+func (w *watcher) lookup(ctx context.Context, operation string, id string) (store.FeatureEvent, bool, error) {
+	delay := watchRetryDelay
+	for attempt := 1; ; attempt++ {
+		event, ok, err := w.event(ctx, operation, id)
+		if err == nil || attempt == maxEventAttempts {
+			return event, ok, err
+		}
+
+		w.logger.Warn("could not look up changed feature, retrying", slog.String("id", id), slog.Int("attempt", attempt), slog.Any("err", err))
+
+		select {
+		case <-ctx.Done():
+			return event, false, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// event looks up the feature a change stream event is about, and checks it
+// against the filter
+func (w *watcher) event(ctx context.Context, operation string, id string) (store.FeatureEvent, bool, error) {
+	event := store.FeatureEvent{Type: store.ChangeUpdate, ID: id}
+	switch operation {
+	case "delete":
+		event.Type = store.ChangeDelete
+		return event, true, nil
+	case "insert":
+		event.Type = store.ChangeInsert
+	}
+
+	var f features.Feature
+	err := w.coll.FindOne(ctx, bson.D{{Key: "$and", Value: bson.A{bson.D{{Key: "_id", Value: id}}, w.query}}}).Decode(&f)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return event, false, nil
+	}
+
+	if err != nil {
+		return event, false, err
+	}
+
+	if err = w.client.resolveOne(ctx, &f); err != nil {
+		return event, false, err
+	}
+
+	event.Feature = &f
+	return event, true, nil
+}
+
+func (w *watcher) send(ctx context.Context, event store.FeatureEvent) bool {
+	select {
+	case w.events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// saveToken remembers how far the watcher has got. Failing to store it only
+// means that some events are sent again after a restart
+func (w *watcher) saveToken(ctx context.Context, token bson.Raw) {
+	w.token = token
+	if w.opts.Name == "" || token == nil {
+		return
+	}
+
+	state := watcherState{Name: w.opts.Name, ResumeToken: token, UpdatedAt: time.Now().UTC()}
+	_, _ = w.tokens.ReplaceOne(ctx, bson.D{{Key: "_id", Value: w.opts.Name}}, state, options.Replace().SetUpsert(true))
+}
+
+type snapshotEntry struct {
+	raw     bson.Raw
+	feature features.Feature
+}
+
+// poll compares the matching features every PollInterval with what they
+// were before, and sends the differences
+func (w *watcher) poll(ctx context.Context) {
+	defer close(w.events)
+
+	var seen map[string]snapshotEntry
+	ticker := time.NewTicker(w.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		current, err := w.snapshot(ctx)
+		if err == nil && seen != nil {
+			err = w.sendDifferences(ctx, seen, current)
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			w.logger.Warn("could not poll for changes", slog.Any("err", err))
+		}
+
+		// a round that failed is compared again on the next tick, which can
+		// send some of its events twice
+		if err == nil {
+			seen = current
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *watcher) snapshot(ctx context.Context) (map[string]snapshotEntry, error) {
+	cursor, err := w.coll.Find(ctx, w.query)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	snapshot := map[string]snapshotEntry{}
+	for cursor.Next(ctx) {
+		// a feature that can't be decoded would fail every round, so it is
+		// left out
+		var f features.Feature
+		if err = cursor.Decode(&f); err != nil {
+			w.logger.Error("skipping feature that could not be decoded", slog.Any("_id", cursor.Current.Lookup("_id")), slog.Any("err", err))
+			continue
+		}
+
+		snapshot[f.ID] = snapshotEntry{raw: append(bson.Raw{}, cursor.Current...), feature: f}
+	}
+
+	return snapshot, cursor.Err()
+}
+
+// sendDifferences sends an event for each feature that was added, changed
+// or removed between two snapshots, in order of id
+func (w *watcher) sendDifferences(ctx context.Context, before map[string]snapshotEntry, after map[string]snapshotEntry) error {
+	ids := make([]string, 0, len(before)+len(after))
+	for id := range after {
+		ids = append(ids, id)
+	}
+
+	for id := range before {
+		if _, ok := after[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		old, existed := before[id]
+		entry, exists := after[id]
+
+		var event store.FeatureEvent
+		switch {
+		case !exists:
+			event = store.FeatureEvent{Type: store.ChangeDelete, ID: id}
+		case !existed:
+			event = store.FeatureEvent{Type: store.ChangeInsert, ID: id}
+		case !bytes.Equal(old.raw, entry.raw):
+			event = store.FeatureEvent{Type: store.ChangeUpdate, ID: id}
+		default:
+			continue
+		}
+
+		if exists {
+			f := entry.feature
+			if err := w.client.resolveOne(ctx, &f); err != nil {
+				return err
+			}
+			event.Feature = &f
+		}
+
+		if !w.send(ctx, event) {
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
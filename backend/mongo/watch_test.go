@@ -0,0 +1,80 @@
+package mongo_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/mongo"
+	"github.com/jghiloni/watchedsky-social/backend/store"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var _ = Describe("Watching features", func() {
+	It("Skips events it can't handle and carries on", func(ctx SpecContext) {
+		client := liveClient(ctx)
+
+		watchCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		events, err := client.WatchFeatures(watchCtx, store.FeatureFilter{Type: features.Alert}, store.WatchOptions{PollInterval: 100 * time.Millisecond})
+		Expect(err).NotTo(HaveOccurred())
+
+		// a standalone server is polled, so let the first snapshot be taken
+		time.Sleep(200 * time.Millisecond)
+
+		// an _id that isn't a string can't be decoded
+		_, err = client.Database().Collection(features.CollectionName).InsertOne(ctx, bson.D{
+			{Key: "_id", Value: 42},
+			{Key: "properties", Value: bson.D{{Key: "@type", Value: features.Alert}}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = client.AddFeatures(ctx, features.Feature{ID: "good", Properties: features.JSONObject{
+			"@type":       features.Alert,
+			"id":          "good",
+			"messageType": "Alert",
+			"sent":        "2024-06-05T21:00:00Z",
+			"expires":     "2099-01-01T00:00:00Z",
+		}})
+		Expect(err).NotTo(HaveOccurred())
+
+		var event store.FeatureEvent
+		Eventually(events).WithTimeout(10 * time.Second).Should(Receive(&event))
+		Expect(event.Type).To(Equal(store.ChangeInsert))
+		Expect(event.ID).To(Equal("good"))
+	}, SpecTimeout(30*time.Second))
+
+	It("Sends what changed between polls, in order of id", func(ctx SpecContext) {
+		alert := func(id string, event string) features.Feature {
+			return features.Feature{ID: id, Properties: features.JSONObject{"@type": features.Alert, "event": event}}
+		}
+
+		before := features.Features{alert("a", "Tornado Warning"), alert("b", "Flood Watch"), alert("c", "Heat Advisory")}
+		after := features.Features{alert("d", "Wind Advisory"), alert("b", "Flood Warning"), alert("a", "Tornado Warning")}
+
+		events, err := mongo.SendDifferences(ctx, before, after)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(events).To(HaveLen(3))
+
+		Expect(events[0].Type).To(Equal(store.ChangeUpdate))
+		Expect(events[0].ID).To(Equal("b"))
+		Expect(events[0].Feature.Properties["event"]).To(Equal("Flood Warning"))
+
+		Expect(events[1]).To(Equal(store.FeatureEvent{Type: store.ChangeDelete, ID: "c"}))
+
+		Expect(events[2].Type).To(Equal(store.ChangeInsert))
+		Expect(events[2].ID).To(Equal("d"))
+		Expect(events[2].Feature).NotTo(BeNil())
+	})
+
+	It("Sends nothing when nothing changed", func(ctx SpecContext) {
+		feats := features.Features{{ID: "a", Properties: features.JSONObject{"@type": features.Alert}}}
+
+		events, err := mongo.SendDifferences(ctx, feats, feats)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(events).To(BeEmpty())
+	})
+})
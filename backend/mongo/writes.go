@@ -0,0 +1,115 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// duplicateKeyErrorCode is returned when an upsert's filter doesn't match
+// because the stored document is as new or newer, and the insert it falls
+// back to collides with that document
+const duplicateKeyErrorCode = 11000
+
+// AddFeatures upserts features in a single unordered bulk write, so one bad
+// feature doesn't stop the rest. A stored feature is only replaced by a
+// newer version, as decided by the sent time; writing the same or an older
+// version is skipped, which makes redelivery harmless. Features without a
+// sent time always replace what is stored. Zone geometries in hydrated
+// alerts are stored as references to the zones, and geometries too big to
+// embed are kept in GridFS. Geometries that Mongo can't index are repaired,
+// or replaced with their bounding box. The error is non-nil if the write
+// failed outright or any feature failed
+func (c *MongoClient) AddFeatures(ctx context.Context, feats ...features.Feature) (store.WriteResult, error) {
+	result := store.WriteResult{Outcomes: make([]store.WriteOutcome, len(feats))}
+	for i, f := range feats {
+		result.Outcomes[i].ID = f.ID
+	}
+
+	docs, err := c.referenceZones(ctx, feats)
+	if err != nil {
+		return result, fmt.Errorf("could not look up zone geometries: %w", err)
+	}
+
+	pending := make([]int, 0, len(docs))
+	for i := range docs {
+		if docs[i], err = c.spill(ctx, docs[i]); err != nil {
+			result.Record(i, store.WriteFailed, err)
+			continue
+		}
+
+		docs[i] = storable(docs[i])
+		pending = append(pending, i)
+	}
+
+	coll := c.cli.Collection(features.CollectionName)
+	for len(pending) > 0 {
+		models := make([]mongo.WriteModel, len(pending))
+		for j, i := range pending {
+			models[j] = upsertModel(docs[i])
+		}
+
+		res, err := coll.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+
+		var bulkErr mongo.BulkWriteException
+		if err != nil && (!errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil) {
+			return result, err
+		}
+
+		writeErrs := map[int]mongo.BulkWriteError{}
+		for _, we := range bulkErr.WriteErrors {
+			writeErrs[we.Index] = we
+		}
+
+		retry := []int{}
+		for j, i := range pending {
+			we, failed := writeErrs[j]
+			switch {
+			case !failed:
+				if _, upserted := res.UpsertedIDs[int64(j)]; upserted {
+					result.Record(i, store.WriteInserted, nil)
+				} else {
+					result.Record(i, store.WriteUpdated, nil)
+				}
+			case we.HasErrorCode(duplicateKeyErrorCode):
+				result.Record(i, store.WriteSkipped, nil)
+			case we.HasErrorCode(geoKeysErrorCode):
+				degraded, ok := degrade(docs[i])
+				if !ok {
+					result.Record(i, store.WriteFailed, we)
+					continue
+				}
+
+				docs[i] = degraded
+				retry = append(retry, i)
+			default:
+				result.Record(i, store.WriteFailed, we)
+			}
+		}
+
+		pending = retry
+	}
+
+	return result, result.Err()
+}
+
+// upsertModel replaces the stored version of a feature if it was sent
+// earlier than f, or inserts f if there is no stored version
+func upsertModel(f features.Feature) mongo.WriteModel {
+	filter := bson.D{{Key: "_id", Value: f.ID}}
+	if sent, ok := f.Time(features.SentField); ok {
+		stored := parsedDate("$properties.sent")
+		filter = append(filter, bson.E{Key: "$expr", Value: bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "$eq", Value: bson.A{stored, nil}}},
+			bson.D{{Key: "$lt", Value: bson.A{stored, sent}}},
+		}}}})
+	}
+
+	return mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(f).SetUpsert(true)
+}
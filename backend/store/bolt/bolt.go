@@ -0,0 +1,563 @@
+// Package bolt is a FeatureStore in a single file, for running without a
+// database server. It evaluates queries with the same Go code as the memory
+// store, using indexes kept alongside the features to narrow them down by
+// type, end time and location
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jghiloni/watchedsky-social/backend/config"
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/geojson"
+	"github.com/jghiloni/watchedsky-social/backend/store"
+	"go.etcd.io/bbolt"
+)
+
+// BackendName selects the bolt store in config
+const BackendName = "bolt"
+
+// DefaultPath is the database file used when the config doesn't give one
+const DefaultPath = "watchedsky.db"
+
+var (
+	// featuresBucket and archiveBucket hold features as JSON by id
+	featuresBucket = []byte("features")
+	archiveBucket  = []byte("alerts_archive")
+
+	// the type indexes are keyed by type and id, separated by a zero byte
+	featureTypesBucket = []byte("features_by_type")
+	archiveTypesBucket = []byte("archive_by_type")
+
+	// endsBucket indexes current alerts by when they end, and
+	// archivedAtBucket indexes the archive by when features were archived,
+	// to the type of the feature. Both are keyed by time and id
+	endsBucket       = []byte("alerts_by_end")
+	archivedAtBucket = []byte("archive_by_time")
+
+	quarantineBucket = []byte("quarantine")
+
+	settingsBucket = []byte("settings")
+	retentionKey   = []byte("retention")
+)
+
+// Store is a FeatureStore kept in a bolt database
+type Store struct {
+	db *bbolt.DB
+
+	// mu serializes writes with updates to the spatial index, which isn't
+	// stored, and is rebuilt when the store is opened
+	mu       sync.RWMutex
+	spatial  *rtree
+	notifier store.Notifier
+}
+
+var _ store.FeatureStore = (*Store)(nil)
+
+// Open opens the store in the file at path, creating it if it doesn't exist
+func Open(path string) (*Store, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", path, err)
+	}
+
+	s := &Store{db: db, spatial: newRTree()}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{
+			featuresBucket, archiveBucket, featureTypesBucket, archiveTypesBucket,
+			endsBucket, archivedAtBucket, quarantineBucket, settingsBucket,
+		} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return fmt.Errorf("could not create bucket %s: %w", name, err)
+			}
+		}
+
+		return each(tx, featuresBucket, func(f features.Feature) error {
+			s.index(f)
+			return nil
+		})
+	})
+
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Close closes the database file
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func openStore(ctx context.Context, cfg config.AppConfig) (store.FeatureStore, error) {
+	path := cfg.Store.Path
+	if path == "" {
+		path = DefaultPath
+	}
+
+	s, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		<-ctx.Done()
+		s.Close()
+	}()
+
+	return s, nil
+}
+
+func init() {
+	store.RegisterBackend(BackendName, openStore)
+}
+
+// index adds a feature's geometry to the spatial index, or removes it if
+// the feature has none. The caller must hold the write lock, or be opening
+// the store
+func (s *Store) index(f features.Feature) {
+	if f.Geometry != nil {
+		if box, ok := geojson.Bounds(f.Geometry); ok {
+			s.spatial.Insert(f.ID, box)
+			return
+		}
+	}
+
+	s.spatial.Delete(f.ID)
+}
+
+// typeKey is the key of a feature in a type index
+func typeKey(featureType string, id string) []byte {
+	return append(append([]byte(featureType), 0), id...)
+}
+
+// timeKey is the key of a feature in a time index. Times sort bytewise
+// from 1970 on
+func timeKey(t time.Time, id string) []byte {
+	key := binary.BigEndian.AppendUint64(nil, uint64(max(t.UnixNano(), 0)))
+	return append(key, id...)
+}
+
+func get(tx *bbolt.Tx, bucket []byte, id string) (features.Feature, bool, error) {
+	raw := tx.Bucket(bucket).Get([]byte(id))
+	if raw == nil {
+		return features.Feature{}, false, nil
+	}
+
+	var f features.Feature
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return features.Feature{}, false, fmt.Errorf("could not decode feature %s: %w", id, err)
+	}
+
+	return f, true, nil
+}
+
+func put(tx *bbolt.Tx, bucket []byte, f features.Feature) error {
+	raw, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket(bucket).Put([]byte(f.ID), raw)
+}
+
+// each calls fn with every feature in a bucket
+func each(tx *bbolt.Tx, bucket []byte, fn func(features.Feature) error) error {
+	return tx.Bucket(bucket).ForEach(func(k []byte, raw []byte) error {
+		var f features.Feature
+		if err := json.Unmarshal(raw, &f); err != nil {
+			return fmt.Errorf("could not decode feature %s: %w", k, err)
+		}
+
+		return fn(f)
+	})
+}
+
+// ofType calls fn with every feature of a type in a bucket, using its type
+// index
+func ofType(tx *bbolt.Tx, bucket []byte, typeBucket []byte, featureType string, fn func(features.Feature) error) error {
+	prefix := typeKey(featureType, "")
+	c := tx.Bucket(typeBucket).Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		f, ok, err := get(tx, bucket, string(k[len(prefix):]))
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			if err = fn(f); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// addCurrent stores a current feature and indexes it by type, and by end
+// time if it is an alert
+func addCurrent(tx *bbolt.Tx, f features.Feature) error {
+	if err := put(tx, featuresBucket, f); err != nil {
+		return err
+	}
+
+	if err := tx.Bucket(featureTypesBucket).Put(typeKey(f.Type(), f.ID), nil); err != nil {
+		return err
+	}
+
+	if ends, ok := f.EndsAt(); ok && f.Type() == features.Alert {
+		return tx.Bucket(endsBucket).Put(timeKey(ends, f.ID), nil)
+	}
+
+	return nil
+}
+
+// removeCurrent removes a stored current feature and its index entries
+func removeCurrent(tx *bbolt.Tx, f features.Feature) error {
+	if err := tx.Bucket(featuresBucket).Delete([]byte(f.ID)); err != nil {
+		return err
+	}
+
+	if err := tx.Bucket(featureTypesBucket).Delete(typeKey(f.Type(), f.ID)); err != nil {
+		return err
+	}
+
+	if ends, ok := f.EndsAt(); ok && f.Type() == features.Alert {
+		return tx.Bucket(endsBucket).Delete(timeKey(ends, f.ID))
+	}
+
+	return nil
+}
+
+// AddFeatures upserts features. A stored feature is only replaced by a newer
+// version, as decided by store.Supersedes. The features are written in one
+// transaction, so if it fails, none of them are
+func (s *Store) AddFeatures(ctx context.Context, feats ...features.Feature) (store.WriteResult, error) {
+	result := store.WriteResult{Outcomes: make([]store.WriteOutcome, len(feats))}
+	events := []store.FeatureEvent{}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		for i, f := range feats {
+			result.Outcomes[i].ID = f.ID
+
+			if _, err := json.Marshal(f); err != nil {
+				result.Record(i, store.WriteFailed, err)
+				continue
+			}
+
+			stored, exists, err := get(tx, featuresBucket, f.ID)
+			if err != nil {
+				return err
+			}
+
+			if exists && !store.Supersedes(f, stored) {
+				result.Record(i, store.WriteSkipped, nil)
+				continue
+			}
+
+			if exists {
+				if err = removeCurrent(tx, stored); err != nil {
+					return err
+				}
+			}
+
+			if err = addCurrent(tx, f); err != nil {
+				return err
+			}
+
+			if exists {
+				result.Record(i, store.WriteUpdated, nil)
+				events = append(events, store.FeatureEvent{Type: store.ChangeUpdate, ID: f.ID})
+			} else {
+				result.Record(i, store.WriteInserted, nil)
+				events = append(events, store.FeatureEvent{Type: store.ChangeInsert, ID: f.ID})
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return store.WriteResult{}, fmt.Errorf("could not write features: %w", err)
+	}
+
+	s.published(events)
+
+	return result, result.Err()
+}
+
+// published indexes the features that were written and tells watchers about
+// them, reading back each one so that watchers get a copy of their own. The
+// caller must hold the write lock
+func (s *Store) published(events []store.FeatureEvent) {
+	_ = s.db.View(func(tx *bbolt.Tx) error {
+		for _, event := range events {
+			if event.Type == store.ChangeDelete {
+				s.spatial.Delete(event.ID)
+				s.notifier.Publish(event.Type, features.Feature{ID: event.ID})
+				continue
+			}
+
+			f, ok, err := get(tx, featuresBucket, event.ID)
+			if err != nil || !ok {
+				continue
+			}
+
+			s.index(f)
+			s.notifier.Publish(event.Type, f)
+		}
+
+		return nil
+	})
+}
+
+// SetPostURI records the AT URI of the post that announced a feature
+func (s *Store) SetPostURI(ctx context.Context, id string, uri string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updated := false
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		f, ok, err := get(tx, featuresBucket, id)
+		if err != nil || !ok {
+			return err
+		}
+
+		f.Properties["postUri"] = uri
+		updated = true
+		return put(tx, featuresBucket, f)
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not set post URI of %s: %w", id, err)
+	}
+
+	if updated {
+		s.published([]store.FeatureEvent{{Type: store.ChangeUpdate, ID: id}})
+	}
+
+	return nil
+}
+
+// QuarantineFeature keeps a feature that failed validation
+func (s *Store) QuarantineFeature(ctx context.Context, f features.Feature, source string, reason error) error {
+	raw, err := json.Marshal(store.Quarantined(f, source, reason))
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(quarantineBucket)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		return b.Put(binary.BigEndian.AppendUint64(nil, seq), raw)
+	})
+}
+
+// Quarantined returns the features that have been quarantined, oldest first
+func (s *Store) Quarantined() ([]store.QuarantinedFeature, error) {
+	quarantined := []store.QuarantinedFeature{}
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(quarantineBucket).ForEach(func(_ []byte, raw []byte) error {
+			var q store.QuarantinedFeature
+			if err := json.Unmarshal(raw, &q); err != nil {
+				return err
+			}
+
+			quarantined = append(quarantined, q)
+			return nil
+		})
+	})
+
+	return quarantined, err
+}
+
+// ArchiveEndedAlerts moves the alerts that ended before t to the archive,
+// and drops archived features that are past their retention window
+func (s *Store) ArchiveEndedAlerts(ctx context.Context, t time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	archivedAt := time.Now().UTC()
+	events := []store.FeatureEvent{}
+
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		ended := []string{}
+		until := timeKey(t, "")[:8]
+		c := tx.Bucket(endsBucket).Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k[:8], until) < 0; k, _ = c.Next() {
+			ended = append(ended, string(k[8:]))
+		}
+
+		for _, id := range ended {
+			f, ok, err := get(tx, featuresBucket, id)
+			if err != nil {
+				return err
+			}
+
+			if !ok {
+				continue
+			}
+
+			if err = removeCurrent(tx, f); err != nil {
+				return err
+			}
+
+			f.Properties[store.ArchivedAtField] = archivedAt.Format(time.RFC3339Nano)
+			if err = addArchived(tx, f, archivedAt); err != nil {
+				return err
+			}
+
+			events = append(events, store.FeatureEvent{Type: store.ChangeDelete, ID: id})
+		}
+
+		return expire(tx, archivedAt)
+	})
+
+	if err != nil {
+		return 0, fmt.Errorf("could not archive alerts: %w", err)
+	}
+
+	s.published(events)
+	return len(events), nil
+}
+
+// addArchived stores a feature in the archive, and indexes it by type and
+// when it was archived
+func addArchived(tx *bbolt.Tx, f features.Feature, archivedAt time.Time) error {
+	if stored, ok, err := get(tx, archiveBucket, f.ID); err != nil {
+		return err
+	} else if ok {
+		if err = removeArchived(tx, stored); err != nil {
+			return err
+		}
+	}
+
+	if err := put(tx, archiveBucket, f); err != nil {
+		return err
+	}
+
+	if err := tx.Bucket(archiveTypesBucket).Put(typeKey(f.Type(), f.ID), nil); err != nil {
+		return err
+	}
+
+	return tx.Bucket(archivedAtBucket).Put(timeKey(archivedAt, f.ID), []byte(f.Type()))
+}
+
+// removeArchived removes a feature from the archive and its indexes
+func removeArchived(tx *bbolt.Tx, f features.Feature) error {
+	if err := tx.Bucket(archiveBucket).Delete([]byte(f.ID)); err != nil {
+		return err
+	}
+
+	if err := tx.Bucket(archiveTypesBucket).Delete(typeKey(f.Type(), f.ID)); err != nil {
+		return err
+	}
+
+	if archivedAt, ok := f.Properties.TimeValue(store.ArchivedAtField); ok {
+		return tx.Bucket(archivedAtBucket).Delete(timeKey(archivedAt, f.ID))
+	}
+
+	return nil
+}
+
+// ApplyRetention sets how long archived features of each type are kept
+// after they are archived. Types without a window are kept forever. The
+// windows are stored, and applied whenever alerts are archived
+func (s *Store) ApplyRetention(ctx context.Context, windows map[string]time.Duration) error {
+	for featureType, window := range windows {
+		if _, ok := features.Types.Lookup(featureType); !ok {
+			return fmt.Errorf("retention window for %w %q", features.ErrUnknownType, featureType)
+		}
+
+		if window <= 0 {
+			return fmt.Errorf("retention window for %s must be positive", featureType)
+		}
+	}
+
+	raw, err := json.Marshal(windows)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		if err := tx.Bucket(settingsBucket).Put(retentionKey, raw); err != nil {
+			return err
+		}
+
+		return expire(tx, time.Now())
+	})
+}
+
+// expire drops archived features that are past their retention window as
+// of now
+func expire(tx *bbolt.Tx, now time.Time) error {
+	var windows map[string]time.Duration
+	if raw := tx.Bucket(settingsBucket).Get(retentionKey); raw != nil {
+		if err := json.Unmarshal(raw, &windows); err != nil {
+			return fmt.Errorf("could not decode retention windows: %w", err)
+		}
+	}
+
+	if len(windows) == 0 {
+		return nil
+	}
+
+	// nothing archived after the shortest window ago has expired
+	shortest := time.Duration(0)
+	for _, window := range windows {
+		if shortest == 0 || window < shortest {
+			shortest = window
+		}
+	}
+	until := timeKey(now.Add(-shortest), "")[:8]
+
+	expired := []string{}
+	c := tx.Bucket(archivedAtBucket).Cursor()
+	for k, v := c.First(); k != nil && bytes.Compare(k[:8], until) < 0; k, v = c.Next() {
+		window, ok := windows[string(v)]
+		archivedAt := time.Unix(0, int64(binary.BigEndian.Uint64(k[:8])))
+		if ok && archivedAt.Add(window).Before(now) {
+			expired = append(expired, string(k[8:]))
+		}
+	}
+
+	for _, id := range expired {
+		f, ok, err := get(tx, archiveBucket, id)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			if err = removeArchived(tx, f); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// WatchFeatures sends the changes to current features that match filter
+// until ctx is done, when the channel is closed. Changes are only seen from
+// when the watch starts, so the options are ignored
+func (s *Store) WatchFeatures(ctx context.Context, filter store.FeatureFilter, opts store.WatchOptions) (<-chan store.FeatureEvent, error) {
+	return s.notifier.Watch(ctx, filter)
+}
@@ -0,0 +1,217 @@
+package bolt_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/geojson"
+	"github.com/jghiloni/watchedsky-social/backend/store"
+	"github.com/jghiloni/watchedsky-social/backend/store/bolt"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Bolt store", func() {
+	var (
+		ctx  context.Context
+		path string
+		s    *bolt.Store
+	)
+
+	alert := func(id string, sent string, props features.JSONObject) features.Feature {
+		p := features.JSONObject{
+			"@type":       features.Alert,
+			"id":          id,
+			"messageType": "Alert",
+			"sent":        sent,
+			"expires":     "2024-06-05T23:30:00Z",
+		}
+		for k, v := range props {
+			p[k] = v
+		}
+
+		return features.Feature{ID: id, Properties: p}
+	}
+
+	zone := func(id string, lon float64, lat float64) features.Feature {
+		return features.Feature{
+			ID:         id,
+			Properties: features.JSONObject{"@type": "wx:Zone", "id": id},
+			Geometry: geojson.Polygon{{
+				{Longitude: lon, Latitude: lat},
+				{Longitude: lon + 0.5, Latitude: lat},
+				{Longitude: lon + 0.5, Latitude: lat + 0.5},
+				{Longitude: lon, Latitude: lat + 0.5},
+				{Longitude: lon, Latitude: lat},
+			}},
+		}
+	}
+
+	ids := func(feats features.Features) []string {
+		out := []string{}
+		for _, f := range feats {
+			out = append(out, f.ID)
+		}
+		return out
+	}
+
+	reopen := func() {
+		Expect(s.Close()).To(Succeed())
+
+		var err error
+		s, err = bolt.Open(path)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		path = filepath.Join(GinkgoT().TempDir(), "features.db")
+
+		var err error
+		s, err = bolt.Open(path)
+		Expect(err).NotTo(HaveOccurred())
+
+		DeferCleanup(func() {
+			s.Close()
+		})
+	})
+
+	It("Keeps features across restarts, replacing them only with newer versions", func() {
+		res, err := s.AddFeatures(ctx, alert("a", "2024-06-05T21:00:00Z", features.JSONObject{"headline": "first"}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Outcomes[0].Status).To(Equal(store.WriteInserted))
+
+		reopen()
+
+		res, err = s.AddFeatures(ctx,
+			alert("a", "2024-06-05T20:00:00Z", features.JSONObject{"headline": "old"}),
+			alert("b", "2024-06-05T20:00:00Z", nil),
+		)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Outcomes[0].Status).To(Equal(store.WriteSkipped))
+		Expect(res.Outcomes[1].Status).To(Equal(store.WriteInserted))
+
+		Expect(s.SetPostURI(ctx, "a", "at://post")).To(Succeed())
+
+		fc, err := s.GetFeaturesByID(ctx, "b", "a", "missing")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(fc.Features)).To(Equal([]string{"b", "a"}))
+		Expect(fc.Features[1].Properties.StringValue("headline")).To(Equal("first"))
+		Expect(fc.Features[1].Properties.StringValue("postUri")).To(Equal("at://post"))
+	})
+
+	It("Pages through features of a type", func() {
+		for i := 0; i < 5; i++ {
+			_, err := s.AddFeatures(ctx,
+				alert(fmt.Sprintf("a%d", i), fmt.Sprintf("2024-06-05T2%d:00:00Z", i), nil),
+				zone(fmt.Sprintf("z%d", i), float64(-100+i), 40),
+			)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		filter := store.FeatureFilter{Type: features.Alert}
+		first, err := s.ListFeatures(ctx, filter, store.PageOptions{PageSize: 3, WithTotal: true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(first.Features)).To(Equal([]string{"a4", "a3", "a2"}))
+		Expect(*first.Total).To(BeEquivalentTo(5))
+
+		second, err := s.ListFeatures(ctx, filter, store.PageOptions{PageSize: 3, Cursor: first.Next})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(second.Features)).To(Equal([]string{"a1", "a0"}))
+		Expect(second.Next).To(BeEmpty())
+
+		page, err := s.ListFeatures(ctx, store.FeatureFilter{Type: "wx:Zone"}, store.PageOptions{PageSize: 10})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(page.Features).To(HaveLen(5))
+	})
+
+	It("Finds features with the spatial index after updates and deletes", func() {
+		zones := features.Features{}
+		for i := 0; i < 20; i++ {
+			for j := 0; j < 20; j++ {
+				zones = append(zones, zone(fmt.Sprintf("z%02d%02d", i, j), float64(-110+i), float64(30+j)))
+			}
+		}
+		_, err := s.AddFeatures(ctx, zones...)
+		Expect(err).NotTo(HaveOccurred())
+
+		// moving a zone must take it out of its old place in the index
+		moved := zone("z0000", 0, 0)
+		moved.Properties["sent"] = "2024-06-05T21:00:00Z"
+		_, err = s.AddFeatures(ctx, moved)
+		Expect(err).NotTo(HaveOccurred())
+
+		fc, err := s.FindIntersecting(ctx, geojson.Point{Longitude: -109.75, Latitude: 30.25})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fc.Features).To(BeEmpty())
+
+		fc, err = s.FindIntersecting(ctx, geojson.Point{Longitude: -104.75, Latitude: 35.25})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(fc.Features)).To(Equal([]string{"z0505"}))
+
+		reopen()
+
+		fc, err = s.FindWithinBBox(ctx, geojson.BoundingBox{MinLongitude: -101, MinLatitude: 39, MaxLongitude: -99.4, MaxLatitude: 40.6})
+		Expect(err).NotTo(HaveOccurred())
+		found := ids(fc.Features)
+		sort.Strings(found)
+		Expect(found).To(Equal([]string{"z0909", "z0910", "z1009", "z1010"}))
+
+		fc, err = s.FindNear(ctx, geojson.Point{Longitude: 0.25, Latitude: 0.25}, 100)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(fc.Features)).To(Equal([]string{"z0000"}))
+
+		fc, err = s.FindNear(ctx, geojson.Point{Longitude: -89, Latitude: 49}, 0, "wx:Zone")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fc.Features).To(HaveLen(400))
+		Expect(fc.Features[0].ID).To(Equal("z1919"))
+	})
+
+	It("Archives ended alerts and expires them by type", func() {
+		_, err := s.AddFeatures(ctx,
+			alert("ended", "2024-06-05T21:00:00Z", features.JSONObject{"event": "Tornado Warning"}),
+			alert("current", "2024-06-05T21:00:00Z", features.JSONObject{"expires": "2099-01-01T00:00:00Z"}),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		events, err := s.WatchFeatures(ctx, store.FeatureFilter{}, store.WatchOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		moved, err := s.ArchiveEndedAlerts(ctx, time.Now())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(moved).To(Equal(1))
+
+		var event store.FeatureEvent
+		Eventually(events).Should(Receive(&event))
+		Expect(event).To(Equal(store.FeatureEvent{Type: store.ChangeDelete, ID: "ended"}))
+
+		page, err := s.SearchFeatures(ctx, "tornado", store.FeatureFilter{Archived: true}, store.PageOptions{PageSize: 10})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(page.Features())).To(Equal([]string{"ended"}))
+
+		versions, err := s.GetAlertVersions(ctx, "ended")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(versions)).To(Equal([]string{"ended"}))
+
+		previous, found, err := s.GetPreviousVersion(ctx, alert("update", "2024-06-05T22:00:00Z", features.JSONObject{"references": []any{"ended"}}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeTrue())
+		Expect(previous.ID).To(Equal("ended"))
+
+		// windows are keyed by full type names by the time they get here
+		Expect(s.ApplyRetention(ctx, map[string]time.Duration{"Alert": time.Nanosecond})).To(MatchError(features.ErrUnknownType))
+		Expect(s.ApplyRetention(ctx, map[string]time.Duration{features.Alert: time.Nanosecond})).To(Succeed())
+
+		page, err = s.SearchFeatures(ctx, "tornado", store.FeatureFilter{Archived: true}, store.PageOptions{PageSize: 10})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(page.Hits).To(BeEmpty())
+
+		current, err := s.ListFeatures(ctx, store.FeatureFilter{}, store.PageOptions{PageSize: 10})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(current.Features)).To(Equal([]string{"current"}))
+	})
+})
@@ -0,0 +1,292 @@
+package bolt
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/geojson"
+	"github.com/jghiloni/watchedsky-social/backend/store"
+	"github.com/jghiloni/watchedsky-social/backend/utils"
+	"go.etcd.io/bbolt"
+)
+
+// collection returns the buckets a filter searches, and the type index for
+// them
+func collection(filter store.FeatureFilter) ([]byte, []byte) {
+	if filter.Archived {
+		return archiveBucket, archiveTypesBucket
+	}
+
+	return featuresBucket, featureTypesBucket
+}
+
+// matching returns the features that match a filter, in no particular
+// order. Filters on a type only read features of that type
+func (s *Store) matching(filter store.FeatureFilter) (features.Features, error) {
+	match, err := filter.Predicate()
+	if err != nil {
+		return nil, err
+	}
+
+	matched := features.Features{}
+	collect := func(f features.Feature) error {
+		if match(f) {
+			matched = append(matched, f)
+		}
+		return nil
+	}
+
+	bucket, typeBucket := collection(filter)
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		if filter.Type != "" {
+			return ofType(tx, bucket, typeBucket, filter.Type, collect)
+		}
+
+		return each(tx, bucket, collect)
+	})
+
+	return matched, err
+}
+
+// ListFeatures returns a page of the features that match filter
+func (s *Store) ListFeatures(ctx context.Context, filter store.FeatureFilter, pageInfo store.PageOptions) (store.FeaturePage, error) {
+	matched, err := s.matching(filter)
+	if err != nil {
+		return store.FeaturePage{}, err
+	}
+
+	return store.PageOf(matched, pageInfo)
+}
+
+// SearchFeatures finds the features that match both a text search and
+// filter, most relevant first, as store.Rank decides
+func (s *Store) SearchFeatures(ctx context.Context, q string, filter store.FeatureFilter, pageInfo store.PageOptions) (store.SearchPage, error) {
+	matched, err := s.matching(filter)
+	if err != nil {
+		return store.SearchPage{}, err
+	}
+
+	return store.Rank(matched, q, pageInfo)
+}
+
+// GetFeaturesByID returns the current features with the given IDs, in the
+// order asked for. IDs that aren't stored are left out
+func (s *Store) GetFeaturesByID(ctx context.Context, ids ...string) (features.FeatureCollection, error) {
+	feats := make(features.Features, 0, len(ids))
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		for _, id := range ids {
+			f, ok, err := get(tx, featuresBucket, id)
+			if err != nil {
+				return err
+			}
+
+			if ok {
+				feats = append(feats, f)
+			}
+		}
+
+		return nil
+	})
+
+	return features.FeatureCollection{Features: feats}, err
+}
+
+// GetAffectedZones returns the zones an alert affects
+func (s *Store) GetAffectedZones(ctx context.Context, alert features.Feature) (features.Features, error) {
+	fc, err := s.GetFeaturesByID(ctx, alert.AffectedZones()...)
+	return fc.Features, err
+}
+
+// alerts returns every alert in the given buckets
+func (s *Store) alerts(archived ...bool) (features.Features, error) {
+	alerts := features.Features{}
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		for _, a := range archived {
+			bucket, typeBucket := collection(store.FeatureFilter{Archived: a})
+			err := ofType(tx, bucket, typeBucket, features.Alert, func(f features.Feature) error {
+				alerts = append(alerts, f)
+				return nil
+			})
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return alerts, err
+}
+
+// GetAlertVersions returns every stored version of the alert with the given
+// ID, found by following references and replacedBy links in both directions.
+// Archived versions are included
+func (s *Store) GetAlertVersions(ctx context.Context, id string) (features.Features, error) {
+	candidates, err := s.alerts(false, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.AlertVersions(id, candidates), nil
+}
+
+// GetPreviousVersion returns the most recent stored alert that f updates or
+// cancels, current or archived. The second return value is false if there
+// isn't one
+func (s *Store) GetPreviousVersion(ctx context.Context, f features.Feature) (features.Feature, bool, error) {
+	if len(f.References()) == 0 {
+		return features.Feature{}, false, nil
+	}
+
+	candidates, err := s.alerts(false, true)
+	if err != nil {
+		return features.Feature{}, false, err
+	}
+
+	previous, found := store.PreviousVersion(f, candidates)
+	return previous, found, nil
+}
+
+// near returns the current features whose bounding boxes intersect box and
+// that match, using the spatial index
+func (s *Store) near(box geojson.BoundingBox, match func(features.Feature) bool) (features.Features, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := []string{}
+	s.spatial.Search(box, func(id string) {
+		ids = append(ids, id)
+	})
+	sort.Strings(ids)
+
+	matched := features.Features{}
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		for _, id := range ids {
+			f, ok, err := get(tx, featuresBucket, id)
+			if err != nil {
+				return err
+			}
+
+			if ok && f.Geometry != nil && match(f) {
+				matched = append(matched, f)
+			}
+		}
+
+		return nil
+	})
+
+	return matched, err
+}
+
+// FindIntersecting returns the features whose geometry intersects g. If
+// types are given, only features of those types are returned
+func (s *Store) FindIntersecting(ctx context.Context, g geojson.Geometry, types ...string) (features.FeatureCollection, error) {
+	if err := geojson.Validate(g); err != nil {
+		return features.FeatureCollection{}, fmt.Errorf("%w: %w", store.ErrInvalidFilter, err)
+	}
+
+	box, ok := geojson.Bounds(g)
+	if !ok {
+		return features.FeatureCollection{Features: features.Features{}}, nil
+	}
+
+	isType := store.OfTypes(types...)
+	matched, err := s.near(box, func(f features.Feature) bool {
+		return isType(f) && geojson.Intersects(f.Geometry, g)
+	})
+
+	return features.FeatureCollection{Features: matched}, err
+}
+
+// kmPerDegree is the length of a degree of latitude, and of longitude at the
+// equator
+const kmPerDegree = 111.195
+
+// around returns a box that holds every point within distanceKm of c. It
+// spans every longitude near the poles
+func around(c geojson.Coordinate, distanceKm float64) geojson.BoundingBox {
+	dLat := distanceKm / kmPerDegree
+	box := geojson.BoundingBox{
+		MinLongitude: math.Inf(-1),
+		MinLatitude:  c.Latitude - dLat,
+		MaxLongitude: math.Inf(1),
+		MaxLatitude:  c.Latitude + dLat,
+	}
+
+	if farthest := math.Max(math.Abs(box.MinLatitude), math.Abs(box.MaxLatitude)); farthest < 90 {
+		dLon := dLat / math.Cos(farthest*math.Pi/180)
+		box.MinLongitude = c.Longitude - dLon
+		box.MaxLongitude = c.Longitude + dLon
+	}
+
+	return box
+}
+
+// FindNear returns the features within maxDistanceKm of point, nearest
+// first, as measured by store.DistanceTo. A maxDistanceKm of 0 or less means
+// no limit
+func (s *Store) FindNear(ctx context.Context, point geojson.Point, maxDistanceKm float64, types ...string) (features.FeatureCollection, error) {
+	if err := geojson.Validate(point); err != nil {
+		return features.FeatureCollection{}, fmt.Errorf("%w: %w", store.ErrInvalidFilter, err)
+	}
+
+	center := geojson.Coordinate(point)
+	box := everywhere
+	if maxDistanceKm > 0 {
+		box = around(center, maxDistanceKm)
+	}
+
+	isType := store.OfTypes(types...)
+	distances := map[string]float64{}
+	matched, err := s.near(box, func(f features.Feature) bool {
+		if !isType(f) {
+			return false
+		}
+
+		d := store.DistanceTo(f.Geometry, center)
+		distances[f.ID] = d
+		return maxDistanceKm <= 0 || d <= maxDistanceKm
+	})
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		return distances[matched[i].ID] < distances[matched[j].ID]
+	})
+
+	return features.FeatureCollection{Features: matched}, err
+}
+
+// FindWithinBBox returns the features whose geometry lies entirely within
+// bbox, as store.WithinBBox decides. If types are given, only features of
+// those types are returned
+func (s *Store) FindWithinBBox(ctx context.Context, bbox geojson.BoundingBox, types ...string) (features.FeatureCollection, error) {
+	if err := store.ValidBBox(bbox); err != nil {
+		return features.FeatureCollection{}, err
+	}
+
+	isType := store.OfTypes(types...)
+	matched, err := s.near(bbox, func(f features.Feature) bool {
+		return isType(f) && store.WithinBBox(f.Geometry, bbox)
+	})
+
+	return features.FeatureCollection{Features: matched}, err
+}
+
+// GetAlertStats gathers statistics about the current and archived alerts a
+// query selects
+func (s *Store) GetAlertStats(ctx context.Context, q store.StatsQuery) (store.AlertStats, error) {
+	match, err := q.Predicate()
+	if err != nil {
+		return store.AlertStats{}, err
+	}
+
+	alerts, err := s.alerts(false, true)
+	if err != nil {
+		return store.AlertStats{}, err
+	}
+
+	return store.Tally(utils.Filter(alerts, match)), nil
+}
@@ -0,0 +1,293 @@
+package bolt
+
+import (
+	"math"
+
+	"github.com/jghiloni/watchedsky-social/backend/geojson"
+)
+
+const (
+	maxNodeEntries = 16
+	minNodeEntries = 6
+)
+
+// rtree indexes the bounding boxes of feature geometries, so spatial lookups
+// only decode the features that might match. It is Guttman's R-tree with the
+// quadratic split, and isn't safe for concurrent use
+type rtree struct {
+	root  *rnode
+	boxes map[string]geojson.BoundingBox
+}
+
+type rnode struct {
+	leaf    bool
+	entries []rentry
+}
+
+// rentry is a feature in a leaf node, or a child in an inner one
+type rentry struct {
+	box   geojson.BoundingBox
+	id    string
+	child *rnode
+}
+
+func newRTree() *rtree {
+	return &rtree{root: &rnode{leaf: true}, boxes: map[string]geojson.BoundingBox{}}
+}
+
+// everywhere is a box that every other box intersects
+var everywhere = geojson.BoundingBox{
+	MinLongitude: math.Inf(-1),
+	MinLatitude:  math.Inf(-1),
+	MaxLongitude: math.Inf(1),
+	MaxLatitude:  math.Inf(1),
+}
+
+func intersects(a geojson.BoundingBox, b geojson.BoundingBox) bool {
+	return a.MinLongitude <= b.MaxLongitude && b.MinLongitude <= a.MaxLongitude &&
+		a.MinLatitude <= b.MaxLatitude && b.MinLatitude <= a.MaxLatitude
+}
+
+func contains(outer geojson.BoundingBox, inner geojson.BoundingBox) bool {
+	return outer.MinLongitude <= inner.MinLongitude && inner.MaxLongitude <= outer.MaxLongitude &&
+		outer.MinLatitude <= inner.MinLatitude && inner.MaxLatitude <= outer.MaxLatitude
+}
+
+func union(a geojson.BoundingBox, b geojson.BoundingBox) geojson.BoundingBox {
+	return geojson.BoundingBox{
+		MinLongitude: math.Min(a.MinLongitude, b.MinLongitude),
+		MinLatitude:  math.Min(a.MinLatitude, b.MinLatitude),
+		MaxLongitude: math.Max(a.MaxLongitude, b.MaxLongitude),
+		MaxLatitude:  math.Max(a.MaxLatitude, b.MaxLatitude),
+	}
+}
+
+func area(b geojson.BoundingBox) float64 {
+	return (b.MaxLongitude - b.MinLongitude) * (b.MaxLatitude - b.MinLatitude)
+}
+
+func (n *rnode) bounds() geojson.BoundingBox {
+	box := n.entries[0].box
+	for _, e := range n.entries[1:] {
+		box = union(box, e.box)
+	}
+
+	return box
+}
+
+// Search calls fn with the id of every feature whose box intersects box
+func (t *rtree) Search(box geojson.BoundingBox, fn func(id string)) {
+	var search func(n *rnode)
+	search = func(n *rnode) {
+		for _, e := range n.entries {
+			if !intersects(e.box, box) {
+				continue
+			}
+
+			if n.leaf {
+				fn(e.id)
+			} else {
+				search(e.child)
+			}
+		}
+	}
+
+	search(t.root)
+}
+
+// Insert indexes a feature's box, replacing any box it already had
+func (t *rtree) Insert(id string, box geojson.BoundingBox) {
+	if _, ok := t.boxes[id]; ok {
+		t.Delete(id)
+	}
+
+	t.boxes[id] = box
+	t.insert(rentry{box: box, id: id})
+}
+
+func (t *rtree) insert(e rentry) {
+	if sibling := t.insertInto(t.root, e); sibling != nil {
+		t.root = &rnode{entries: []rentry{
+			{box: t.root.bounds(), child: t.root},
+			{box: sibling.bounds(), child: sibling},
+		}}
+	}
+}
+
+// insertInto adds a leaf entry under n. If n overflows, it is split, and the
+// new sibling is returned
+func (t *rtree) insertInto(n *rnode, e rentry) *rnode {
+	if n.leaf {
+		n.entries = append(n.entries, e)
+	} else {
+		i := chooseSubtree(n, e.box)
+		child := n.entries[i].child
+		sibling := t.insertInto(child, e)
+		n.entries[i].box = child.bounds()
+		if sibling != nil {
+			n.entries = append(n.entries, rentry{box: sibling.bounds(), child: sibling})
+		}
+	}
+
+	if len(n.entries) > maxNodeEntries {
+		return split(n)
+	}
+
+	return nil
+}
+
+// chooseSubtree picks the child that grows least to hold box, and then the
+// smallest
+func chooseSubtree(n *rnode, box geojson.BoundingBox) int {
+	best := 0
+	bestGrowth, bestArea := math.Inf(1), math.Inf(1)
+	for i, e := range n.entries {
+		a := area(e.box)
+		growth := area(union(e.box, box)) - a
+		if growth < bestGrowth || (growth == bestGrowth && a < bestArea) {
+			best, bestGrowth, bestArea = i, growth, a
+		}
+	}
+
+	return best
+}
+
+// split divides the entries of n between n and a new sibling, which it
+// returns. The two entries that would waste the most space together seed
+// the groups, and the rest go where they cause the least growth
+func split(n *rnode) *rnode {
+	entries := n.entries
+
+	seedA, seedB := 0, 1
+	worst := math.Inf(-1)
+	for i := range entries {
+		for j := i + 1; j < len(entries); j++ {
+			waste := area(union(entries[i].box, entries[j].box)) - area(entries[i].box) - area(entries[j].box)
+			if waste > worst {
+				seedA, seedB, worst = i, j, waste
+			}
+		}
+	}
+
+	a := &rnode{leaf: n.leaf, entries: []rentry{entries[seedA]}}
+	b := &rnode{leaf: n.leaf, entries: []rentry{entries[seedB]}}
+	boxA, boxB := entries[seedA].box, entries[seedB].box
+
+	remaining := make([]rentry, 0, len(entries)-2)
+	for i, e := range entries {
+		if i != seedA && i != seedB {
+			remaining = append(remaining, e)
+		}
+	}
+
+	for len(remaining) > 0 {
+		// a group that needs every remaining entry to reach the minimum
+		// gets them all
+		if len(a.entries)+len(remaining) <= minNodeEntries {
+			a.entries = append(a.entries, remaining...)
+			break
+		}
+
+		if len(b.entries)+len(remaining) <= minNodeEntries {
+			b.entries = append(b.entries, remaining...)
+			break
+		}
+
+		// the entry with the strongest preference for one group goes next
+		next, toA := 0, true
+		strongest := math.Inf(-1)
+		for i, e := range remaining {
+			growthA := area(union(boxA, e.box)) - area(boxA)
+			growthB := area(union(boxB, e.box)) - area(boxB)
+			if preference := math.Abs(growthA - growthB); preference > strongest {
+				next, strongest = i, preference
+				toA = growthA < growthB || (growthA == growthB && len(a.entries) <= len(b.entries))
+			}
+		}
+
+		e := remaining[next]
+		remaining = append(remaining[:next], remaining[next+1:]...)
+		if toA {
+			a.entries = append(a.entries, e)
+			boxA = union(boxA, e.box)
+		} else {
+			b.entries = append(b.entries, e)
+			boxB = union(boxB, e.box)
+		}
+	}
+
+	n.entries = a.entries
+	return b
+}
+
+// Delete removes a feature from the index, if it is there
+func (t *rtree) Delete(id string) {
+	box, ok := t.boxes[id]
+	if !ok {
+		return
+	}
+	delete(t.boxes, id)
+
+	_, orphans := t.deleteFrom(t.root, id, box)
+
+	if !t.root.leaf && len(t.root.entries) == 1 {
+		t.root = t.root.entries[0].child
+	} else if len(t.root.entries) == 0 {
+		t.root = &rnode{leaf: true}
+	}
+
+	for _, e := range orphans {
+		t.insert(e)
+	}
+}
+
+// deleteFrom removes a leaf entry from under n. Nodes that are left with too
+// few entries are removed too, and their leaf entries returned to be
+// inserted again
+func (t *rtree) deleteFrom(n *rnode, id string, box geojson.BoundingBox) (bool, []rentry) {
+	if n.leaf {
+		for i, e := range n.entries {
+			if e.id == id {
+				n.entries = append(n.entries[:i], n.entries[i+1:]...)
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}
+
+	for i, e := range n.entries {
+		if !contains(e.box, box) {
+			continue
+		}
+
+		found, orphans := t.deleteFrom(e.child, id, box)
+		if !found {
+			continue
+		}
+
+		if len(e.child.entries) < minNodeEntries {
+			orphans = append(orphans, leafEntries(e.child)...)
+			n.entries = append(n.entries[:i], n.entries[i+1:]...)
+		} else {
+			n.entries[i].box = e.child.bounds()
+		}
+
+		return true, orphans
+	}
+
+	return false, nil
+}
+
+func leafEntries(n *rnode) []rentry {
+	if n.leaf {
+		return n.entries
+	}
+
+	entries := []rentry{}
+	for _, e := range n.entries {
+		entries = append(entries, leafEntries(e.child)...)
+	}
+
+	return entries
+}
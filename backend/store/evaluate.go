@@ -0,0 +1,385 @@
+package store
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/geojson"
+)
+
+// The functions in this file evaluate queries in Go, for backends that can't
+// run them in the database. They match what the Mongo backend does as
+// closely as they can, and the differences are noted
+
+// Supersedes returns true if f should replace stored. A feature is only
+// replaced by a version sent later, but features without a sent time always
+// replace what is stored
+func Supersedes(f features.Feature, stored features.Feature) bool {
+	sent, ok := f.Time(features.SentField)
+	if !ok {
+		return true
+	}
+
+	storedSent, ok := stored.Time(features.SentField)
+	return !ok || storedSent.Before(sent)
+}
+
+// CompareValues orders property values the way Mongo does for the types
+// features hold: missing values first, then numbers, then strings
+func CompareValues(a any, b any) int {
+	if ra, rb := typeRank(a), typeRank(b); ra != rb {
+		return cmp.Compare(ra, rb)
+	}
+
+	switch va := a.(type) {
+	case nil:
+		return 0
+	case string:
+		return strings.Compare(va, b.(string))
+	}
+
+	if fa, ok := number(a); ok {
+		fb, _ := number(b)
+		return cmp.Compare(fa, fb)
+	}
+
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func typeRank(v any) int {
+	if v == nil {
+		return 0
+	}
+
+	if _, ok := number(v); ok {
+		return 1
+	}
+
+	if _, ok := v.(string); ok {
+		return 2
+	}
+
+	return 3
+}
+
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+
+	return 0, false
+}
+
+// compareKeys compares the sort values of two features in order, reversed
+// for backward cursors
+func compareKeys(order SortOrder, a []any, b []any, backward bool) int {
+	for i, k := range order.Keys() {
+		c := CompareValues(a[i], b[i])
+		if k.Descending != backward {
+			c = -c
+		}
+
+		if c != 0 {
+			return c
+		}
+	}
+
+	return 0
+}
+
+// PageOf returns a page of matched, which are every feature that matches a
+// filter, in any order. The page shares features with matched
+func PageOf(matched features.Features, pageInfo PageOptions) (FeaturePage, error) {
+	pageInfo = pageInfo.Normalized()
+
+	var position *Cursor
+	if pageInfo.Cursor != "" {
+		p, err := DecodeCursor(pageInfo.Cursor, pageInfo.Order)
+		if err != nil {
+			return FeaturePage{}, err
+		}
+		position = &p
+	}
+
+	backward := position != nil && position.Backward
+	values := make([][]any, len(matched))
+	sorted := make([]int, len(matched))
+	for i, f := range matched {
+		values[i] = pageInfo.Order.Values(f)
+		sorted[i] = i
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return compareKeys(pageInfo.Order, values[sorted[i]], values[sorted[j]], backward) < 0
+	})
+
+	start := 0
+	if position != nil {
+		start = sort.Search(len(sorted), func(i int) bool {
+			return compareKeys(pageInfo.Order, values[sorted[i]], position.Values, backward) > 0
+		})
+	} else {
+		start = min(int(pageInfo.PageSize*pageInfo.Page), len(sorted))
+	}
+
+	// one extra feature shows whether there is another page
+	end := min(len(sorted), start+int(pageInfo.PageSize)+1)
+	feats := make(features.Features, 0, end-start)
+	for _, i := range sorted[start:end] {
+		feats = append(feats, matched[i])
+	}
+
+	page := BuildPage(feats, pageInfo, position)
+	if pageInfo.WithTotal {
+		total := int64(len(matched))
+		page.Total = &total
+	}
+
+	return page, nil
+}
+
+// Rank returns a page of the features in matched that match a text search,
+// most relevant first. Words match the start of words, which stands in for
+// Mongo's stemming, and relevance is the number of matches weighted by
+// SearchWeights. The page shares features with matched
+func Rank(matched features.Features, q string, pageInfo PageOptions) (SearchPage, error) {
+	q = strings.TrimSpace(q)
+	search := ParseTextSearch(q)
+	if len(search.Terms()) == 0 {
+		return SearchPage{}, ErrEmptySearch
+	}
+
+	pageInfo = pageInfo.Normalized()
+
+	hits := []SearchHit{}
+	for _, f := range matched {
+		if score, ok := textScore(f, search); ok {
+			hits = append(hits, SearchHit{Feature: f, Score: score})
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+
+		return compareKeys(OrderNewest, OrderNewest.Values(hits[i].Feature), OrderNewest.Values(hits[j].Feature), false) < 0
+	})
+
+	total := int64(len(hits))
+	skip := min(int(pageInfo.PageSize*pageInfo.Page), len(hits))
+	hits = hits[skip:min(len(hits), skip+int(pageInfo.PageSize))]
+
+	if pattern := HighlightPattern(q); pattern != nil {
+		for i := range hits {
+			hits[i].Highlights = Highlights(hits[i].Feature, pattern)
+		}
+	}
+
+	page := SearchPage{
+		PageInfo: PageOptions{Page: pageInfo.Page, PageSize: uint(len(hits))},
+		Query:    q,
+		Hits:     hits,
+	}
+
+	if pageInfo.WithTotal {
+		page.Total = &total
+	}
+
+	return page, nil
+}
+
+// textScore scores a feature against a search. Like Mongo, a feature must
+// contain every phrase and none of the excluded terms, and if there are no
+// phrases, at least one of the words
+func textScore(f features.Feature, search TextSearch) (float64, bool) {
+	texts := map[string]string{}
+	for _, field := range SearchFields {
+		texts[field] = strings.Join(strings.Fields(f.Properties.StringValue(field)), " ")
+	}
+
+	found := func(pattern *regexp.Regexp) bool {
+		for _, text := range texts {
+			if pattern.MatchString(text) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if search.Excluded != nil && found(TermPattern(search.Excluded...)) {
+		return 0, false
+	}
+
+	for _, phrase := range search.Phrases {
+		if !found(TermPattern(phrase)) {
+			return 0, false
+		}
+	}
+
+	terms := TermPattern(search.Terms()...)
+	score := 0.0
+	for field, text := range texts {
+		score += float64(SearchWeights[field] * len(TermMatches(terms, text)))
+	}
+
+	return score, score > 0
+}
+
+// refersTo returns true if f is one of ids, or references one of them
+func refersTo(f features.Feature, ids map[string]bool) bool {
+	if ids[f.ID] || ids[f.Properties.StringValue("id")] {
+		return true
+	}
+
+	for _, ref := range f.References() {
+		if ids[ref] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AlertVersions finds every version of the alert with the given ID among
+// candidates, by following references and replacedBy links in both
+// directions
+func AlertVersions(id string, candidates features.Features) features.Features {
+	found := map[string]bool{}
+	queried := map[string]bool{}
+	frontier := []string{features.AlertIdentifier(id)}
+	versions := features.Features{}
+
+	for len(frontier) > 0 {
+		wanted := map[string]bool{}
+		for _, id := range frontier {
+			queried[id] = true
+			wanted[id] = true
+		}
+
+		next := []string{}
+		enqueue := func(id string) {
+			if id != "" && !queried[id] {
+				queried[id] = true
+				next = append(next, id)
+			}
+		}
+
+		for _, f := range candidates {
+			if found[f.AlertID()] || !refersTo(f, wanted) {
+				continue
+			}
+
+			found[f.AlertID()] = true
+			versions = append(versions, f)
+
+			enqueue(f.AlertID())
+			enqueue(f.ReplacedBy())
+			for _, ref := range f.References() {
+				enqueue(ref)
+			}
+		}
+
+		frontier = next
+	}
+
+	return versions
+}
+
+// PreviousVersion finds the most recent of candidates that f updates or
+// cancels. The second return value is false if there isn't one
+func PreviousVersion(f features.Feature, candidates features.Features) (features.Feature, bool) {
+	refs := map[string]bool{}
+	for _, ref := range f.References() {
+		refs[ref] = true
+	}
+
+	var previous features.Feature
+	var previousSent time.Time
+	found := false
+	for _, candidate := range candidates {
+		if !refs[candidate.ID] && !refs[candidate.Properties.StringValue("id")] {
+			continue
+		}
+
+		// sent times carry their own offsets, so they are compared as times
+		// rather than as strings
+		sent, _ := candidate.Time(features.SentField)
+		if !found || sent.After(previousSent) {
+			previous, previousSent, found = candidate, sent, true
+		}
+	}
+
+	return previous, found
+}
+
+// OfTypes matches features of any of the given types, or every feature if
+// there are none
+func OfTypes(types ...string) func(features.Feature) bool {
+	return func(f features.Feature) bool {
+		if len(types) == 0 {
+			return true
+		}
+
+		for _, t := range types {
+			if f.Type() == t {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// DistanceTo is how far c is from g in kilometers. It is the distance to the
+// nearest vertex of g, or 0 if g covers c, so it can be a little further
+// than Mongo would measure to the nearest edge
+func DistanceTo(g geojson.Geometry, c geojson.Coordinate) float64 {
+	if geojson.Covers(g, c) {
+		return 0
+	}
+
+	nearest := math.Inf(1)
+	for _, v := range geojson.Vertices(g) {
+		nearest = math.Min(nearest, geojson.Distance(v, c))
+	}
+
+	return nearest
+}
+
+// WithinBBox returns true if g lies entirely within bbox. Unlike in Mongo,
+// the edges of the box follow lines of latitude and longitude
+func WithinBBox(g geojson.Geometry, bbox geojson.BoundingBox) bool {
+	bounds, ok := geojson.Bounds(g)
+	return ok && bounds.MinLongitude >= bbox.MinLongitude && bounds.MaxLongitude <= bbox.MaxLongitude &&
+		bounds.MinLatitude >= bbox.MinLatitude && bounds.MaxLatitude <= bbox.MaxLatitude
+}
+
+// ValidBBox checks that a bounding box can be queried
+func ValidBBox(bbox geojson.BoundingBox) error {
+	if bbox.Empty() {
+		return fmt.Errorf("%w: bounding box is empty", ErrInvalidFilter)
+	}
+
+	if err := geojson.Validate(bbox.Polygon()); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidFilter, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jghiloni/watchedsky-social/backend/cql2"
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/utils"
+)
+
+// ErrInvalidFilter is returned when a FeatureFilter can't be turned into a
+// query
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// FeatureFilter narrows down a feature listing. Empty fields match everything
+type FeatureFilter struct {
+	Type string
+
+	// EventCodes are EAS event codes, e.g. TOR
+	EventCodes []string
+
+	// FIPS are 5 digit county FIPS codes. Alerts covering the whole state
+	// that the county is in are matched as well
+	FIPS []string
+
+	// States are state postal abbreviations
+	States []string
+
+	// HazardTags match alerts with any of the given hazards
+	HazardTags []features.HazardTag
+
+	// MinWindGustMPH and MinHailSizeInches match alerts with at least the
+	// given wind gusts or hail size
+	MinWindGustMPH    float64
+	MinHailSizeInches float64
+
+	// Posted only matches alerts that have been announced on Bluesky
+	Posted bool
+
+	// Expression is a parsed CQL2 filter
+	Expression cql2.Expr
+
+	// ActiveAt only matches alerts that are in force at the given time, as
+	// determined by features.Feature.IsActiveAt. It is ignored if zero
+	ActiveAt time.Time
+
+	// Archived matches alerts that have been moved to the archive instead of
+	// current features
+	Archived bool
+}
+
+var fipsPattern = regexp.MustCompile(`^[0-9]{5}$`)
+
+// SAMEPatterns are the regular expressions that an alert's SAME geocodes are
+// matched against for the FIPS and States fields. An alert must have a code
+// that matches each pattern. SAME codes are a subdivision digit followed by
+// the 5 digit FIPS code
+func (f FeatureFilter) SAMEPatterns() ([]string, error) {
+	patterns := []string{}
+	if len(f.FIPS) > 0 {
+		alternatives := make([]string, 0, 2*len(f.FIPS))
+		for _, fips := range f.FIPS {
+			if !fipsPattern.MatchString(fips) {
+				return nil, fmt.Errorf("%w: invalid FIPS code %q", ErrInvalidFilter, fips)
+			}
+
+			alternatives = append(alternatives, fips, fips[:2]+"000")
+		}
+
+		patterns = append(patterns, fmt.Sprintf("^[0-9](%s)$", strings.Join(alternatives, "|")))
+	}
+
+	if len(f.States) > 0 {
+		stateFIPS := make([]string, 0, len(f.States))
+		for _, state := range f.States {
+			fips, ok := features.StateFIPS(state)
+			if !ok {
+				return nil, fmt.Errorf("%w: unknown state %q", ErrInvalidFilter, state)
+			}
+
+			stateFIPS = append(stateFIPS, fips)
+		}
+
+		patterns = append(patterns, fmt.Sprintf("^[0-9](%s)[0-9]{3}$", strings.Join(stateFIPS, "|")))
+	}
+
+	return patterns, nil
+}
+
+// Predicate compiles the filter to a function that reports whether a
+// feature matches it, with the same semantics as the Mongo query
+func (f FeatureFilter) Predicate() (func(features.Feature) bool, error) {
+	patterns, err := f.SAMEPatterns()
+	if err != nil {
+		return nil, err
+	}
+
+	sameMatchers := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		sameMatchers[i] = regexp.MustCompile(p)
+	}
+
+	codes := utils.Map(f.EventCodes, strings.ToUpper)
+
+	return func(feat features.Feature) bool {
+		if f.Type != "" && feat.Type() != f.Type {
+			return false
+		}
+
+		if len(codes) > 0 && !anyIn([]string{feat.EASEventCode()}, codes) {
+			return false
+		}
+
+		if len(f.HazardTags) > 0 || f.MinWindGustMPH > 0 || f.MinHailSizeInches > 0 {
+			h, ok := StoredHazards(feat)
+			if !ok || (len(f.HazardTags) > 0 && !anyIn(h.Tags, f.HazardTags)) {
+				return false
+			}
+
+			if (f.MinWindGustMPH > 0 && h.MaxWindGustMPH < f.MinWindGustMPH) ||
+				(f.MinHailSizeInches > 0 && h.MaxHailSizeInches < f.MinHailSizeInches) {
+				return false
+			}
+		}
+
+		if _, posted := feat.Properties["postUri"]; f.Posted && !posted {
+			return false
+		}
+
+		for _, m := range sameMatchers {
+			if len(utils.Filter(feat.SAMECodes(), m.MatchString)) == 0 {
+				return false
+			}
+		}
+
+		if f.Expression != nil && !f.Expression.Match(feat) {
+			return false
+		}
+
+		return f.ActiveAt.IsZero() || feat.IsActiveAt(f.ActiveAt)
+	}, nil
+}
+
+// StoredHazards returns the hazards stored with a feature, which are what
+// hazard filters match, rather than hazards parsed from its text
+func StoredHazards(f features.Feature) (features.Hazards, bool) {
+	switch h := f.Properties["hazards"].(type) {
+	case nil:
+		return features.Hazards{}, false
+	case features.Hazards:
+		return h, true
+	default:
+		var hazards features.Hazards
+		raw, err := json.Marshal(h)
+		if err != nil || json.Unmarshal(raw, &hazards) != nil {
+			return features.Hazards{}, false
+		}
+
+		return hazards, true
+	}
+}
+
+func anyIn[T comparable](values []T, wanted []T) bool {
+	for _, v := range values {
+		for _, w := range wanted {
+			if v == w {
+				return true
+			}
+		}
+	}
+
+	return false
+}
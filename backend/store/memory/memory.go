@@ -0,0 +1,245 @@
+// Package memory is a FeatureStore that keeps everything in memory. It has
+// the same semantics as the Mongo store, so it can stand in for it in tests
+// and in development, but nothing survives a restart
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jghiloni/watchedsky-social/backend/config"
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/store"
+)
+
+// BackendName selects the memory store in config
+const BackendName = "memory"
+
+// Store is an in-memory FeatureStore. The zero value isn't usable; use New
+type Store struct {
+	mu         sync.RWMutex
+	features   map[string]features.Feature
+	archive    map[string]features.Feature
+	quarantine []store.QuarantinedFeature
+	retention  map[string]time.Duration
+	notifier   store.Notifier
+}
+
+var _ store.FeatureStore = (*Store)(nil)
+
+// New returns an empty store
+func New() *Store {
+	return &Store{
+		features:  map[string]features.Feature{},
+		archive:   map[string]features.Feature{},
+		retention: map[string]time.Duration{},
+	}
+}
+
+func init() {
+	store.RegisterBackend(BackendName, func(context.Context, config.AppConfig) (store.FeatureStore, error) {
+		return New(), nil
+	})
+}
+
+// clone copies a feature through JSON, so that the store and its callers
+// never share maps, and stored features look the same as ones read back from
+// a database
+func clone(f features.Feature) (features.Feature, error) {
+	raw, err := json.Marshal(f)
+	if err != nil {
+		return features.Feature{}, err
+	}
+
+	var c features.Feature
+	if err = json.Unmarshal(raw, &c); err != nil {
+		return features.Feature{}, err
+	}
+
+	return c, nil
+}
+
+// cloneAll copies features that are already stored, which always succeeds
+func cloneAll(feats features.Features) features.Features {
+	copies := make(features.Features, len(feats))
+	for i, f := range feats {
+		copies[i], _ = clone(f)
+	}
+
+	return copies
+}
+
+// collection is the map a filter searches
+func (s *Store) collection(filter store.FeatureFilter) map[string]features.Feature {
+	if filter.Archived {
+		return s.archive
+	}
+
+	return s.features
+}
+
+// AddFeatures upserts features. A stored feature is only replaced by a newer
+// version, as decided by the sent time; writing the same or an older version
+// is skipped. Features without a sent time always replace what is stored.
+// Unlike in Mongo, geometries are stored as they are
+func (s *Store) AddFeatures(ctx context.Context, feats ...features.Feature) (store.WriteResult, error) {
+	result := store.WriteResult{Outcomes: make([]store.WriteOutcome, len(feats))}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, f := range feats {
+		result.Outcomes[i].ID = f.ID
+
+		c, err := clone(f)
+		if err != nil {
+			result.Record(i, store.WriteFailed, err)
+			continue
+		}
+
+		stored, exists := s.features[f.ID]
+		if exists && !store.Supersedes(c, stored) {
+			result.Record(i, store.WriteSkipped, nil)
+			continue
+		}
+
+		s.features[f.ID] = c
+		if exists {
+			result.Record(i, store.WriteUpdated, nil)
+			s.publish(store.ChangeUpdate, c)
+		} else {
+			result.Record(i, store.WriteInserted, nil)
+			s.publish(store.ChangeInsert, c)
+		}
+	}
+
+	return result, result.Err()
+}
+
+// SetPostURI records the AT URI of the post that announced a feature
+func (s *Store) SetPostURI(ctx context.Context, id string, uri string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.features[id]
+	if !ok {
+		return nil
+	}
+
+	f.Properties["postUri"] = uri
+	s.publish(store.ChangeUpdate, f)
+	return nil
+}
+
+// QuarantineFeature keeps a feature that failed validation
+func (s *Store) QuarantineFeature(ctx context.Context, f features.Feature, source string, reason error) error {
+	c, err := clone(f)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.quarantine = append(s.quarantine, store.Quarantined(c, source, reason))
+	return nil
+}
+
+// Quarantined returns the features that have been quarantined, oldest first
+func (s *Store) Quarantined() []store.QuarantinedFeature {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]store.QuarantinedFeature{}, s.quarantine...)
+}
+
+// GetFeaturesByID returns the current features with the given IDs, in the
+// order asked for. IDs that aren't stored are left out
+func (s *Store) GetFeaturesByID(ctx context.Context, ids ...string) (features.FeatureCollection, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	feats := make(features.Features, 0, len(ids))
+	for _, id := range ids {
+		if f, ok := s.features[id]; ok {
+			feats = append(feats, f)
+		}
+	}
+
+	return features.FeatureCollection{Features: cloneAll(feats)}, nil
+}
+
+// GetAffectedZones returns the zones an alert affects
+func (s *Store) GetAffectedZones(ctx context.Context, alert features.Feature) (features.Features, error) {
+	fc, err := s.GetFeaturesByID(ctx, alert.AffectedZones()...)
+	return fc.Features, err
+}
+
+// ArchiveEndedAlerts moves the alerts that ended before t to the archive,
+// and drops archived features that are past their retention window
+func (s *Store) ArchiveEndedAlerts(ctx context.Context, t time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	archivedAt := time.Now().UTC()
+	moved := 0
+	for id, f := range s.features {
+		if f.Type() != features.Alert {
+			continue
+		}
+
+		if ends, ok := f.EndsAt(); !ok || !ends.Before(t) {
+			continue
+		}
+
+		delete(s.features, id)
+		f.Properties[store.ArchivedAtField] = archivedAt.Format(time.RFC3339Nano)
+		s.archive[id] = f
+		moved++
+		s.publish(store.ChangeDelete, f)
+	}
+
+	s.expire(archivedAt)
+	return moved, nil
+}
+
+// ApplyRetention sets how long archived features of each type are kept
+// after they are archived. Types without a window are kept forever
+func (s *Store) ApplyRetention(ctx context.Context, windows map[string]time.Duration) error {
+	retention := map[string]time.Duration{}
+	for featureType, window := range windows {
+		if _, ok := features.Types.Lookup(featureType); !ok {
+			return fmt.Errorf("retention window for %w %q", features.ErrUnknownType, featureType)
+		}
+
+		if window <= 0 {
+			return fmt.Errorf("retention window for %s must be positive", featureType)
+		}
+		retention[featureType] = window
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.retention = retention
+	s.expire(time.Now())
+	return nil
+}
+
+// expire drops archived features that are past their retention window as
+// of now. The caller must hold the write lock
+func (s *Store) expire(now time.Time) {
+	for id, f := range s.archive {
+		window, ok := s.retention[f.Type()]
+		if !ok {
+			continue
+		}
+
+		if archivedAt, ok := f.Properties.TimeValue(store.ArchivedAtField); ok && archivedAt.Add(window).Before(now) {
+			delete(s.archive, id)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package memory_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/geojson"
+	"github.com/jghiloni/watchedsky-social/backend/store"
+	"github.com/jghiloni/watchedsky-social/backend/store/memory"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Memory store", func() {
+	var (
+		ctx context.Context
+		s   *memory.Store
+	)
+
+	alert := func(id string, sent string, props features.JSONObject) features.Feature {
+		p := features.JSONObject{
+			"@type":       features.Alert,
+			"id":          id,
+			"messageType": "Alert",
+			"sent":        sent,
+			"expires":     "2024-06-05T23:30:00Z",
+		}
+		for k, v := range props {
+			p[k] = v
+		}
+
+		return features.Feature{ID: id, Properties: p}
+	}
+
+	square := func(lon float64, lat float64) geojson.Polygon {
+		return geojson.Polygon{{
+			{Longitude: lon, Latitude: lat},
+			{Longitude: lon + 1, Latitude: lat},
+			{Longitude: lon + 1, Latitude: lat + 1},
+			{Longitude: lon, Latitude: lat + 1},
+			{Longitude: lon, Latitude: lat},
+		}}
+	}
+
+	ids := func(feats features.Features) []string {
+		out := []string{}
+		for _, f := range feats {
+			out = append(out, f.ID)
+		}
+		return out
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		s = memory.New()
+	})
+
+	It("Only replaces features with newer versions", func() {
+		res, err := s.AddFeatures(ctx, alert("a", "2024-06-05T21:00:00Z", nil))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Outcomes[0].Status).To(Equal(store.WriteInserted))
+
+		res, err = s.AddFeatures(ctx,
+			alert("a", "2024-06-05T20:00:00Z", features.JSONObject{"headline": "old"}),
+		)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Outcomes[0].Status).To(Equal(store.WriteSkipped))
+
+		res, err = s.AddFeatures(ctx,
+			alert("a", "2024-06-05T22:00:00Z", features.JSONObject{"headline": "new"}),
+		)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Outcomes[0].Status).To(Equal(store.WriteUpdated))
+
+		fc, err := s.GetFeaturesByID(ctx, "a", "missing")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fc.Features).To(HaveLen(1))
+		Expect(fc.Features[0].Properties.StringValue("headline")).To(Equal("new"))
+	})
+
+	It("Doesn't share features with callers", func() {
+		f := alert("a", "2024-06-05T21:00:00Z", nil)
+		_, err := s.AddFeatures(ctx, f)
+		Expect(err).NotTo(HaveOccurred())
+
+		f.Properties["headline"] = "changed"
+		fc, err := s.GetFeaturesByID(ctx, "a")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fc.Features[0].Properties).NotTo(HaveKey("headline"))
+	})
+
+	It("Pages through features with cursors in both directions", func() {
+		for i := 0; i < 5; i++ {
+			_, err := s.AddFeatures(ctx, alert(fmt.Sprintf("a%d", i), fmt.Sprintf("2024-06-05T2%d:00:00Z", i), nil))
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		first, err := s.ListFeatures(ctx, store.FeatureFilter{}, store.PageOptions{PageSize: 2, WithTotal: true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(first.Features)).To(Equal([]string{"a4", "a3"}))
+		Expect(*first.Total).To(BeEquivalentTo(5))
+		Expect(first.Prev).To(BeEmpty())
+
+		second, err := s.ListFeatures(ctx, store.FeatureFilter{}, store.PageOptions{PageSize: 2, Cursor: first.Next})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(second.Features)).To(Equal([]string{"a2", "a1"}))
+
+		third, err := s.ListFeatures(ctx, store.FeatureFilter{}, store.PageOptions{PageSize: 2, Cursor: second.Next})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(third.Features)).To(Equal([]string{"a0"}))
+		Expect(third.Next).To(BeEmpty())
+
+		back, err := s.ListFeatures(ctx, store.FeatureFilter{}, store.PageOptions{PageSize: 2, Cursor: third.Prev})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(back.Features)).To(Equal([]string{"a2", "a1"}))
+
+		_, err = s.ListFeatures(ctx, store.FeatureFilter{}, store.PageOptions{Order: store.OrderPriority, Cursor: first.Next})
+		Expect(err).To(MatchError(store.ErrInvalidCursor))
+	})
+
+	It("Filters features", func() {
+		_, err := s.AddFeatures(ctx,
+			alert("tor", "2024-06-05T21:00:00Z", features.JSONObject{
+				"parameters": map[string]any{"EAS-ORG": []any{"WXR"}},
+				"eventCode":  map[string]any{"SAME": []any{"TOW"}},
+				"geocode":    map[string]any{"SAME": []any{"029095"}},
+			}),
+			alert("svr", "2024-06-05T21:00:00Z", features.JSONObject{
+				"eventCode": map[string]any{"SAME": []any{"SVR"}},
+				"geocode":   map[string]any{"SAME": []any{"020091"}},
+			}),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		page, err := s.ListFeatures(ctx, store.FeatureFilter{States: []string{"KS"}}, store.PageOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(page.Features)).To(Equal([]string{"svr"}))
+
+		page, err = s.ListFeatures(ctx, store.FeatureFilter{FIPS: []string{"29095"}}, store.PageOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(page.Features)).To(Equal([]string{"tor"}))
+
+		_, err = s.ListFeatures(ctx, store.FeatureFilter{FIPS: []string{"290"}}, store.PageOptions{})
+		Expect(err).To(MatchError(store.ErrInvalidFilter))
+	})
+
+	It("Searches alert text by relevance", func() {
+		_, err := s.AddFeatures(ctx,
+			alert("a", "2024-06-05T21:00:00Z", features.JSONObject{
+				"event":       "Tornado Warning",
+				"description": "A tornado was reported near Springfield.",
+			}),
+			alert("b", "2024-06-05T22:00:00Z", features.JSONObject{
+				"event":       "Severe Thunderstorm Warning",
+				"description": "A tornado is possible.",
+			}),
+			alert("c", "2024-06-05T22:00:00Z", features.JSONObject{
+				"event": "Flood Warning",
+			}),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		page, err := s.SearchFeatures(ctx, "tornado", store.FeatureFilter{}, store.PageOptions{PageSize: 10, WithTotal: true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(page.Features())).To(Equal([]string{"a", "b"}))
+		Expect(*page.Total).To(BeEquivalentTo(2))
+		Expect(page.Hits[0].Highlights["event"]).To(ContainElement("<mark>Tornado</mark> Warning"))
+
+		page, err = s.SearchFeatures(ctx, "warning -tornado", store.FeatureFilter{}, store.PageOptions{PageSize: 10})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(page.Features())).To(Equal([]string{"c"}))
+
+		_, err = s.SearchFeatures(ctx, "  ", store.FeatureFilter{}, store.PageOptions{})
+		Expect(err).To(MatchError(store.ErrEmptySearch))
+	})
+
+	It("Finds features by geometry", func() {
+		near := alert("near", "2024-06-05T21:00:00Z", nil)
+		near.Geometry = square(-95, 39)
+		far := alert("far", "2024-06-05T21:00:00Z", nil)
+		far.Geometry = square(-80, 30)
+
+		_, err := s.AddFeatures(ctx, near, far)
+		Expect(err).NotTo(HaveOccurred())
+
+		fc, err := s.FindIntersecting(ctx, geojson.Point{Longitude: -94.5, Latitude: 39.5})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(fc.Features)).To(Equal([]string{"near"}))
+
+		fc, err = s.FindNear(ctx, geojson.Point{Longitude: -96, Latitude: 39.5}, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(fc.Features)).To(Equal([]string{"near", "far"}))
+
+		fc, err = s.FindNear(ctx, geojson.Point{Longitude: -96, Latitude: 39.5}, 200)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(fc.Features)).To(Equal([]string{"near"}))
+
+		fc, err = s.FindWithinBBox(ctx, geojson.BoundingBox{MinLongitude: -100, MinLatitude: 35, MaxLongitude: -90, MaxLatitude: 45})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(fc.Features)).To(Equal([]string{"near"}))
+	})
+
+	It("Archives ended alerts", func() {
+		_, err := s.AddFeatures(ctx,
+			alert("ended", "2024-06-05T21:00:00Z", nil),
+			alert("current", "2024-06-05T21:00:00Z", features.JSONObject{"expires": "2099-01-01T00:00:00Z"}),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		moved, err := s.ArchiveEndedAlerts(ctx, time.Now())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(moved).To(Equal(1))
+
+		page, err := s.ListFeatures(ctx, store.FeatureFilter{Archived: true}, store.PageOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(page.Features)).To(Equal([]string{"ended"}))
+
+		versions, err := s.GetAlertVersions(ctx, "ended")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(versions)).To(Equal([]string{"ended"}))
+
+		previous, found, err := s.GetPreviousVersion(ctx, alert("update", "2024-06-05T22:00:00Z", features.JSONObject{"references": []any{"ended"}}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeTrue())
+		Expect(previous.ID).To(Equal("ended"))
+	})
+
+	It("Finds the previous version by when it was sent, not how the time is written", func() {
+		_, err := s.AddFeatures(ctx,
+			alert("central", "2024-06-05T21:00:00-05:00", nil),
+			alert("utc", "2024-06-05T23:00:00Z", nil),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		previous, found, err := s.GetPreviousVersion(ctx, alert("update", "2024-06-06T03:00:00Z", features.JSONObject{"references": []any{"central", "utc"}}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeTrue())
+		Expect(previous.ID).To(Equal("central"))
+	})
+
+	It("Sends changes to watchers whose filter matches", func() {
+		watchCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		events, err := s.WatchFeatures(watchCtx, store.FeatureFilter{Type: features.Alert}, store.WatchOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = s.AddFeatures(ctx,
+			features.Feature{ID: "zone", Properties: features.JSONObject{"@type": "wx:Zone"}},
+			alert("a", "2024-06-05T21:00:00Z", nil),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		var event store.FeatureEvent
+		Eventually(events).Should(Receive(&event))
+		Expect(event.Type).To(Equal(store.ChangeInsert))
+		Expect(event.ID).To(Equal("a"))
+
+		_, err = s.ArchiveEndedAlerts(ctx, time.Now())
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(events).Should(Receive(&event))
+		Expect(event.Type).To(Equal(store.ChangeDelete))
+		Expect(event.Feature).To(BeNil())
+
+		cancel()
+		Eventually(events).Should(BeClosed())
+	})
+
+	It("Gathers statistics about new alerts, archived or not", func() {
+		_, err := s.AddFeatures(ctx,
+			alert("a", "2024-06-05T16:00:00-05:00", features.JSONObject{
+				"event":      "Tornado Warning",
+				"severity":   "Extreme",
+				"senderName": "NWS Topeka KS",
+				"effective":  "2024-06-05T21:00:00Z",
+				"expires":    "2024-06-05T21:30:00Z",
+				"geocode":    map[string]any{"SAME": []any{"020177", "020045", "029095"}},
+			}),
+			alert("b", "2024-06-06T12:00:00Z", features.JSONObject{
+				"event":      "Tornado Warning",
+				"severity":   "Extreme",
+				"senderName": "NWS Kansas City/Pleasant Hill MO",
+				"expires":    "2099-06-06T13:00:00Z",
+				"ends":       "2024-06-06T13:00:00Z",
+				"geocode":    map[string]any{"SAME": []any{"029095"}},
+			}),
+			alert("c", "2024-06-06T13:00:00Z", features.JSONObject{
+				"event":       "Tornado Warning",
+				"messageType": "Update",
+			}),
+			alert("d", "2025-01-01T00:00:00Z", features.JSONObject{
+				"event":   "Winter Storm Warning",
+				"expires": "2099-01-01T00:00:00Z",
+			}),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		moved, err := s.ArchiveEndedAlerts(ctx, time.Now())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(moved).To(Equal(3))
+
+		stats, err := s.GetAlertStats(ctx, store.StatsQuery{
+			From: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+			To:   time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stats.Total).To(BeEquivalentTo(2))
+		Expect(stats.ByEvent).To(Equal([]store.Count{{Key: "Tornado Warning", Count: 2}}))
+		Expect(stats.ByState).To(Equal([]store.Count{{Key: "MO", Count: 2}, {Key: "KS", Count: 1}}))
+		Expect(stats.ByDay).To(Equal([]store.Count{{Key: "2024-06-05", Count: 1}, {Key: "2024-06-06", Count: 1}}))
+		Expect(*stats.MedianDurationSeconds).To(BeNumerically("==", 45*60))
+		Expect(*stats.FirstSent).To(BeTemporally("==", time.Date(2024, 6, 5, 21, 0, 0, 0, time.UTC)))
+		Expect(*stats.LastSent).To(BeTemporally("==", time.Date(2024, 6, 6, 12, 0, 0, 0, time.UTC)))
+
+		stats, err = s.GetAlertStats(ctx, store.StatsQuery{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stats.Total).To(BeEquivalentTo(3))
+
+		stats, err = s.GetAlertStats(ctx, store.StatsQuery{Filter: store.FeatureFilter{States: []string{"KS"}}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stats.BySender).To(Equal([]store.Count{{Key: "NWS Topeka KS", Count: 1}}))
+	})
+})
@@ -0,0 +1,174 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/geojson"
+	"github.com/jghiloni/watchedsky-social/backend/store"
+)
+
+// matching returns the features in a collection that match, in no
+// particular order. The caller must hold the read lock
+func matching(collection map[string]features.Feature, match func(features.Feature) bool) features.Features {
+	matched := features.Features{}
+	for _, f := range collection {
+		if match(f) {
+			matched = append(matched, f)
+		}
+	}
+
+	return matched
+}
+
+// ListFeatures returns a page of the features that match filter
+func (s *Store) ListFeatures(ctx context.Context, filter store.FeatureFilter, pageInfo store.PageOptions) (store.FeaturePage, error) {
+	match, err := filter.Predicate()
+	if err != nil {
+		return store.FeaturePage{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	page, err := store.PageOf(matching(s.collection(filter), match), pageInfo)
+	page.Features = cloneAll(page.Features)
+	return page, err
+}
+
+// SearchFeatures finds the features that match both a text search and
+// filter, most relevant first, as store.Rank decides
+func (s *Store) SearchFeatures(ctx context.Context, q string, filter store.FeatureFilter, pageInfo store.PageOptions) (store.SearchPage, error) {
+	match, err := filter.Predicate()
+	if err != nil {
+		return store.SearchPage{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	page, err := store.Rank(matching(s.collection(filter), match), q, pageInfo)
+	for i := range page.Hits {
+		page.Hits[i].Feature, _ = clone(page.Hits[i].Feature)
+	}
+
+	return page, err
+}
+
+func all(features.Feature) bool {
+	return true
+}
+
+// GetAlertVersions returns every stored version of the alert with the given
+// ID, found by following references and replacedBy links in both directions.
+// Archived versions are included
+func (s *Store) GetAlertVersions(ctx context.Context, id string) (features.Features, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	candidates := append(matching(s.features, all), matching(s.archive, all)...)
+	return cloneAll(store.AlertVersions(id, candidates)), nil
+}
+
+// GetPreviousVersion returns the most recent stored alert that f updates or
+// cancels, current or archived. The second return value is false if there
+// isn't one
+func (s *Store) GetPreviousVersion(ctx context.Context, f features.Feature) (features.Feature, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	candidates := append(matching(s.features, all), matching(s.archive, all)...)
+	previous, found := store.PreviousVersion(f, candidates)
+	if !found {
+		return features.Feature{}, false, nil
+	}
+
+	previous, _ = clone(previous)
+	return previous, true, nil
+}
+
+// FindIntersecting returns the features whose geometry intersects g. If
+// types are given, only features of those types are returned
+func (s *Store) FindIntersecting(ctx context.Context, g geojson.Geometry, types ...string) (features.FeatureCollection, error) {
+	if err := geojson.Validate(g); err != nil {
+		return features.FeatureCollection{}, fmt.Errorf("%w: %w", store.ErrInvalidFilter, err)
+	}
+
+	isType := store.OfTypes(types...)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matched := matching(s.features, func(f features.Feature) bool {
+		return isType(f) && f.Geometry != nil && geojson.Intersects(f.Geometry, g)
+	})
+
+	return features.FeatureCollection{Features: cloneAll(matched)}, nil
+}
+
+// FindNear returns the features within maxDistanceKm of point, nearest
+// first, as measured by store.DistanceTo. A maxDistanceKm of 0 or less means
+// no limit
+func (s *Store) FindNear(ctx context.Context, point geojson.Point, maxDistanceKm float64, types ...string) (features.FeatureCollection, error) {
+	if err := geojson.Validate(point); err != nil {
+		return features.FeatureCollection{}, fmt.Errorf("%w: %w", store.ErrInvalidFilter, err)
+	}
+
+	isType := store.OfTypes(types...)
+	distances := map[string]float64{}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matched := matching(s.features, func(f features.Feature) bool {
+		if !isType(f) || f.Geometry == nil {
+			return false
+		}
+
+		d := store.DistanceTo(f.Geometry, geojson.Coordinate(point))
+		distances[f.ID] = d
+		return maxDistanceKm <= 0 || d <= maxDistanceKm
+	})
+
+	sort.Slice(matched, func(i, j int) bool {
+		return distances[matched[i].ID] < distances[matched[j].ID]
+	})
+
+	return features.FeatureCollection{Features: cloneAll(matched)}, nil
+}
+
+// FindWithinBBox returns the features whose geometry lies entirely within
+// bbox, as store.WithinBBox decides. If types are given, only features of
+// those types are returned
+func (s *Store) FindWithinBBox(ctx context.Context, bbox geojson.BoundingBox, types ...string) (features.FeatureCollection, error) {
+	if err := store.ValidBBox(bbox); err != nil {
+		return features.FeatureCollection{}, err
+	}
+
+	isType := store.OfTypes(types...)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matched := matching(s.features, func(f features.Feature) bool {
+		return isType(f) && f.Geometry != nil && store.WithinBBox(f.Geometry, bbox)
+	})
+
+	return features.FeatureCollection{Features: cloneAll(matched)}, nil
+}
+
+// GetAlertStats gathers statistics about the current and archived alerts a
+// query selects
+func (s *Store) GetAlertStats(ctx context.Context, q store.StatsQuery) (store.AlertStats, error) {
+	match, err := q.Predicate()
+	if err != nil {
+		return store.AlertStats{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return store.Tally(append(matching(s.features, match), matching(s.archive, match)...)), nil
+}
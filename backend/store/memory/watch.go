@@ -0,0 +1,22 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/store"
+)
+
+// publish tells watchers about a change to a stored feature, with a copy
+// they can keep. The caller must hold the write lock
+func (s *Store) publish(changeType store.ChangeType, f features.Feature) {
+	c, _ := clone(f)
+	s.notifier.Publish(changeType, c)
+}
+
+// WatchFeatures sends the changes to current features that match filter
+// until ctx is done, when the channel is closed. Changes are only seen from
+// when the watch starts, so the options are ignored
+func (s *Store) WatchFeatures(ctx context.Context, filter store.FeatureFilter, opts store.WatchOptions) (<-chan store.FeatureEvent, error) {
+	return s.notifier.Watch(ctx, filter)
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+)
+
+// Notifier implements WatchFeatures for backends that see every write
+// themselves. Its zero value is ready to use
+type Notifier struct {
+	mu       sync.Mutex
+	watchers map[*watcher]bool
+}
+
+// watcher queues the events for one Watch call, so that a slow reader never
+// blocks writes to the store
+type watcher struct {
+	match func(features.Feature) bool
+
+	mu    sync.Mutex
+	queue []FeatureEvent
+	wake  chan struct{}
+}
+
+func (w *watcher) push(event FeatureEvent) {
+	w.mu.Lock()
+	w.queue = append(w.queue, event)
+	w.mu.Unlock()
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (w *watcher) pop() ([]FeatureEvent, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	events := w.queue
+	w.queue = nil
+	return events, len(events) > 0
+}
+
+// run sends queued events to out until ctx is done
+func (w *watcher) run(ctx context.Context, out chan<- FeatureEvent) {
+	for {
+		events, ok := w.pop()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-w.wake:
+				continue
+			}
+		}
+
+		for _, event := range events {
+			select {
+			case <-ctx.Done():
+				return
+			case out <- event:
+			}
+		}
+	}
+}
+
+// Publish tells watchers about a change. Inserts and updates only go to
+// watchers whose filter matches the feature; like in Mongo, deletes go to
+// everyone. f must not be changed afterward, since watchers share it
+func (n *Notifier) Publish(changeType ChangeType, f features.Feature) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for w := range n.watchers {
+		event := FeatureEvent{Type: changeType, ID: f.ID}
+		if changeType != ChangeDelete {
+			if !w.match(f) {
+				continue
+			}
+			event.Feature = &f
+		}
+
+		w.push(event)
+	}
+}
+
+// Watch sends the published changes to features that match filter until
+// ctx is done, when the channel is closed. Changes are only seen from when
+// the watch starts
+func (n *Notifier) Watch(ctx context.Context, filter FeatureFilter) (<-chan FeatureEvent, error) {
+	match, err := filter.Predicate()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &watcher{match: match, wake: make(chan struct{}, 1)}
+
+	n.mu.Lock()
+	if n.watchers == nil {
+		n.watchers = map[*watcher]bool{}
+	}
+	n.watchers[w] = true
+	n.mu.Unlock()
+
+	events := make(chan FeatureEvent)
+	go func() {
+		defer close(events)
+		defer func() {
+			n.mu.Lock()
+			delete(n.watchers, w)
+			n.mu.Unlock()
+		}()
+
+		w.run(ctx, events)
+	}()
+
+	return events, nil
+}
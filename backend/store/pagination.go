@@ -0,0 +1,176 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/utils"
+)
+
+// MaxPageSize is the most features a page can hold
+const MaxPageSize = 500
+
+// PageOptions selects a page of features. A Cursor from a previous page
+// takes precedence over Page, which skips PageSize * Page features and gets
+// slower the deeper it goes
+type PageOptions struct {
+	Page     uint      `json:"page"`
+	PageSize uint      `json:"pageSize"`
+	Order    SortOrder `json:"order,omitempty"`
+	Cursor   string    `json:"-"`
+
+	// WithTotal counts every feature that matches the filter, which costs
+	// another query
+	WithTotal bool `json:"-"`
+}
+
+// Normalized clamps the page size between 1 and MaxPageSize, and defaults
+// the order to OrderNewest
+func (p PageOptions) Normalized() PageOptions {
+	p.PageSize = utils.Max(1, utils.Min(p.PageSize, MaxPageSize))
+	if p.Order == "" {
+		p.Order = OrderNewest
+	}
+
+	return p
+}
+
+// FeaturePage is a page of features. Next and Prev are cursors for the
+// neighboring pages, and are empty if there is no such page
+type FeaturePage struct {
+	PageInfo PageOptions       `json:",inline"`
+	Features features.Features `json:"features"`
+	Next     string            `json:"next,omitempty"`
+	Prev     string            `json:"prev,omitempty"`
+	Total    *int64            `json:"total,omitempty"`
+}
+
+// ErrInvalidCursor is returned when a page cursor can't be decoded, or was
+// made for a different sort order
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// SortOrder is the order features are listed in
+type SortOrder string
+
+const (
+	// OrderNewest lists the most recently sent features first. It is the
+	// default
+	OrderNewest SortOrder = "newest"
+
+	// OrderPriority lists the highest priority alerts first, and then the
+	// newest
+	OrderPriority SortOrder = "priority"
+)
+
+// SortKey is a field that features are sorted by, as a document path
+type SortKey struct {
+	Field      string
+	Descending bool
+}
+
+// Keys are the fields an order sorts by. The id is always the last tie
+// breaker, so that every feature has a unique position to resume from
+func (o SortOrder) Keys() []SortKey {
+	if o == OrderPriority {
+		return []SortKey{{"properties.priority", true}, {"properties.sent", true}, {"_id", false}}
+	}
+
+	return []SortKey{{"properties.sent", true}, {"_id", false}}
+}
+
+// Values returns the values of f for each of the order's keys
+func (o SortOrder) Values(f features.Feature) []any {
+	values := []any{}
+	for _, k := range o.Keys() {
+		if k.Field == "_id" {
+			values = append(values, f.ID)
+			continue
+		}
+
+		values = append(values, f.Properties[strings.TrimPrefix(k.Field, "properties.")])
+	}
+
+	return values
+}
+
+// Cursor is the position of a feature in a sort order. Backward cursors
+// select the page before the feature rather than the one after it
+type Cursor struct {
+	Order    SortOrder `json:"o"`
+	Values   []any     `json:"v"`
+	Backward bool      `json:"b,omitempty"`
+}
+
+// Encode returns the cursor as an opaque, URL safe string
+func (p Cursor) Encode() string {
+	raw, _ := json.Marshal(p)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeCursor decodes a cursor made by Encode, which must be for order
+func DecodeCursor(s string, order SortOrder) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var p Cursor
+	if err = json.Unmarshal(raw, &p); err != nil || len(p.Values) != len(p.Order.Keys()) {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	if p.Order != order {
+		return Cursor{}, fmt.Errorf("%w: it is for %s order, not %s", ErrInvalidCursor, p.Order, order)
+	}
+
+	return p, nil
+}
+
+// CursorAt returns a cursor at the position of f in order
+func CursorAt(f features.Feature, order SortOrder, backward bool) string {
+	return Cursor{Order: order, Values: order.Values(f), Backward: backward}.Encode()
+}
+
+// BuildPage makes a page from the features fetched for it. feats are in the
+// direction of position, or in order if there is no cursor, and hold up to
+// one more feature than the page size, which shows there is another page
+func BuildPage(feats features.Features, pageInfo PageOptions, position *Cursor) FeaturePage {
+	more := len(feats) > int(pageInfo.PageSize)
+	if more {
+		feats = feats[:pageInfo.PageSize]
+	}
+
+	backward := position != nil && position.Backward
+	if backward {
+		feats = utils.Reverse(feats)
+	}
+
+	page := FeaturePage{
+		PageInfo: PageOptions{
+			Page:     pageInfo.Page,
+			PageSize: utils.WNMin(pageInfo.PageSize, uint(len(feats))),
+			Order:    pageInfo.Order,
+		},
+		Features: feats,
+	}
+
+	if len(feats) > 0 {
+		// a cursor always has features on the side it came from
+		hasNext := backward || more
+		hasPrev := (backward && more) || (position != nil && !backward) || (position == nil && pageInfo.Page > 0)
+
+		if hasNext {
+			page.Next = CursorAt(feats[len(feats)-1], pageInfo.Order, false)
+		}
+
+		if hasPrev {
+			page.Prev = CursorAt(feats[0], pageInfo.Order, true)
+		}
+	}
+
+	return page
+}
@@ -0,0 +1,49 @@
+package store_test
+
+import (
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/store"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cursors", func() {
+	alert := features.Feature{ID: "a", Properties: features.JSONObject{
+		"@type":    features.Alert,
+		"sent":     "2024-06-05T16:42:00-05:00",
+		"priority": 12.5,
+	}}
+
+	DescribeTable("Decode to the position they were made at",
+		func(order store.SortOrder, backward bool) {
+			c, err := store.DecodeCursor(store.CursorAt(alert, order, backward), order)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c).To(Equal(store.Cursor{Order: order, Values: order.Values(alert), Backward: backward}))
+		},
+		Entry("newest first", store.OrderNewest, false),
+		Entry("newest first, backward", store.OrderNewest, true),
+		Entry("by priority", store.OrderPriority, false),
+	)
+
+	It("Keep features without a sort value in place", func() {
+		f := features.Feature{ID: "b", Properties: features.JSONObject{"@type": features.Alert}}
+		c, err := store.DecodeCursor(store.CursorAt(f, store.OrderPriority, false), store.OrderPriority)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Values).To(Equal([]any{nil, nil, "b"}))
+	})
+
+	It("Only work with the order they were made for", func() {
+		_, err := store.DecodeCursor(store.CursorAt(alert, store.OrderNewest, false), store.OrderPriority)
+		Expect(err).To(MatchError(store.ErrInvalidCursor))
+	})
+
+	DescribeTable("Reject anything else",
+		func(cursor string) {
+			_, err := store.DecodeCursor(cursor, store.OrderNewest)
+			Expect(err).To(MatchError(store.ErrInvalidCursor))
+		},
+		Entry("not base64", "!!!"),
+		Entry("not JSON", "bm90IGpzb24"),
+		Entry("the wrong number of values", store.Cursor{Order: store.OrderNewest, Values: []any{"x"}}.Encode()),
+	)
+})
@@ -0,0 +1,34 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+)
+
+// QuarantinedFeature is a feature that failed validation, along with why
+type QuarantinedFeature struct {
+	Feature       features.Feature `json:"feature"`
+	Source        string           `json:"source"`
+	Errors        []string         `json:"errors"`
+	QuarantinedAt time.Time        `json:"quarantinedAt"`
+}
+
+// Quarantined records that f failed validation now
+func Quarantined(f features.Feature, source string, reason error) QuarantinedFeature {
+	q := QuarantinedFeature{
+		Feature:       f,
+		Source:        source,
+		QuarantinedAt: time.Now().UTC(),
+	}
+
+	var verr *features.ValidationError
+	if errors.As(reason, &verr) {
+		q.Errors = verr.Errors
+	} else if reason != nil {
+		q.Errors = []string{reason.Error()}
+	}
+
+	return q
+}
@@ -0,0 +1,231 @@
+package store
+
+import (
+	"errors"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+)
+
+// ErrEmptySearch is returned when a search has no terms
+var ErrEmptySearch = errors.New("search query is empty")
+
+// SearchFields are the properties that text searches cover, and the ones
+// that are highlighted in results
+var SearchFields = []string{"event", "headline", "areaDesc", "senderName", "description", "instruction"}
+
+// SearchWeights are how much a match in each of the SearchFields counts
+// toward relevance. Matches in short fields count for more than matches in
+// the body of an alert
+var SearchWeights = map[string]int{
+	"event":       10,
+	"headline":    5,
+	"areaDesc":    5,
+	"senderName":  3,
+	"description": 1,
+	"instruction": 1,
+}
+
+const (
+	// maxHighlights is how many fragments are highlighted per field
+	maxHighlights = 3
+
+	// highlightContext is roughly how many bytes of text are kept on either
+	// side of a match
+	highlightContext = 60
+)
+
+// SearchHit is a feature that matched a search. Highlights are fragments of
+// the matching fields, HTML escaped, with the matched terms wrapped in <mark>
+type SearchHit struct {
+	Feature    features.Feature    `json:"feature"`
+	Score      float64             `json:"score"`
+	Highlights map[string][]string `json:"highlights,omitempty"`
+}
+
+// SearchPage is a page of search results, most relevant first
+type SearchPage struct {
+	PageInfo PageOptions `json:",inline"`
+	Query    string      `json:"query"`
+	Hits     []SearchHit `json:"hits"`
+	Total    *int64      `json:"total,omitempty"`
+}
+
+// Features returns the features of the hits, in order
+func (p SearchPage) Features() features.Features {
+	feats := make(features.Features, len(p.Hits))
+	for i, hit := range p.Hits {
+		feats[i] = hit.Feature
+	}
+
+	return feats
+}
+
+// TextSearch is a text search in Mongo's $text syntax: words match any
+// form of the word, "quoted phrases" must appear as written, and -words or
+// -"phrases" must not appear
+type TextSearch struct {
+	Words    []string
+	Phrases  []string
+	Excluded []string
+}
+
+// ParseTextSearch splits a text search into its parts
+func ParseTextSearch(q string) TextSearch {
+	var t TextSearch
+	excluded := false
+	for i, part := range strings.Split(q, `"`) {
+		// odd parts are inside quotes
+		if i%2 == 1 {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+
+			if excluded {
+				t.Excluded = append(t.Excluded, part)
+			} else {
+				t.Phrases = append(t.Phrases, part)
+			}
+			continue
+		}
+
+		excluded = strings.HasSuffix(part, "-")
+		for _, word := range strings.Fields(part) {
+			negated := strings.HasPrefix(word, "-")
+			if word = strings.Trim(word, `-.,;:!?()[]{}'`); word == "" {
+				continue
+			}
+
+			if negated {
+				t.Excluded = append(t.Excluded, word)
+			} else {
+				t.Words = append(t.Words, word)
+			}
+		}
+	}
+
+	return t
+}
+
+// Terms are the phrases and words that matching features contain
+func (t TextSearch) Terms() []string {
+	return append(append([]string{}, t.Phrases...), t.Words...)
+}
+
+// HighlightPattern matches the search terms at the start of a word. Mongo
+// stems words, so a match runs on to the end of the word, e.g. a search for
+// storm highlights storms too. It is nil if there are no terms to highlight
+func HighlightPattern(q string) *regexp.Regexp {
+	return TermPattern(ParseTextSearch(q).Terms()...)
+}
+
+// TermPattern matches any of terms at the start of a word, running on to the
+// end of the word. Spaces in terms match any whitespace. Words are made of
+// letters and digits in any script, so the match is in its first group,
+// after the character before the word; use TermMatches to find them. It is
+// nil if there are no terms
+func TermPattern(terms ...string) *regexp.Regexp {
+	if len(terms) == 0 {
+		return nil
+	}
+
+	alternatives := make([]string, len(terms))
+	for i, term := range terms {
+		words := strings.Fields(term)
+		for j, word := range words {
+			words[j] = regexp.QuoteMeta(word)
+		}
+		alternatives[i] = strings.Join(words, `\s+`)
+	}
+
+	// \b and \w only know ASCII
+	return regexp.MustCompile(`(?i)(?:^|[^\pL\pM\pN_])((?:` + strings.Join(alternatives, "|") + `)[\pL\pM\pN_]*)`)
+}
+
+// TermMatches returns the start and end of each match of a TermPattern in
+// text
+func TermMatches(pattern *regexp.Regexp, text string) [][2]int {
+	matches := [][2]int{}
+	for _, m := range pattern.FindAllStringSubmatchIndex(text, -1) {
+		matches = append(matches, [2]int{m[2], m[3]})
+	}
+
+	return matches
+}
+
+// Highlights returns the highlighted fragments of each of the SearchFields
+// of f that pattern matches
+func Highlights(f features.Feature, pattern *regexp.Regexp) map[string][]string {
+	highlights := map[string][]string{}
+	for _, field := range SearchFields {
+		if fragments := Highlight(f.Properties.StringValue(field), pattern); len(fragments) > 0 {
+			highlights[field] = fragments
+		}
+	}
+
+	return highlights
+}
+
+// Highlight returns up to maxHighlights fragments of text around the matches
+// of pattern. Whitespace is collapsed, the text is HTML escaped, and matches
+// are wrapped in <mark>
+func Highlight(text string, pattern *regexp.Regexp) []string {
+	text = strings.Join(strings.Fields(text), " ")
+	matches := TermMatches(pattern, text)
+
+	fragments := []string{}
+	for i := 0; i < len(matches) && len(fragments) < maxHighlights; {
+		start := wordStart(text, matches[i][0]-highlightContext)
+		end := wordEnd(text, matches[i][1]+highlightContext)
+
+		sb := new(strings.Builder)
+		if start > 0 {
+			sb.WriteString("…")
+		}
+
+		// matches that start inside the fragment are marked in it
+		pos := start
+		for ; i < len(matches) && matches[i][0] < end; i++ {
+			if matches[i][1] > end {
+				end = matches[i][1]
+			}
+
+			sb.WriteString(html.EscapeString(text[pos:matches[i][0]]))
+			sb.WriteString("<mark>" + html.EscapeString(text[matches[i][0]:matches[i][1]]) + "</mark>")
+			pos = matches[i][1]
+		}
+
+		sb.WriteString(html.EscapeString(text[pos:end]))
+		if end < len(text) {
+			sb.WriteString("…")
+		}
+
+		fragments = append(fragments, sb.String())
+	}
+
+	return fragments
+}
+
+// wordStart moves i back to the start of the word it is in
+func wordStart(text string, i int) int {
+	if i <= 0 {
+		return 0
+	}
+
+	return strings.LastIndexByte(text[:i], ' ') + 1
+}
+
+// wordEnd moves i forward to the end of the word it is in
+func wordEnd(text string, i int) int {
+	if i >= len(text) {
+		return len(text)
+	}
+
+	if k := strings.IndexByte(text[i:], ' '); k >= 0 {
+		return i + k
+	}
+
+	return len(text)
+}
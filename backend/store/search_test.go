@@ -0,0 +1,47 @@
+package store_test
+
+import (
+	"github.com/jghiloni/watchedsky-social/backend/store"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Text search", func() {
+	It("Splits searches into words, phrases and excluded terms", func() {
+		Expect(store.ParseTextSearch(`tornado "flash flood" -test -"practice run"`)).To(Equal(store.TextSearch{
+			Words:    []string{"tornado"},
+			Phrases:  []string{"flash flood"},
+			Excluded: []string{"test", "practice run"},
+		}))
+	})
+
+	It("Highlights overlapping terms once", func() {
+		pattern := store.HighlightPattern(`storm storms "storm surge"`)
+		Expect(store.Highlight("Storm surge from the storms", pattern)).To(Equal([]string{
+			"<mark>Storm surge</mark> from the <mark>storms</mark>",
+		}))
+	})
+
+	It("Only matches terms at the start of words", func() {
+		pattern := store.HighlightPattern("storm")
+		Expect(store.Highlight("Thunderstorms and brainstorming", pattern)).To(BeEmpty())
+	})
+
+	It("Highlights words in any script", func() {
+		pattern := store.HighlightPattern("meteorológica éclair")
+		Expect(store.Highlight("Alerta meteorológica, Éclairs", pattern)).To(Equal([]string{
+			"Alerta <mark>meteorológica</mark>, <mark>Éclairs</mark>",
+		}))
+
+		// ó is a letter, so it doesn't end the word
+		Expect(store.Highlight("meteorológica", store.HighlightPattern("meteorol"))).To(Equal([]string{
+			"<mark>meteorológica</mark>",
+		}))
+	})
+
+	It("Escapes HTML around highlights", func() {
+		Expect(store.Highlight("<b>tornado</b>", store.HighlightPattern("tornado"))).To(Equal([]string{
+			"&lt;b&gt;<mark>tornado</mark>&lt;/b&gt;",
+		}))
+	})
+})
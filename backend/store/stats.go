@@ -0,0 +1,208 @@
+package store
+
+import (
+	"sort"
+	"time"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+)
+
+// StatsQuery selects the alerts that statistics are gathered over. Only new
+// alerts count, not updates or cancellations of them, and archived alerts
+// are always included
+type StatsQuery struct {
+	// Filter narrows down the alerts. Its Type and Archived fields are
+	// ignored
+	Filter FeatureFilter
+
+	// From and To select alerts sent at or after From and before To. Either
+	// can be zero to leave that end open
+	From time.Time
+	To   time.Time
+}
+
+// Count is how many alerts share a value
+type Count struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+// AlertStats are statistics about the alerts a StatsQuery selects. The
+// counts are highest first, except ByDay, which is in order of the UTC days
+// the alerts were sent. Alerts without a value aren't counted in a
+// breakdown, and alerts for more than one state count once for each
+type AlertStats struct {
+	Total      int64   `json:"total"`
+	ByEvent    []Count `json:"byEvent"`
+	BySeverity []Count `json:"bySeverity"`
+	BySender   []Count `json:"bySender"`
+	ByState    []Count `json:"byState"`
+	ByDay      []Count `json:"byDay"`
+
+	// MedianDurationSeconds is the median time from when alerts take effect
+	// until they end or expire
+	MedianDurationSeconds *float64 `json:"medianDurationSeconds,omitempty"`
+
+	// FirstSent and LastSent are when the earliest and latest alerts were
+	// sent
+	FirstSent *time.Time `json:"firstSent,omitempty"`
+	LastSent  *time.Time `json:"lastSent,omitempty"`
+}
+
+// statsDayFormat is how days are keyed in AlertStats.ByDay
+const statsDayFormat = "2006-01-02"
+
+// Predicate reports whether an alert is covered by the query
+func (q StatsQuery) Predicate() (func(features.Feature) bool, error) {
+	filter := q.Filter
+	filter.Type = features.Alert
+
+	match, err := filter.Predicate()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(f features.Feature) bool {
+		if !match(f) || f.Properties.StringValue("messageType") != "Alert" {
+			return false
+		}
+
+		if q.From.IsZero() && q.To.IsZero() {
+			return true
+		}
+
+		sent, ok := f.Time(features.SentField)
+		return ok && (q.From.IsZero() || !sent.Before(q.From)) && (q.To.IsZero() || sent.Before(q.To))
+	}, nil
+}
+
+// SortCounts turns counts by key into Counts, highest first, dropping the
+// empty key
+func SortCounts(counts map[string]int64) []Count {
+	sorted := make([]Count, 0, len(counts))
+	for k, n := range counts {
+		if k != "" {
+			sorted = append(sorted, Count{Key: k, Count: n})
+		}
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Count != sorted[j].Count {
+			return sorted[i].Count > sorted[j].Count
+		}
+
+		return sorted[i].Key < sorted[j].Key
+	})
+
+	return sorted
+}
+
+// SortDays turns counts by day into Counts, in order of day
+func SortDays(counts map[string]int64) []Count {
+	sorted := SortCounts(counts)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Key < sorted[j].Key
+	})
+
+	return sorted
+}
+
+// StatesOf returns the postal abbreviations of the states a list of SAME
+// geocodes cover, once each. Codes for unknown states are kept as their FIPS
+// codes
+func StatesOf(sameCodes []string) []string {
+	seen := map[string]bool{}
+	states := []string{}
+	for _, code := range sameCodes {
+		if len(code) != 6 {
+			continue
+		}
+
+		state, ok := features.StateAbbreviation(code[1:3])
+		if !ok {
+			state = code[1:3]
+		}
+
+		if !seen[state] {
+			seen[state] = true
+			states = append(states, state)
+		}
+	}
+
+	return states
+}
+
+// Median returns the median of durations, which must be sorted. It is nil
+// if there are none
+func Median(durations []time.Duration) *float64 {
+	if len(durations) == 0 {
+		return nil
+	}
+
+	mid := len(durations) / 2
+	median := durations[mid].Seconds()
+	if len(durations)%2 == 0 {
+		median = (durations[mid-1].Seconds() + median) / 2
+	}
+
+	return &median
+}
+
+// Tally gathers statistics over alerts, which must already be the ones a
+// query covers
+func Tally(alerts features.Features) AlertStats {
+	byEvent := map[string]int64{}
+	bySeverity := map[string]int64{}
+	bySender := map[string]int64{}
+	byState := map[string]int64{}
+	byDay := map[string]int64{}
+	durations := []time.Duration{}
+
+	stats := AlertStats{Total: int64(len(alerts))}
+	for _, f := range alerts {
+		byEvent[f.Properties.StringValue("event")]++
+		bySeverity[f.Properties.StringValue("severity")]++
+		bySender[f.Properties.StringValue("senderName")]++
+		for _, state := range StatesOf(f.SAMECodes()) {
+			byState[state]++
+		}
+
+		sent, ok := f.Time(features.SentField)
+		sent = sent.UTC()
+		if ok {
+			byDay[sent.Format(statsDayFormat)]++
+
+			if stats.FirstSent == nil || sent.Before(*stats.FirstSent) {
+				first := sent
+				stats.FirstSent = &first
+			}
+
+			if stats.LastSent == nil || sent.After(*stats.LastSent) {
+				last := sent
+				stats.LastSent = &last
+			}
+		}
+
+		starts, ok := f.Properties.TimeValue("effective")
+		if !ok {
+			starts, ok = sent, !sent.IsZero()
+		}
+
+		if ends, endsOK := f.EndsAt(); ok && endsOK && !ends.Before(starts) {
+			durations = append(durations, ends.Sub(starts))
+		}
+	}
+
+	sort.Slice(durations, func(i, j int) bool {
+		return durations[i] < durations[j]
+	})
+
+	stats.ByEvent = SortCounts(byEvent)
+	stats.BySeverity = SortCounts(bySeverity)
+	stats.BySender = SortCounts(bySender)
+	stats.ByState = SortCounts(byState)
+	stats.ByDay = SortDays(byDay)
+	stats.MedianDurationSeconds = Median(durations)
+
+	return stats
+}
@@ -0,0 +1,165 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jghiloni/watchedsky-social/backend/appcontext"
+	"github.com/jghiloni/watchedsky-social/backend/config"
+	"github.com/jghiloni/watchedsky-social/backend/features"
+	"github.com/jghiloni/watchedsky-social/backend/geojson"
+)
+
+// DefaultBackend is used when the config doesn't name one
+const DefaultBackend = "mongo"
+
+// ArchivedAtField is the property set to when a feature was archived
+const ArchivedAtField = "archivedAt"
+
+// FeatureStore is where features are kept and queried. Implementations must
+// be safe for concurrent use
+type FeatureStore interface {
+	// ListFeatures returns a page of the features that match filter
+	ListFeatures(ctx context.Context, filter FeatureFilter, pageInfo PageOptions) (FeaturePage, error)
+
+	// SearchFeatures finds the features that match both a text search and
+	// filter, most relevant first
+	SearchFeatures(ctx context.Context, q string, filter FeatureFilter, pageInfo PageOptions) (SearchPage, error)
+
+	// GetFeaturesByID returns the stored features with the given IDs. IDs
+	// that aren't stored are left out
+	GetFeaturesByID(ctx context.Context, ids ...string) (features.FeatureCollection, error)
+
+	// GetAffectedZones returns the zones an alert affects
+	GetAffectedZones(ctx context.Context, alert features.Feature) (features.Features, error)
+
+	// GetAlertVersions returns every stored version of an alert, archived or
+	// not
+	GetAlertVersions(ctx context.Context, id string) (features.Features, error)
+
+	// GetAlertStats gathers statistics about the alerts a query selects
+	GetAlertStats(ctx context.Context, q StatsQuery) (AlertStats, error)
+
+	// GetPreviousVersion returns the most recent stored alert that f updates
+	// or cancels, archived or not. The second return value is false if there
+	// isn't one
+	GetPreviousVersion(ctx context.Context, f features.Feature) (features.Feature, bool, error)
+
+	// FindIntersecting returns the features whose geometry intersects g
+	FindIntersecting(ctx context.Context, g geojson.Geometry, types ...string) (features.FeatureCollection, error)
+
+	// FindNear returns the features within maxDistanceKm of point, nearest
+	// first. A maxDistanceKm of 0 or less means no limit
+	FindNear(ctx context.Context, point geojson.Point, maxDistanceKm float64, types ...string) (features.FeatureCollection, error)
+
+	// FindWithinBBox returns the features whose geometry lies entirely within
+	// bbox
+	FindWithinBBox(ctx context.Context, bbox geojson.BoundingBox, types ...string) (features.FeatureCollection, error)
+
+	// AddFeatures upserts features. A stored feature is only replaced by a
+	// version sent later
+	AddFeatures(ctx context.Context, feats ...features.Feature) (WriteResult, error)
+
+	// SetPostURI records the AT URI of the post that announced a feature
+	SetPostURI(ctx context.Context, id string, uri string) error
+
+	// QuarantineFeature keeps a feature that failed validation. source says
+	// where it came from, e.g. poller or firehose
+	QuarantineFeature(ctx context.Context, f features.Feature, source string, reason error) error
+
+	// WatchFeatures streams changes to the features that match filter until
+	// ctx is done
+	WatchFeatures(ctx context.Context, filter FeatureFilter, opts WatchOptions) (<-chan FeatureEvent, error)
+
+	// ArchiveEndedAlerts moves the alerts that ended before t to the archive,
+	// and returns how many it moved
+	ArchiveEndedAlerts(ctx context.Context, t time.Time) (int, error)
+
+	// ApplyRetention sets how long archived features of each type are kept
+	ApplyRetention(ctx context.Context, windows map[string]time.Duration) error
+}
+
+// Backend opens a FeatureStore. It returns a nil store if it isn't
+// configured, in which case the app runs without one
+type Backend func(ctx context.Context, cfg config.AppConfig) (FeatureStore, error)
+
+var (
+	backends   = map[string]Backend{}
+	backendsMu = new(sync.RWMutex)
+)
+
+// RegisterBackend makes a backend available under a name, which the store
+// config selects it by. Backends register themselves from init, so the
+// packages that provide them must be imported by the binary
+func RegisterBackend(name string, backend Backend) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	if _, ok := backends[name]; ok {
+		panic(fmt.Sprintf("store backend %q registered twice", name))
+	}
+	backends[name] = backend
+}
+
+// Backends lists the names of the registered backends
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+type contextKey struct{}
+
+var storeContextKey contextKey
+
+func loadStoreToContext(ctx context.Context, cfg config.AppConfig) (context.Context, error) {
+	name := cfg.Store.Backend
+	if name == "" {
+		name = DefaultBackend
+	}
+
+	backendsMu.RLock()
+	backend, ok := backends[name]
+	backendsMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown store backend %q, expected one of %s", name, strings.Join(Backends(), ", "))
+	}
+
+	s, err := backend(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if s == nil {
+		return ctx, nil
+	}
+
+	return WithStore(ctx, s), nil
+}
+
+func init() {
+	appcontext.Registry.RegisterClient(loadStoreToContext)
+}
+
+// WithStore returns a copy of ctx that carries s
+func WithStore(ctx context.Context, s FeatureStore) context.Context {
+	return context.WithValue(ctx, storeContextKey, s)
+}
+
+// GetStore returns the store in ctx, or nil if there isn't one
+func GetStore(ctx context.Context) FeatureStore {
+	s, _ := ctx.Value(storeContextKey).(FeatureStore)
+	return s
+}
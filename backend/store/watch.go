@@ -0,0 +1,36 @@
+package store
+
+import (
+	"time"
+
+	"github.com/jghiloni/watchedsky-social/backend/features"
+)
+
+// ChangeType is the kind of change a FeatureEvent reports
+type ChangeType string
+
+const (
+	ChangeInsert ChangeType = "insert"
+	ChangeUpdate ChangeType = "update"
+	ChangeDelete ChangeType = "delete"
+)
+
+// FeatureEvent is a change to a stored feature. Feature is the feature after
+// the change, and is nil for deletes
+type FeatureEvent struct {
+	Type    ChangeType        `json:"type"`
+	ID      string            `json:"id"`
+	Feature *features.Feature `json:"feature,omitempty"`
+}
+
+// WatchOptions configure WatchFeatures
+type WatchOptions struct {
+	// Name identifies a watcher across restarts. Named watchers store their
+	// change stream resume token, and pick up where they left off. Unnamed
+	// watchers only see changes made after they start
+	Name string
+
+	// PollInterval is how often the collection is polled on servers without
+	// change streams. It defaults to 5 seconds
+	PollInterval time.Duration
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+// WriteStatus is what happened to one feature in a write
+type WriteStatus string
+
+const (
+	WriteInserted WriteStatus = "inserted"
+	WriteUpdated  WriteStatus = "updated"
+	WriteSkipped  WriteStatus = "skipped"
+	WriteFailed   WriteStatus = "failed"
+)
+
+// WriteOutcome is what happened to one feature in a write
+type WriteOutcome struct {
+	ID     string      `json:"id"`
+	Status WriteStatus `json:"status"`
+	Err    error       `json:"-"`
+}
+
+// WriteResult counts what happened to the features in a write. Outcomes are
+// in the same order as the features
+type WriteResult struct {
+	Inserted int            `json:"inserted"`
+	Updated  int            `json:"updated"`
+	Skipped  int            `json:"skipped"`
+	Failed   int            `json:"failed"`
+	Outcomes []WriteOutcome `json:"outcomes"`
+}
+
+// Record sets the outcome of the i-th feature, and counts it
+func (r *WriteResult) Record(i int, status WriteStatus, err error) {
+	r.Outcomes[i].Status = status
+	r.Outcomes[i].Err = err
+	switch status {
+	case WriteInserted:
+		r.Inserted++
+	case WriteUpdated:
+		r.Updated++
+	case WriteSkipped:
+		r.Skipped++
+	case WriteFailed:
+		r.Failed++
+	}
+}
+
+// Err joins the errors of the features that failed, or returns nil if none
+// did
+func (r WriteResult) Err() error {
+	if r.Failed == 0 {
+		return nil
+	}
+
+	errs := []error{}
+	for _, o := range r.Outcomes {
+		if o.Status == WriteFailed {
+			errs = append(errs, fmt.Errorf("%s: %w", o.ID, o.Err))
+		}
+	}
+
+	return fmt.Errorf("%d of %d features could not be written: %w", r.Failed, len(r.Outcomes), errors.Join(errs...))
+}
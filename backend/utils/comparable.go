@@ -35,3 +35,15 @@ func Max[C Comparable](c1 C, cs ...C) C {
 
 	return max
 }
+
+// Coalesce returns the first argument that is not the zero value
+func Coalesce[T comparable](values ...T) T {
+	var zero T
+	for _, v := range values {
+		if v != zero {
+			return v
+		}
+	}
+
+	return zero
+}
@@ -1,6 +1,10 @@
 package utils
 
-import "fmt"
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 func AnySlice[T any](src []T) []any {
 	if src == nil {
@@ -87,3 +91,18 @@ func Reduce[R any, T any](src []T, reducer func(R, T) R, initialValue R) R {
 
 	return value
 }
+
+// NormalizeSlice converts the list types produced by decoding JSON or BSON
+// into a plain []any
+func NormalizeSlice(data any) ([]any, bool) {
+	switch a := data.(type) {
+	case []any:
+		return a, true
+	case primitive.A:
+		return []any(a), true
+	case []string:
+		return AnySlice(a), true
+	default:
+		return nil, false
+	}
+}
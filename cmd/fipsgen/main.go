@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	countyListURL = "https://www2.census.gov/geo/docs/reference/codes2020/national_county2020.txt"
+	datasetPath   = "backend/features/data/fips.csv"
+)
+
+// fipsgen rebuilds the embedded FIPS dataset. State rows (county FIPS 000) are
+// kept from the existing dataset, and county rows are replaced with the
+// current Census Bureau county list. Where census.gov can't be reached, a
+// downloaded copy of the list can be given with -counties
+func main() {
+	countyList := flag.String("counties", countyListURL, "URL or path of the Census Bureau county list")
+	flag.Parse()
+
+	rows, err := readStateRows(datasetPath)
+	if err != nil {
+		panic(err)
+	}
+
+	list, err := openCountyList(*countyList)
+	if err != nil {
+		panic(err)
+	}
+	defer list.Close()
+
+	// STATE|STATEFP|COUNTYFP|COUNTYNS|COUNTYNAME|CLASSFP|FUNCSTAT
+	r := csv.NewReader(list)
+	r.Comma = '|'
+	counties, err := r.ReadAll()
+	if err != nil {
+		panic(err)
+	}
+
+	for _, c := range counties[1:] {
+		rows = append(rows, []string{c[0], c[1], c[2], c[4]})
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i][1]+rows[i][2] < rows[j][1]+rows[j][2]
+	})
+
+	out, err := os.Create(datasetPath)
+	if err != nil {
+		panic(err)
+	}
+	defer out.Close()
+
+	w := csv.NewWriter(out)
+	if err = w.Write([]string{"state", "state_fips", "county_fips", "name"}); err != nil {
+		panic(err)
+	}
+
+	if err = w.WriteAll(rows); err != nil {
+		panic(err)
+	}
+}
+
+// openCountyList opens the county list at a URL or a path
+func openCountyList(location string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		return os.Open(location)
+	}
+
+	resp, err := http.Get(location)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("expected status code 200 from %s, got %d", location, resp.StatusCode)
+	}
+
+	return resp.Body, nil
+}
+
+func readStateRows(path string) ([][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	all, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	states := [][]string{}
+	for _, row := range all[1:] {
+		if row[2] == "000" {
+			states = append(states, row)
+		}
+	}
+
+	return states, nil
+}
@@ -10,7 +10,7 @@ func main() {
 		MaxStringLength: 1_000_000,
 	}
 
-	if err := genCfg.WriteMapEncodersToFile("backend/bsky/cbor_gen.go", "bsky", bsky.Alert{}); err != nil {
+	if err := genCfg.WriteMapEncodersToFile("backend/bsky/cbor_gen.go", "bsky", bsky.Alert{}, bsky.Alert_Hazards{}, bsky.Alert_Localization{}); err != nil {
 		panic(err)
 	}
 }
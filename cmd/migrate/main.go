@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jghiloni/watchedsky-social/backend/config"
+	"github.com/jghiloni/watchedsky-social/backend/mongo"
+)
+
+// migrate applies pending database migrations, or with -status, lists every
+// migration and when it was applied. The database is configured the same
+// way as for watchedsky
+func main() {
+	status := flag.Bool("status", false, "List migrations instead of applying them")
+
+	ctx, err := config.LoadAppConfig(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	client, err := mongo.Connect(ctx, config.GetConfig(ctx).MongoDB)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *status {
+		statuses, err := client.GetMigrationStatus(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Local().Format(time.RFC3339)
+			}
+
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		w.Flush()
+		return
+	}
+
+	ran, err := client.Migrate(ctx)
+	for _, r := range ran {
+		fmt.Printf("applied %d: %s\n", r.Version, r.Name)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(ran) == 0 {
+		fmt.Println("database is up to date")
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	lexiconPath = "lexicons/social/watchedsky/alert.json"
+	schemaPath  = "backend/features/schemas/wx_Alert.json"
+)
+
+type lexProperty struct {
+	Type        string                  `json:"type"`
+	Description string                  `json:"description"`
+	Format      string                  `json:"format"`
+	MaxLength   int                     `json:"maxLength"`
+	KnownValues []string                `json:"knownValues"`
+	Items       *lexProperty            `json:"items"`
+	Properties  map[string]*lexProperty `json:"properties"`
+	Required    []string                `json:"required"`
+	Record      *lexProperty            `json:"record"`
+}
+
+type lexicon struct {
+	Defs map[string]lexProperty `json:"defs"`
+}
+
+// skipped are lexicon properties that aren't alert properties. The geometry
+// is the feature's own, and the rest are denormalized from the geocode,
+// eventCode and parameters properties, which the NWS always sends
+var skipped = map[string]bool{
+	"geometry":               true,
+	"sameCodes":              true,
+	"easEventCode":           true,
+	"hazards":                true,
+	"eventMotionDescription": true,
+}
+
+// schemagen derives the JSON Schema of wx:Alert features from the alert
+// lexicon, so that ingested alerts are checked against the same rules as the
+// records they become
+func main() {
+	raw, err := os.ReadFile(lexiconPath)
+	if err != nil {
+		panic(err)
+	}
+
+	var lex lexicon
+	if err = json.Unmarshal(raw, &lex); err != nil {
+		panic(err)
+	}
+
+	record := lex.Defs["main"].Record
+	if record == nil {
+		panic(fmt.Errorf("%s has no record definition", lexiconPath))
+	}
+
+	required := map[string]bool{}
+	for _, name := range record.Required {
+		required[name] = true
+	}
+
+	props := map[string]any{
+		"@type": map[string]any{"const": "wx:Alert"},
+	}
+
+	for name, p := range record.Properties {
+		if skipped[name] {
+			continue
+		}
+
+		s := schemaFor(p)
+		if name == "references" {
+			// the NWS sends references as objects, but bare identifiers are
+			// accepted too, as they are by features.Feature.References
+			s["items"] = map[string]any{
+				"anyOf": []any{
+					map[string]any{"type": "string"},
+					map[string]any{
+						"type":     "object",
+						"required": []string{"identifier"},
+						"properties": map[string]any{
+							"identifier": map[string]any{"type": "string"},
+						},
+					},
+				},
+			}
+		}
+
+		if !required[name] {
+			s["type"] = []string{s["type"].(string), "null"}
+		}
+
+		props[name] = s
+	}
+
+	req := []string{"@type"}
+	for name := range required {
+		if !skipped[name] {
+			req = append(req, name)
+		}
+	}
+	sort.Strings(req)
+
+	schema := map[string]any{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"$id":         "https://watchedsky.social/schemas/wx_Alert.json",
+		"title":       "wx:Alert",
+		"description": "Generated from " + lexiconPath + " by cmd/schemagen. DO NOT EDIT",
+		"type":        "object",
+		"required":    []string{"id", "properties"},
+		"properties": map[string]any{
+			"id": map[string]any{"type": "string", "minLength": 1},
+			"properties": map[string]any{
+				"type":       "object",
+				"required":   req,
+				"properties": props,
+			},
+		},
+	}
+
+	out, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	if err = os.WriteFile(schemaPath, append(out, '\n'), 0o644); err != nil {
+		panic(err)
+	}
+}
+
+func schemaFor(p *lexProperty) map[string]any {
+	s := map[string]any{}
+	if p.Description != "" {
+		s["description"] = p.Description
+	}
+
+	switch p.Type {
+	case "string":
+		s["type"] = "string"
+		if p.Format == "datetime" {
+			s["format"] = "date-time"
+		}
+
+		if p.MaxLength > 0 {
+			s["maxLength"] = p.MaxLength
+		}
+
+		if len(p.KnownValues) > 0 {
+			// known values are open ended, so they are documented rather than
+			// enforced
+			values := make([]string, 0, len(p.KnownValues))
+			for _, v := range p.KnownValues {
+				values = append(values, v[strings.LastIndex(v, ".")+1:])
+			}
+			s["examples"] = values
+		}
+	case "integer":
+		s["type"] = "integer"
+	case "boolean":
+		s["type"] = "boolean"
+	case "array":
+		s["type"] = "array"
+		if p.Items != nil {
+			s["items"] = schemaFor(p.Items)
+		}
+	default:
+		s["type"] = "object"
+	}
+
+	return s
+}
@@ -10,6 +10,11 @@ import (
 	"github.com/jghiloni/watchedsky-social/backend/appcontext"
 	"github.com/jghiloni/watchedsky-social/backend/config"
 	"github.com/jghiloni/watchedsky-social/backend/daemons"
+
+	// feature store backends register themselves
+	_ "github.com/jghiloni/watchedsky-social/backend/mongo"
+	_ "github.com/jghiloni/watchedsky-social/backend/store/bolt"
+	_ "github.com/jghiloni/watchedsky-social/backend/store/memory"
 )
 
 func main() {
@@ -37,4 +42,6 @@ func main() {
 	go daemons.StartDaemon(ctx, "HTTPServer", daemons.HTTPServerDaemon)
 	go daemons.StartDaemon(ctx, "AlertPoller", daemons.AlertPoller)
 	go daemons.StartDaemon(ctx, "FirehoseNozzle", daemons.FirehoseNozzle)
+	go daemons.StartDaemon(ctx, "Retention", daemons.Retention)
+	go daemons.StartDaemon(ctx, "Metrics", daemons.Metrics)
 }